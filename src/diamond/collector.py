@@ -10,6 +10,7 @@ import os
 import platform
 import re
 import socket
+import ssl
 import subprocess
 import sys
 import time
@@ -17,8 +18,10 @@ import time
 from diamond.metric import Metric
 from error import DiamondException
 
-FULLERITE_ADDR = ('', 19191)
+FULLERITE_ADDR = ('127.0.0.1', 19191)
 FULLERITE_RETRY_COUNT = 3
+FULLERITE_CONNECTION_KEYS = ['fulleriteHost', 'fulleriteCA', 'fulleriteCert',
+                             'fulleriteKey', 'fulleriteServerName']
 
 # Detect the architecture of the system and set the counters for MAX_VALUES
 # appropriately. Otherwise, rolling over counters will cause incorrect or
@@ -107,19 +110,35 @@ class Collector(object):
         fullerite_addr = FULLERITE_ADDR
         try:
             if 'fulleritePort' in self.config:
-                fullerite_addr = ('', int(self.config['fulleritePort']))
+                fullerite_addr = (self.config.get('fulleriteHost') or FULLERITE_ADDR[0],
+                                  int(self.config['fulleritePort']))
         except TypeError:
             raise "Invalid fullerite port %s" % self.config['fulleritePort']
 
         self.log.debug("Connecting to fullerite at %s", fullerite_addr)
-        sock = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
         try:
-            sock.connect(fullerite_addr)
+            sock = socket.create_connection(fullerite_addr)
+            if self.config.get('fulleriteCA'):
+                sock = self._wrap_tls(sock, fullerite_addr[0])
         except socket.error, msg:
             self.log.warn("Error connecting to fullerite TCP port: %s", msg)
             sys.exit(1)
         return sock
 
+    def _wrap_tls(self, sock, host):
+        """
+        Speak TLS to a fullerite that has a server certificate. The
+        certificate has to be signed by fulleriteCA, and fulleriteCert and
+        fulleriteKey are presented to a fullerite that wants client
+        certificates.
+        """
+        context = ssl.create_default_context(cafile=self.config['fulleriteCA'])
+        if self.config.get('fulleriteCert'):
+            context.load_cert_chain(self.config['fulleriteCert'],
+                                    self.config.get('fulleriteKey'))
+        server_hostname = self.config.get('fulleriteServerName') or host
+        return context.wrap_socket(sock, server_hostname=server_hostname)
+
     def load_config(self, config):
         """
         Process a configfile, or reload if previously given one.
@@ -135,9 +154,14 @@ class Collector(object):
         # check if they are enabled.
         #
         # We use "fulleritePort" in collectors to connect
-        # to the running fullerite instance.
+        # to the running fullerite instance, and the other
+        # FULLERITE_CONNECTION_KEYS if its Diamond listener
+        # is bound to an address or speaks TLS.
         self.config['enabled'] = True
         self.config['fulleritePort'] = config['fulleritePort']
+        for key in FULLERITE_CONNECTION_KEYS:
+            if config.get(key):
+                self.config[key] = config[key]
         self.config['interval'] = self.configfile.get('interval', config['interval'])
 
         self.config.update(config.get('defaultConfig', {}))
@@ -350,6 +374,7 @@ class Collector(object):
             'name': metric.getMetricPath(),
             'value': value,
             'type': metric.metric_type,
+            'timestamp': metric.timestamp,
             'dimensions': {
                 'prefix': metric.getPathPrefix(),
                 'collector': metric.getCollectorPath(),
@@ -103,6 +103,32 @@ class BaseCollectorTest(unittest.TestCase):
         self.assertEquals(mock_socket.sendall.call_count, 1)
         self.assertEquals(len(c.payload), 1)
 
+    @patch('diamond.collector.socket.create_connection')
+    def test_connect_plaintext(self, mock_connect):
+        c = Collector(self.config_object(), [])
+        self.assertEquals(c._connect(), mock_connect.return_value)
+        mock_connect.assert_called_once_with(('127.0.0.1', 0))
+
+    @patch('diamond.collector.ssl.create_default_context')
+    @patch('diamond.collector.socket.create_connection')
+    def test_connect_tls(self, mock_connect, mock_context):
+        config = self.config_object()
+        config['fulleriteHost'] = 'fullerite.local'
+        config['fulleriteCA'] = '/etc/fullerite/ca.pem'
+        config['fulleriteCert'] = '/etc/fullerite/client.pem'
+        config['fulleriteKey'] = '/etc/fullerite/client-key.pem'
+        c = Collector(config, [])
+
+        sock = c._connect()
+        mock_connect.assert_called_once_with(('fullerite.local', 0))
+        mock_context.assert_called_once_with(cafile='/etc/fullerite/ca.pem')
+        context = mock_context.return_value
+        context.load_cert_chain.assert_called_once_with(
+            '/etc/fullerite/client.pem', '/etc/fullerite/client-key.pem')
+        context.wrap_socket.assert_called_once_with(
+            mock_connect.return_value, server_hostname='fullerite.local')
+        self.assertEquals(sock, context.wrap_socket.return_value)
+
     def test_configure_collector(self):
         c = Collector(self.config_object(), [], configfile=self.configfile())
         self.assertEquals(c.config, {
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fullerite/collector"
+	"fullerite/config"
+	"fullerite/handler"
+	"fullerite/internalserver"
+	"fullerite/metric"
+	"fullerite/processor"
+	"fullerite/stats"
+
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// agent keeps track of the collectors and handlers fullerite runs, so that a
+// changed configuration can be applied without restarting the process.
+type agent struct {
+	lock       sync.Mutex
+	configFile string
+	config     config.Config
+
+	collectors map[string]runningCollector
+	handlers   map[string]runningHandler
+	handlerSet *handlerSet
+	readers    *sync.WaitGroup
+}
+
+// runningCollector is a collector along with the settings it was started with
+type runningCollector struct {
+	collector collector.Collector
+	settings  collectorSettings
+	started   time.Time
+	// closed once the reader of the collector is done
+	reader <-chan struct{}
+}
+
+// collectorSettings is everything from the configuration a collector depends on
+type collectorSettings struct {
+	interval interface{}
+	config   map[string]interface{}
+}
+
+// runningHandler is a handler along with the settings it was started with
+type runningHandler struct {
+	handler  handler.Handler
+	settings handlerSettings
+	started  time.Time
+}
+
+// handlerSettings is everything from the configuration a handler depends on,
+// except for the collectors, the queues of which change while it runs
+type handlerSettings struct {
+	interval          interface{}
+	prefix            string
+	defaultDimensions map[string]string
+	config            map[string]interface{}
+}
+
+func newAgent(configFile string) *agent {
+	handlers := newHandlerSet(nil, nil)
+	handlers.limiter = newCardinalityLimiter()
+	return &agent{
+		configFile: configFile,
+		collectors: make(map[string]runningCollector),
+		handlers:   make(map[string]runningHandler),
+		handlerSet: handlers,
+		readers:    new(sync.WaitGroup),
+	}
+}
+
+func newCollectorSettings(c config.Config, instanceConfig map[string]interface{}) collectorSettings {
+	return collectorSettings{
+		interval: c.Interval,
+		config:   instanceConfig,
+	}
+}
+
+func newHandlerSettings(c config.Config, instanceConfig map[string]interface{}) handlerSettings {
+	return handlerSettings{
+		interval:          c.Interval,
+		prefix:            c.Prefix,
+		defaultDimensions: c.DefaultDimensions,
+		config:            instanceConfig,
+	}
+}
+
+// start reads the configuration and starts everything in it. Collectors
+// whose configuration cannot be read are skipped.
+func (a *agent) start() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	c, err := config.ReadConfig(a.configFile)
+	if err != nil {
+		return err
+	}
+
+	collectorConfigs := make(map[string]map[string]interface{})
+	for _, name := range c.Collectors {
+		conf, err := readCollectorConfig(c, name)
+		if err != nil {
+			log.Error("Collector config failed to load for: ", name)
+			continue
+		}
+		collectorConfigs[name] = conf
+	}
+
+	processors, err := processor.NewChain(c.Processors)
+	if err != nil {
+		log.Error("Ignoring the global processors: ", err)
+		processors = nil
+	}
+
+	if err := a.handlerSet.limiter.configure(c.Cardinality); err != nil {
+		log.Error("Cardinality limits not applied: ", err)
+	}
+
+	a.apply(c, collectorConfigs, processors)
+	return nil
+}
+
+// reload reads the configuration again and restarts the collectors and
+// handlers whose configuration changed, everything else keeps running
+// untouched. If any of the configuration files cannot be read nothing
+// changes at all.
+func (a *agent) reload() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	log.Info("Reloading the configuration from ", a.configFile)
+	err := a.reloadConfig()
+	a.reportReload(err)
+	return err
+}
+
+func (a *agent) reloadConfig() error {
+	c, err := config.ReadConfig(a.configFile)
+	if err != nil {
+		return err
+	}
+
+	collectorConfigs := make(map[string]map[string]interface{})
+	for _, name := range c.Collectors {
+		conf, err := readCollectorConfig(c, name)
+		if err != nil {
+			return fmt.Errorf("config of collector %s: %s", name, err)
+		}
+		collectorConfigs[name] = conf
+	}
+
+	processors, err := processor.NewChain(c.Processors)
+	if err != nil {
+		return err
+	}
+
+	// nothing else can fail from here on
+	if err := a.handlerSet.limiter.configure(c.Cardinality); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(a.config.InternalServerConfig, c.InternalServerConfig) {
+		log.Warn("Changes to the internal server only take effect after a restart")
+	}
+
+	a.apply(c, collectorConfigs, processors)
+	return nil
+}
+
+// apply makes the running collectors, handlers and processors match the
+// configuration. The handlers go first so that there is a queue for every new
+// collector by the time it starts to emit. The handlers get the queues of new
+// collectors right away, but keep those of removed ones until what the removed
+// collectors collected has been read. The caller must hold the lock.
+func (a *agent) apply(c config.Config,
+	collectorConfigs map[string]map[string]interface{},
+	processors *processor.Chain) {
+	handlers := make(map[string]runningHandler)
+	for name, instanceConfig := range c.Handlers {
+		settings := newHandlerSettings(c, instanceConfig)
+		if running, exists := a.handlers[name]; exists && reflect.DeepEqual(running.settings, settings) {
+			handlers[name] = running
+			continue
+		}
+
+		inst := startHandler(name, c, instanceConfig)
+		if inst != nil {
+			handlers[name] = runningHandler{handler: inst, settings: settings, started: time.Now()}
+		}
+	}
+
+	stale := []handler.Handler{}
+	for name, running := range a.handlers {
+		if handlers[name].handler != running.handler {
+			log.Info("Stopping handler ", name)
+			stale = append(stale, running.handler)
+		}
+	}
+
+	handlerList := make([]handler.Handler, 0, len(handlers))
+	for _, running := range handlers {
+		handlerList = append(handlerList, running.handler)
+	}
+	a.handlers = handlers
+	a.handlerSet.replace(handlerList, processors)
+	a.handlerSet.updateListeners(withCollectorsOf(c, a.config))
+	for _, h := range stale {
+		h.Stop()
+	}
+
+	collectors := make(map[string]runningCollector)
+	stopping := []collector.Collector{}
+	readers := []<-chan struct{}{}
+	for name, running := range a.collectors {
+		conf, exists := collectorConfigs[name]
+		if exists && reflect.DeepEqual(running.settings, newCollectorSettings(c, conf)) {
+			collectors[name] = running
+			continue
+		}
+		log.Info("Stopping collector ", name)
+		stopping = append(stopping, running.collector)
+		readers = append(readers, running.reader)
+	}
+
+	// the readers of the stopped collectors return once their channels
+	// are drained, only then are the queues of removed collectors retired
+	timeout := time.Duration(config.GetAsInt(c.ShutdownTimeout, defaultShutdownTimeout)) * time.Second
+	stopCollectors(stopping, timeout)
+	stopReaders(stopping)
+	if !waitForReaders(readers, timeout) {
+		log.Warn("The stopped collectors were not read within ", timeout, ", their last metrics may be dropped")
+	}
+	a.handlerSet.updateListeners(c)
+
+	for _, name := range c.Collectors {
+		conf, exists := collectorConfigs[name]
+		if _, running := collectors[name]; running || !exists {
+			continue
+		}
+
+		inst := startCollector(name, c, conf)
+		if inst != nil {
+			collectors[name] = runningCollector{
+				collector: inst,
+				settings:  newCollectorSettings(c, conf),
+				started:   time.Now(),
+				reader:    startReader(a.readers, inst, a.handlerSet),
+			}
+		}
+	}
+
+	a.collectors = collectors
+	a.config = c
+}
+
+// withCollectorsOf returns the config with the collectors of the previous
+// config added, so that no collector queue is retired yet
+func withCollectorsOf(c config.Config, previous config.Config) config.Config {
+	union := c
+	union.Collectors = appendMissing(append([]string{}, c.Collectors...), previous.Collectors)
+	union.DiamondCollectors = appendMissing(append([]string{}, c.DiamondCollectors...), previous.DiamondCollectors)
+	return union
+}
+
+func appendMissing(names []string, more []string) []string {
+	known := make(map[string]bool)
+	for _, name := range names {
+		known[name] = true
+	}
+	for _, name := range more {
+		if !known[name] {
+			names = append(names, name)
+			known[name] = true
+		}
+	}
+	return names
+}
+
+// waitForReaders waits until the readers are done, it returns false if
+// that took longer than the timeout
+func waitForReaders(readers []<-chan struct{}, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for _, done := range readers {
+		select {
+		case <-done:
+		case <-deadline:
+			return false
+		}
+	}
+	return true
+}
+
+// status returns the health of the running handlers and collectors for the
+// health checks of the internal server
+func (a *agent) status() internalserver.Status {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	status := internalserver.Status{
+		Handlers:   make(map[string]internalserver.HandlerStatus),
+		Collectors: make(map[string]internalserver.CollectorStatus),
+	}
+	for name, running := range a.handlers {
+		status.Handlers[name] = internalserver.HandlerStatus{
+			Health:  running.handler.Health(),
+			Started: running.started,
+		}
+	}
+
+	collectorStats := stats.Default.Collectors()
+	for name, running := range a.collectors {
+		s := internalserver.CollectorStatus{
+			Started:     running.started,
+			Interval:    running.collector.Interval(),
+			Listener:    running.collector.CollectorType() == "listener",
+			LastSuccess: collectorStats[running.collector.CanonicalName()].LastSuccess,
+		}
+		if listener, ok := running.collector.(collector.Listener); ok {
+			listening := listener.Listening()
+			s.Listening = &listening
+		}
+		if control, exists := collectorControlOf(running.collector); exists {
+			s.Schedule = control.sched.next
+			s.Paused = control.isPaused()
+		}
+		status.Collectors[name] = s
+	}
+	return status
+}
+
+// reportReload sends the outcome of a reload to the handlers, through the
+// queue of fullerite's own metrics like any other metric
+func (a *agent) reportReload(err error) {
+	m := metric.New("fullerite.config_reload")
+	m.Value = 1
+	if err != nil {
+		log.Error("Failed to reload the configuration: ", err)
+		m.AddDimension("status", "failure")
+	} else {
+		log.Info("Reloaded the configuration")
+		m.AddDimension("status", "success")
+	}
+	go a.handlerSet.enqueue(handler.InternalCollector, m)
+}
+
+// shutdown stops everything the agent runs, see shutdown()
+func (a *agent) shutdown() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	collectors := make([]collector.Collector, 0, len(a.collectors))
+	for _, running := range a.collectors {
+		collectors = append(collectors, running.collector)
+	}
+
+	timeout := time.Duration(config.GetAsInt(a.config.ShutdownTimeout, defaultShutdownTimeout)) * time.Second
+	return shutdown(collectors, a.handlerSet.Handlers(), a.readers, timeout)
+}
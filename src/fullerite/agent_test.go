@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAgentConfiguration = `{
+    "prefix": "test.",
+    "interval": 10,
+    "collectorsConfigPath": "%s",
+    "collectors": ["Test"],
+    "handlers": {
+        "Log": {}
+    }
+}
+`
+
+func writeTestFile(t *testing.T, path, contents string) {
+	require.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+}
+
+func newTestAgent(t *testing.T) (*agent, string) {
+	dir, err := ioutil.TempDir("", "fullerite")
+	require.Nil(t, err)
+
+	writeTestFile(t, filepath.Join(dir, "fullerite.conf"), fmt.Sprintf(testAgentConfiguration, dir))
+	writeTestFile(t, filepath.Join(dir, "Test.conf"), `{"metricName": "TestMetric"}`)
+
+	a := newAgent(filepath.Join(dir, "fullerite.conf"))
+	require.Nil(t, a.start())
+	return a, dir
+}
+
+func TestAgentReloadUnchanged(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+
+	col := a.collectors["Test"].collector
+	h := a.handlers["Log"].handler
+
+	assert.Nil(t, a.reload())
+	assert.True(t, col == a.collectors["Test"].collector, "should keep the collector running")
+	assert.True(t, h == a.handlers["Log"].handler, "should keep the handler running")
+	assert.True(t, a.shutdown())
+}
+
+func TestAgentReloadChangedCollector(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+
+	col := a.collectors["Test"].collector
+	h := a.handlers["Log"].handler
+
+	writeTestFile(t, filepath.Join(dir, "Test.conf"), `{"metricName": "OtherMetric"}`)
+	assert.Nil(t, a.reload())
+
+	assert.False(t, col == a.collectors["Test"].collector, "should restart the collector")
+	assert.True(t, h == a.handlers["Log"].handler, "should keep the handler running")
+	select {
+	case <-col.Stopping():
+	default:
+		t.Fatal("should have stopped the old collector")
+	}
+	assert.True(t, a.shutdown())
+}
+
+func TestAgentReloadChangedHandlers(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+
+	col := a.collectors["Test"].collector
+	h := a.handlers["Log"].handler
+
+	conf := `{
+	    "interval": 10,
+	    "collectorsConfigPath": "%s",
+	    "collectors": ["Test"],
+	    "handlers": {
+	        "Log": {"max_buffer_size": 10},
+	        "Graphite": {"server": "localhost", "port": "2003"}
+	    }
+	}`
+	writeTestFile(t, filepath.Join(dir, "fullerite.conf"), fmt.Sprintf(conf, dir))
+	assert.Nil(t, a.reload())
+
+	assert.True(t, col == a.collectors["Test"].collector, "should keep the collector running")
+	assert.False(t, h == a.handlers["Log"].handler, "should restart the changed handler")
+	assert.Contains(t, a.handlers, "Graphite")
+	assert.Equal(t, 2, len(a.handlerSet.Handlers()))
+	assert.True(t, a.shutdown())
+}
+
+func TestAgentReloadRemovesCollector(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+
+	col := a.collectors["Test"].collector
+	h := a.handlers["Log"].handler
+	require.Contains(t, h.CollectorChannels(), "Test")
+
+	conf := `{"prefix": "test.", "interval": 10, "collectorsConfigPath": "%s", "collectors": [], "handlers": {"Log": {}}}`
+	writeTestFile(t, filepath.Join(dir, "fullerite.conf"), fmt.Sprintf(conf, dir))
+	assert.Nil(t, a.reload())
+
+	assert.Empty(t, a.collectors)
+	assert.NotPanics(t, func() {
+		select {
+		case col.Channel() <- metric.New("late"):
+		default:
+		}
+	}, "should not have closed the channel of the removed collector")
+	assert.True(t, h == a.handlers["Log"].handler, "should keep the handler running")
+	assert.NotContains(t, h.CollectorChannels(), "Test")
+	assert.Equal(t, 0.0, h.InternalMetrics().Counters["metricsDropped"])
+
+	writeTestFile(t, filepath.Join(dir, "fullerite.conf"), fmt.Sprintf(testAgentConfiguration, dir))
+	assert.Nil(t, a.reload())
+	assert.True(t, h == a.handlers["Log"].handler, "should keep the handler running")
+	assert.Contains(t, h.CollectorChannels(), "Test")
+	assert.True(t, a.shutdown())
+}
+
+func TestWithCollectorsOf(t *testing.T) {
+	c := config.Config{Collectors: []string{"Test", "CPU"}, DiamondCollectors: []string{"Diamond"}}
+	previous := config.Config{Collectors: []string{"Memory", "Test"}}
+
+	union := withCollectorsOf(c, previous)
+	assert.Equal(t, []string{"Test", "CPU", "Memory"}, union.Collectors)
+	assert.Equal(t, []string{"Diamond"}, union.DiamondCollectors)
+	assert.Equal(t, []string{"Test", "CPU"}, c.Collectors, "should leave the config alone")
+}
+
+func TestAgentReloadInvalidConfig(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+
+	col := a.collectors["Test"].collector
+	h := a.handlers["Log"].handler
+
+	writeTestFile(t, filepath.Join(dir, "Test.conf"), `{not json`)
+	assert.NotNil(t, a.reload())
+
+	assert.True(t, col == a.collectors["Test"].collector, "should leave the collector alone")
+	assert.True(t, h == a.handlers["Log"].handler, "should leave the handler alone")
+	assert.True(t, a.shutdown())
+}
+
+func TestAgentStatus(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+
+	status := a.status()
+	require.Contains(t, status.Handlers, "Log")
+	require.Contains(t, status.Collectors, "Test")
+	assert.False(t, status.Handlers["Log"].Started.IsZero())
+	assert.Equal(t, 10, status.Collectors["Test"].Interval)
+	assert.False(t, status.Collectors["Test"].Listener)
+	assert.Nil(t, status.Collectors["Test"].Listening, "should only be set for collectors with a socket")
+	assert.NotNil(t, status.Collectors["Test"].Schedule, "should be due on the schedule of the collector")
+	assert.False(t, status.Collectors["Test"].Paused)
+	assert.True(t, a.shutdown())
+}
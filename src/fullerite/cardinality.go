@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Settings of the series cardinality limiter, configured in fullerite.conf as
+//
+//	"cardinality": {
+//		"maxSeriesPerCollector": 10000,
+//		"maxSeriesPerMetric": 1000,
+//		"action": "collapse",
+//		"dimensions": ["container_id", "pid"],
+//		"window": 3600
+//	}
+//
+// Once a metric name or a collector has too many distinct series, new series
+// lose the offending dimensions, the ones with the most distinct values for
+// that metric name, one after the other until what is left is a known
+// series. The "drop" action removes them, "collapse" replaces their values
+// with "other". Only the listed dimensions are considered if "dimensions" is
+// set. A metric name may start one series past the ceilings: with "drop" the
+// first series that is still new without its most offending dimension, with
+// "collapse" the one its series collapse into once every dimension was given
+// up. Other new series that do not end up as a known one are dropped. The
+// series are counted from scratch every window.
+const (
+	cardinalityDrop     = "drop"
+	cardinalityCollapse = "collapse"
+
+	defaultCardinalityAction = cardinalityCollapse
+	defaultCardinalityWindow = 3600 // seconds
+
+	collapsedDimensionValue = "other"
+)
+
+// cardinalityLimiter counts the distinct series per collector and per metric
+// name and enforces the ceilings on them
+type cardinalityLimiter struct {
+	lock sync.Mutex
+
+	enabled         bool
+	maxPerCollector int
+	maxPerMetric    int
+	action          string
+	dimensions      map[string]bool
+	window          time.Duration
+
+	windowStart time.Time
+	collectors  map[string]*collectorSeries
+}
+
+type collectorSeries struct {
+	series  int
+	metrics map[string]*metricSeries
+}
+
+type metricSeries struct {
+	keys       map[string]bool
+	dimensions map[string]map[string]bool
+	warned     bool
+	// overflowed is set once the series past the ceilings was started
+	overflowed bool
+}
+
+func newCardinalityLimiter() *cardinalityLimiter {
+	return &cardinalityLimiter{
+		windowStart: time.Now(),
+		collectors:  make(map[string]*collectorSeries),
+	}
+}
+
+// configure applies the "cardinality" part of the configuration. The series
+// counted so far are kept. Without a configuration nothing is tracked.
+func (cl *cardinalityLimiter) configure(conf map[string]interface{}) error {
+	action := defaultCardinalityAction
+	if value, exists := conf["action"]; exists {
+		action, _ = value.(string)
+	}
+	if action != cardinalityDrop && action != cardinalityCollapse {
+		return fmt.Errorf("cardinality action must be %s or %s, got %v",
+			cardinalityDrop, cardinalityCollapse, conf["action"])
+	}
+
+	var dimensions map[string]bool
+	if value, exists := conf["dimensions"]; exists {
+		dimensions = make(map[string]bool)
+		for _, name := range config.GetAsSlice(value) {
+			dimensions[name] = true
+		}
+	}
+
+	window := config.GetAsInt(conf["window"], defaultCardinalityWindow)
+	if window <= 0 {
+		return fmt.Errorf("cardinality window must be positive, got %v", conf["window"])
+	}
+
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	cl.enabled = conf != nil
+	cl.maxPerCollector = config.GetAsInt(conf["maxSeriesPerCollector"], 0)
+	cl.maxPerMetric = config.GetAsInt(conf["maxSeriesPerMetric"], 0)
+	cl.action = action
+	cl.dimensions = dimensions
+	cl.window = time.Duration(window) * time.Second
+	if !cl.enabled {
+		cl.collectors = make(map[string]*collectorSeries)
+	}
+	return nil
+}
+
+// limit checks a metric of the named collector against the ceilings and
+// takes the offending dimensions out if it would start a series too many. It
+// returns false if the metric has to be dropped, and a warning metric the
+// first time a metric name hits a ceiling within the window.
+func (cl *cardinalityLimiter) limit(collectorName string, m *metric.Metric) (bool, *metric.Metric) {
+	if cl == nil {
+		return true, nil
+	}
+
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	if !cl.enabled {
+		return true, nil
+	}
+	if time.Since(cl.windowStart) > cl.window {
+		cl.collectors = make(map[string]*collectorSeries)
+		cl.windowStart = time.Now()
+	}
+
+	cs, exists := cl.collectors[collectorName]
+	if !exists {
+		cs = &collectorSeries{metrics: make(map[string]*metricSeries)}
+		cl.collectors[collectorName] = cs
+	}
+	ms, exists := cs.metrics[m.Name]
+	if !exists {
+		ms = &metricSeries{
+			keys:       make(map[string]bool),
+			dimensions: make(map[string]map[string]bool),
+		}
+		cs.metrics[m.Name] = ms
+	}
+
+	key := m.SeriesKey()
+	if ms.keys[key] {
+		return true, nil
+	}
+	if (cl.maxPerMetric <= 0 || len(ms.keys) < cl.maxPerMetric) &&
+		(cl.maxPerCollector <= 0 || cs.series < cl.maxPerCollector) {
+		cl.track(cs, ms, key, m)
+		return true, nil
+	}
+
+	var warning *metric.Metric
+	if !ms.warned {
+		ms.warned = true
+		w := metric.WithValue("fullerite.cardinality_limit_exceeded", float64(len(ms.keys)))
+		w.AddDimension("collector", collectorName)
+		w.AddDimension("metric", m.Name)
+		warning = &w
+		log.Warn("Too many series for ", m.Name, " of ", collectorName, ", limiting its dimensions")
+	}
+
+	// the dimensions may be shared with other metrics of the collector
+	limited := *m
+	limited.Dimensions = make(map[string]string, len(m.Dimensions))
+	for k, v := range m.Dimensions {
+		limited.Dimensions[k] = v
+	}
+
+	// more than one dimension may be unbounded, a series that is still new
+	// once there is nothing left to give up would be one too many
+	collapsed := false
+	for offender := cl.offendingDimension(ms, &limited); offender != ""; offender = cl.offendingDimension(ms, &limited) {
+		if cl.action == cardinalityCollapse {
+			limited.AddDimension(offender, collapsedDimensionValue)
+			collapsed = true
+		} else {
+			limited.RemoveDimension(offender)
+		}
+		if ms.keys[limited.SeriesKey()] {
+			*m = limited
+			return true, warning
+		}
+		// every earlier series had the offending dimension, so what is
+		// left without it is new the first time
+		if cl.action == cardinalityDrop && !ms.overflowed {
+			cl.overflow(cs, ms, m, &limited)
+			return true, warning
+		}
+	}
+
+	// without a series to collapse into, collapsing would drop as well
+	if collapsed && !ms.overflowed {
+		cl.overflow(cs, ms, m, &limited)
+		return true, warning
+	}
+	return false, warning
+}
+
+// overflow tracks the limited metric as the one series of its name past the
+// ceilings and hands it on in place of the metric. The caller must hold the
+// lock.
+func (cl *cardinalityLimiter) overflow(cs *collectorSeries, ms *metricSeries, m *metric.Metric, limited *metric.Metric) {
+	ms.overflowed = true
+	cl.track(cs, ms, limited.SeriesKey(), limited)
+	*m = *limited
+}
+
+// track counts a new series. The caller must hold the lock.
+func (cl *cardinalityLimiter) track(cs *collectorSeries, ms *metricSeries, key string, m *metric.Metric) {
+	ms.keys[key] = true
+	cs.series++
+	for name, value := range m.Dimensions {
+		if ms.dimensions[name] == nil {
+			ms.dimensions[name] = make(map[string]bool)
+		}
+		ms.dimensions[name][value] = true
+	}
+}
+
+// offendingDimension picks the dimension of the metric with the most distinct
+// values for its name, counting the value of the metric itself. The caller
+// must hold the lock.
+func (cl *cardinalityLimiter) offendingDimension(ms *metricSeries, m *metric.Metric) string {
+	offender := ""
+	most := -1
+	for name, value := range m.Dimensions {
+		if name == "collector" || value == collapsedDimensionValue {
+			continue
+		}
+		if len(cl.dimensions) > 0 && !cl.dimensions[name] {
+			continue
+		}
+		count := len(ms.dimensions[name])
+		if !ms.dimensions[name][value] {
+			count++
+		}
+		if count > most || (count == most && name < offender) {
+			offender = name
+			most = count
+		}
+	}
+	return offender
+}
+
+// topN returns the n metric names with the most series
+func (cl *cardinalityLimiter) topN(n int) []metric.SeriesCount {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	counts := []metric.SeriesCount{}
+	for collectorName, cs := range cl.collectors {
+		for name, ms := range cs.metrics {
+			dimensions := make(map[string]int, len(ms.dimensions))
+			for dim, values := range ms.dimensions {
+				dimensions[dim] = len(values)
+			}
+			counts = append(counts, metric.SeriesCount{
+				Collector:  collectorName,
+				Name:       name,
+				Series:     len(ms.keys),
+				Dimensions: dimensions,
+			})
+		}
+	}
+
+	sort.Sort(bySeries(counts))
+	if n >= 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+type bySeries []metric.SeriesCount
+
+func (b bySeries) Len() int      { return len(b) }
+func (b bySeries) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bySeries) Less(i, j int) bool {
+	if b[i].Series != b[j].Series {
+		return b[i].Series > b[j].Series
+	}
+	if b[i].Collector != b[j].Collector {
+		return b[i].Collector < b[j].Collector
+	}
+	return b[i].Name < b[j].Name
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fullerite/metric"
+
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(t *testing.T, conf map[string]interface{}) *cardinalityLimiter {
+	cl := newCardinalityLimiter()
+	assert.Nil(t, cl.configure(conf))
+	return cl
+}
+
+func metricWithPid(name string, pid int) metric.Metric {
+	m := metric.New(name)
+	m.AddDimension("host", "box1")
+	m.AddDimension("pid", fmt.Sprint(pid))
+	return m
+}
+
+func TestCardinalityLimiterDisabled(t *testing.T) {
+	cl := newTestLimiter(t, nil)
+
+	for i := 0; i < 10; i++ {
+		m := metricWithPid("rss", i)
+		keep, warning := cl.limit("ProcStatus", &m)
+		assert.True(t, keep)
+		assert.Nil(t, warning)
+	}
+	assert.Empty(t, cl.topN(10), "should not track anything")
+
+	var unset *cardinalityLimiter
+	m := metric.New("rss")
+	keep, _ := unset.limit("ProcStatus", &m)
+	assert.True(t, keep)
+}
+
+func TestCardinalityLimiterCollapse(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{
+		"maxSeriesPerMetric": 2,
+		"dimensions":         []interface{}{"pid"},
+	})
+
+	var warnings []*metric.Metric
+	var results []metric.Metric
+	for i := 0; i < 4; i++ {
+		m := metricWithPid("rss", i)
+		keep, warning := cl.limit("ProcStatus", &m)
+		assert.True(t, keep)
+		if warning != nil {
+			warnings = append(warnings, warning)
+		}
+		results = append(results, m)
+	}
+
+	assert.Equal(t, "0", results[0].Dimensions["pid"])
+	assert.Equal(t, "1", results[1].Dimensions["pid"])
+	assert.Equal(t, "other", results[2].Dimensions["pid"], "should collapse the offending dimension")
+	assert.Equal(t, "box1", results[2].Dimensions["host"])
+	assert.Equal(t, "other", results[3].Dimensions["pid"])
+	assert.Equal(t, 3, cl.topN(1)[0].Series, "should track the collapsed series past the ceiling")
+
+	assert.Equal(t, 1, len(warnings), "should only warn once per metric name")
+	assert.Equal(t, "fullerite.cardinality_limit_exceeded", warnings[0].Name)
+	assert.Equal(t, "ProcStatus", warnings[0].Dimensions["collector"])
+	assert.Equal(t, "rss", warnings[0].Dimensions["metric"])
+	assert.Equal(t, 2.0, warnings[0].Value)
+}
+
+func TestCardinalityLimiterDropDimension(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{
+		"maxSeriesPerMetric": 2,
+		"action":             "drop",
+	})
+
+	first := metric.New("rss")
+	first.AddDimension("host", "box1")
+	cl.limit("ProcStatus", &first)
+	other := metricWithPid("rss", 1)
+	cl.limit("ProcStatus", &other)
+
+	dimensions := map[string]string{"host": "box1", "pid": "2"}
+	second := metric.WithValue("rss", 1)
+	second.Dimensions = dimensions
+	keep, _ := cl.limit("ProcStatus", &second)
+
+	assert.True(t, keep)
+	assert.NotContains(t, second.Dimensions, "pid")
+	assert.Equal(t, "2", dimensions["pid"], "should not change dimensions shared with other metrics")
+}
+
+func TestCardinalityLimiterConfiguredDimensions(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{
+		"maxSeriesPerMetric": 1,
+		"dimensions":         []interface{}{"host"},
+	})
+
+	first := metricWithPid("rss", 2)
+	first.AddDimension("host", "other")
+	cl.limit("ProcStatus", &first)
+	second := metricWithPid("rss", 2)
+	keep, _ := cl.limit("ProcStatus", &second)
+
+	assert.True(t, keep)
+	assert.Equal(t, "other", second.Dimensions["host"], "should only give up the listed dimensions")
+	assert.Equal(t, "2", second.Dimensions["pid"])
+
+	third := metricWithPid("rss", 3)
+	keep, _ = cl.limit("ProcStatus", &third)
+	assert.True(t, keep, "should start the collapsed series past the ceiling")
+	assert.Equal(t, "other", third.Dimensions["host"])
+
+	fourth := metricWithPid("rss", 4)
+	keep, _ = cl.limit("ProcStatus", &fourth)
+	assert.False(t, keep, "should not start more series with the other dimensions")
+}
+
+func TestCardinalityLimiterSeveralUnboundedDimensions(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{"maxSeriesPerMetric": 2})
+
+	for i := 0; i < 10; i++ {
+		m := metricWithPid("latency", i)
+		m.AddDimension("request_id", fmt.Sprint("r", i))
+		keep, _ := cl.limit("Test", &m)
+		assert.True(t, keep)
+		if i >= 2 {
+			for _, name := range []string{"host", "pid", "request_id"} {
+				assert.Equal(t, "other", m.Dimensions[name], "should collapse every dimension that stays new")
+			}
+		}
+	}
+	assert.Equal(t, 3, cl.topN(1)[0].Series, "should only track the collapsed series past the ceiling")
+
+	cl = newTestLimiter(t, map[string]interface{}{"maxSeriesPerMetric": 2, "action": "drop"})
+	for i := 0; i < 10; i++ {
+		m := metricWithPid("latency", i)
+		m.AddDimension("request_id", fmt.Sprint("r", i))
+		keep, _ := cl.limit("Test", &m)
+		assert.Equal(t, i < 3, keep, "should drop series that stay new with every dimension given up")
+	}
+	assert.Equal(t, 3, cl.topN(1)[0].Series, "should only track the stripped series past the ceiling")
+}
+
+func TestCardinalityLimiterDropToNewSeries(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{
+		"maxSeriesPerMetric": 2,
+		"action":             "drop",
+	})
+
+	for i := 0; i < 5; i++ {
+		m := metric.New("memory")
+		m.AddDimension("host", "box1")
+		m.AddDimension("container_id", fmt.Sprint("c", i))
+		keep, _ := cl.limit("DockerStats", &m)
+		assert.True(t, keep, "should drop the offending dimension rather than the point")
+		if i >= 2 {
+			assert.NotContains(t, m.Dimensions, "container_id")
+			assert.Equal(t, "box1", m.Dimensions["host"], "should keep the dimensions that do not offend")
+		}
+	}
+	assert.Equal(t, 3, cl.topN(1)[0].Series)
+}
+
+func TestCardinalityLimiterDimensionsMatchExactly(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{
+		"maxSeriesPerMetric": 1,
+		"dimensions":         []interface{}{"pid"},
+	})
+
+	first := metric.New("rss")
+	first.AddDimension("rapid_id", "1")
+	cl.limit("ProcStatus", &first)
+	second := metric.New("rss")
+	second.AddDimension("rapid_id", "2")
+	keep, _ := cl.limit("ProcStatus", &second)
+
+	assert.False(t, keep, "a limit on pid should not give up rapid_id")
+	assert.Equal(t, "2", second.Dimensions["rapid_id"])
+}
+
+func TestCardinalityLimiterPerCollector(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{"maxSeriesPerCollector": 2})
+
+	for _, name := range []string{"a", "b"} {
+		m := metric.New(name)
+		keep, _ := cl.limit("Test", &m)
+		assert.True(t, keep)
+	}
+
+	m := metric.New("c")
+	keep, warning := cl.limit("Test", &m)
+	assert.False(t, keep, "should drop a new series without dimensions to give up")
+	assert.NotNil(t, warning)
+
+	m = metric.New("a")
+	keep, _ = cl.limit("Test", &m)
+	assert.True(t, keep, "should let known series through")
+
+	m = metric.New("c")
+	keep, _ = cl.limit("Other", &m)
+	assert.True(t, keep, "should count the series of every collector separately")
+}
+
+func TestCardinalityLimiterWindow(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{"maxSeriesPerMetric": 1})
+
+	first := metricWithPid("rss", 1)
+	cl.limit("ProcStatus", &first)
+	cl.windowStart = time.Now().Add(-2 * time.Hour)
+
+	second := metricWithPid("rss", 2)
+	cl.limit("ProcStatus", &second)
+	assert.Equal(t, "2", second.Dimensions["pid"], "should start counting from scratch")
+}
+
+func TestCardinalityLimiterTopN(t *testing.T) {
+	cl := newTestLimiter(t, map[string]interface{}{})
+
+	for i := 0; i < 3; i++ {
+		m := metricWithPid("rss", i)
+		cl.limit("ProcStatus", &m)
+	}
+	for i := 0; i < 2; i++ {
+		m := metricWithPid("cpu", i)
+		cl.limit("ProcStatus", &m)
+	}
+	m := metric.New("load")
+	cl.limit("Test", &m)
+
+	top := cl.topN(2)
+	assert.Equal(t, 2, len(top))
+	assert.Equal(t, metric.SeriesCount{
+		Collector:  "ProcStatus",
+		Name:       "rss",
+		Series:     3,
+		Dimensions: map[string]int{"host": 1, "pid": 3},
+	}, top[0])
+	assert.Equal(t, "cpu", top[1].Name)
+	assert.Equal(t, 3, len(cl.topN(10)))
+}
+
+func TestCardinalityLimiterInvalidConfig(t *testing.T) {
+	cl := newCardinalityLimiter()
+	assert.NotNil(t, cl.configure(map[string]interface{}{"action": "explode"}))
+	assert.NotNil(t, cl.configure(map[string]interface{}{"window": -1}))
+	assert.False(t, cl.enabled, "should not apply an invalid config")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fullerite/collector"
+	"fullerite/config"
+	"fullerite/handler"
+	"fullerite/internalserver"
+	"fullerite/processor"
+
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+)
+
+func checkConfig(ctx *cli.Context) {
+	initLogrus(ctx)
+
+	configFile := ctx.String("config")
+	if ctx.Bool("dump") {
+		dumpConfig(configFile)
+	}
+
+	problems := checkConfigFile(configFile)
+	for _, problem := range problems {
+		fmt.Println(config.RedactString(problem))
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Found %d problems in %s\n", len(problems), configFile)
+		os.Exit(1)
+	}
+	fmt.Println("Configuration in", configFile, "is valid")
+}
+
+// dumpConfig prints the configuration as fullerite sees it, with the included
+// files merged and the references expanded, but without the secrets
+func dumpConfig(configFile string) {
+	c, err := config.ReadConfig(configFile)
+	if err != nil {
+		return
+	}
+	dump, err := json.MarshalIndent(config.Redact(c), "", "  ")
+	if err != nil {
+		log.Error("Failed to dump the configuration: ", err)
+		return
+	}
+	fmt.Println(string(dump))
+}
+
+// checkConfigFile checks a fullerite configuration and the configs of its
+// collectors, every problem names the file or the part it was found in
+func checkConfigFile(configFile string) []string {
+	raw, err := config.ReadConfigMap(configFile)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", configFile, err)}
+	}
+
+	var problems []string
+	report := func(where string, errs ...error) {
+		for _, err := range errs {
+			problems = append(problems, fmt.Sprintf("%s: %s", where, err))
+		}
+	}
+
+	errs := config.CheckKeys(raw, config.GlobalKeys)
+	report(configFile, errs...)
+	c, err := config.ParseConfig(raw)
+	if err != nil {
+		// a value of the wrong type was reported already
+		if len(errs) == 0 {
+			report(configFile, err)
+		}
+		return problems
+	}
+
+	report(configFile+": internalServer", config.CheckKeys(c.InternalServerConfig, internalserver.ConfigKeys)...)
+	if _, err = processor.NewChain(c.Processors); err != nil {
+		report(configFile+": processors", err)
+	}
+	if err = newCardinalityLimiter().configure(c.Cardinality); err != nil {
+		report(configFile+": cardinality", err)
+	}
+
+	handlerNames := make([]string, 0, len(c.Handlers))
+	for name := range c.Handlers {
+		handlerNames = append(handlerNames, name)
+	}
+	sort.Strings(handlerNames)
+	for _, name := range handlerNames {
+		where := "handler " + name
+		if !isRegistered(name, handler.Names()) {
+			report(where, fmt.Errorf("unknown handler, expected one of %s", strings.Join(handler.Names(), ", ")))
+			continue
+		}
+		report(where, handler.CheckConfig(handler.New(name), c.Handlers[name])...)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range c.Collectors {
+		where := "collector " + name
+		if seen[name] {
+			report(where, fmt.Errorf("listed more than once"))
+			continue
+		}
+		seen[name] = true
+		if !isRegistered(name, collector.Names()) {
+			report(where, fmt.Errorf("unknown collector, expected one of %s", strings.Join(collector.Names(), ", ")))
+			continue
+		}
+
+		source := collectorConfigFile(c, name)
+		if _, exists := c.CollectorConfigs[name]; exists {
+			source = "collectorConfigs"
+		}
+		where = fmt.Sprintf("collector %s (%s)", name, source)
+		conf, err := readCollectorConfig(c, name)
+		if err != nil {
+			report(where, err)
+			continue
+		}
+		keys := append(collector.New(name).ConfigKeys(), scheduleKeys...)
+		errs := config.CheckKeys(conf, keys)
+		report(where, errs...)
+		if len(errs) > 0 {
+			continue
+		}
+
+		interval := config.GetAsInt(c.Interval, collector.DefaultCollectionInterval)
+		interval = config.GetAsInt(conf["interval"], interval)
+		if _, err = newSchedule(name, interval, conf); err != nil {
+			report(where, err)
+		}
+	}
+	return problems
+}
+
+// isRegistered tells whether the collector or handler of the given name is
+// one of the registered ones. Only the part before the first space counts,
+// the rest tells instances of the same collector apart.
+func isRegistered(name string, registered []string) bool {
+	realName := strings.Split(name, " ")[0]
+	for _, r := range registered {
+		if r == realName {
+			return true
+		}
+	}
+	return false
+}
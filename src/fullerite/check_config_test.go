@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCheckConfig(t *testing.T, conf string, collectorConfigs map[string]string) (string, string) {
+	dir, err := ioutil.TempDir("", "fullerite")
+	require.Nil(t, err)
+
+	configFile := filepath.Join(dir, "fullerite.conf")
+	writeTestFile(t, configFile, fmt.Sprintf(conf, dir))
+	for name, contents := range collectorConfigs {
+		writeTestFile(t, filepath.Join(dir, name+".conf"), contents)
+	}
+	return configFile, dir
+}
+
+func TestCheckConfigValid(t *testing.T) {
+	configFile, dir := writeCheckConfig(t, `{
+		"interval": 10,
+		"collectorsConfigPath": "%s",
+		"collectors": ["Test", "Test second"],
+		"internalServer": {"port": "29090"},
+		"cardinality": {"maxSeriesPerMetric": 100},
+		"collectorConfigs": {"ProcStatus": {"pattern": "^fullerite$"}},
+		"handlers": {
+			"Graphite": {"server": "localhost", "port": 2003, "overflowPolicy": "drop-oldest"},
+			"Log": {"collectorWhiteList": ["Test"]}
+		}
+	}`, map[string]string{
+		"Test":        `{"metricName": "TestMetric", "interval": "5", "align": true}`,
+		"Test_second": `{"schedule": "*/5 * * * *"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	assert.Empty(t, checkConfigFile(configFile))
+}
+
+func TestCheckConfigProblems(t *testing.T) {
+	configFile, dir := writeCheckConfig(t, `{
+		"collectorsConfigPath": "%s",
+		"collectors": ["Test", "CPUInfo", "Nope", "Test"],
+		"diamondPort": 19191,
+		"cardinality": {"action": "explode"},
+		"handlers": {
+			"Graphite": {"server": "localhost", "overflowPolicy": "spill"},
+			"Nope": {}
+		}
+	}`, map[string]string{
+		"Test": `{"metricName": 1, "splay": 5}`,
+	})
+	defer os.RemoveAll(dir)
+
+	problems := checkConfigFile(configFile)
+	require.Equal(t, 9, len(problems), "%v", problems)
+	assert.Equal(t, configFile+`: unknown key "diamondPort"`, problems[0])
+	assert.Equal(t, configFile+": cardinality: cardinality action must be drop or collapse, got explode", problems[1])
+	assert.Equal(t, `handler Graphite: missing required key "port"`, problems[2])
+	assert.Equal(t, `handler Graphite: unknown overflowPolicy "spill"`, problems[3])
+	assert.True(t, strings.HasPrefix(problems[4], "handler Nope: unknown handler, expected one of Datadog, Graphite"), problems[4])
+	assert.Equal(t, "collector Test ("+filepath.Join(dir, "Test.conf")+`): "metricName" must be of type string, got 1`, problems[5])
+	assert.True(t, strings.HasPrefix(problems[6], "collector CPUInfo ("+filepath.Join(dir, "CPUInfo.conf")+"): open "), problems[6])
+	assert.True(t, strings.HasPrefix(problems[7], "collector Nope: unknown collector, expected one of AdHoc, CPUInfo"), problems[7])
+	assert.Equal(t, "collector Test: listed more than once", problems[8])
+}
+
+func TestCheckConfigMisspelledNames(t *testing.T) {
+	configFile, dir := writeCheckConfig(t, `{
+		"collectorsConfigPath": "%s",
+		"collectors": ["Diamondz", "Test second"],
+		"handlers": {"MyGraphite": {}}
+	}`, map[string]string{
+		"Test_second": `{}`,
+	})
+	defer os.RemoveAll(dir)
+
+	problems := checkConfigFile(configFile)
+	require.Equal(t, 2, len(problems), "%v", problems)
+	assert.True(t, strings.HasPrefix(problems[0], "handler MyGraphite: unknown handler"), problems[0])
+	assert.True(t, strings.HasPrefix(problems[1], "collector Diamondz: unknown collector"), problems[1])
+}
+
+func TestCheckConfigSchedule(t *testing.T) {
+	configFile, dir := writeCheckConfig(t, `{
+		"collectorsConfigPath": "%s",
+		"collectors": ["Test"]
+	}`, map[string]string{
+		"Test": `{"schedule": "* * *"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	problems := checkConfigFile(configFile)
+	require.Equal(t, 1, len(problems), "%v", problems)
+	assert.Contains(t, problems[0], `cron expression "* * *" must have 5 fields`)
+}
+
+func TestCheckConfigInvalidJSON(t *testing.T) {
+	configFile, dir := writeCheckConfig(t, `{"collectorsConfigPath": "%s",}`, nil)
+	defer os.RemoveAll(dir)
+
+	problems := checkConfigFile(configFile)
+	assert.Equal(t, 1, len(problems))
+	assert.Contains(t, problems[0], "invalid character")
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fullerite/collector"
+	"fullerite/config"
+	"fullerite/handler"
+	"fullerite/metric"
+	"fullerite/processor"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+)
+
+// The output formats of collect-once
+const (
+	formatJSON     = "json"
+	formatGraphite = "graphite"
+	formatTable    = "table"
+)
+
+// collectQuietPeriod is how long to wait for more metrics once Collect()
+// returned, for collectors that emit from goroutines of their own
+const collectQuietPeriod = 500 * time.Millisecond
+
+func collectOnceCommand(ctx *cli.Context) {
+	initLogrus(ctx)
+	// keep stdout for the metrics
+	logrus.SetOutput(os.Stderr)
+
+	if len(ctx.Args()) != 1 {
+		log.Error("You need to name a collector to run, see 'fullerite help collect-once'")
+		os.Exit(1)
+	}
+	name := ctx.Args()[0]
+
+	format := ctx.String("format")
+	if format != formatJSON && format != formatGraphite && format != formatTable {
+		log.Error("Unknown format ", format, ", expected ", formatJSON, ", ", formatGraphite, " or ", formatTable)
+		os.Exit(1)
+	}
+
+	c, conf, err := collectOnceConfig(name, ctx.String("config"), ctx.String("collector-config"))
+	if err != nil {
+		os.Exit(1)
+	}
+	processors, err := processor.NewChain(c.Processors)
+	if err != nil {
+		log.Error(err)
+	}
+
+	inst := collector.New(name)
+	if inst == nil {
+		os.Exit(1)
+	}
+	if inst.CollectorType() == "listener" {
+		log.Error(name, " is a listener collector, it cannot be run once")
+		os.Exit(1)
+	}
+	inst.SetInterval(config.GetAsInt(c.Interval, collector.DefaultCollectionInterval))
+	inst.Configure(conf)
+
+	times := ctx.Int("times")
+	for run := 1; run <= times; run++ {
+		if run > 1 {
+			time.Sleep(time.Duration(inst.Interval()) * time.Second)
+		}
+		metrics := collectMetrics(inst, processors)
+		if times > 1 && format != formatJSON {
+			fmt.Printf("# run %d of %d\n", run, times)
+		}
+		writeMetrics(os.Stdout, format, metrics)
+	}
+}
+
+// collectOnceConfig reads the global config if there is one and the config
+// of the collector, from the given file or wherever fullerite would find it
+func collectOnceConfig(name, configFile, collectorConfigFile string) (config.Config, map[string]interface{}, error) {
+	var c config.Config
+	if _, err := os.Stat(configFile); err == nil || collectorConfigFile == "" {
+		var err error
+		if c, err = config.ReadConfig(configFile); err != nil {
+			return c, nil, err
+		}
+	}
+
+	if collectorConfigFile != "" {
+		conf, err := config.ReadCollectorConfig(collectorConfigFile)
+		return c, conf, err
+	}
+	conf, err := readCollectorConfig(c, name)
+	return c, conf, err
+}
+
+// collectMetrics runs the collector once and returns its metrics the way the
+// handlers would get them: prepared for the handlers, without blacklisted
+// metrics and after the global processors
+func collectMetrics(inst collector.Collector, processors *processor.Chain) []metric.Metric {
+	interval := time.Duration(inst.Interval()) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+	inst.SetContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inst.Collect()
+	}()
+
+	var metrics []metric.Metric
+	var quiet <-chan time.Time
+	for {
+		select {
+		case m := <-inst.Channel():
+			if _, keep := prepareMetric(inst, &m); !keep {
+				continue
+			}
+			if m, keep := processors.ProcessMetric(m); keep {
+				metrics = append(metrics, m)
+			}
+			if quiet != nil {
+				quiet = time.After(collectQuietPeriod)
+			}
+		case <-done:
+			done = nil
+			quiet = time.After(collectQuietPeriod)
+		case <-quiet:
+			return metrics
+		case <-ctx.Done():
+			log.Warn(inst.Name(), " collector took too long to run, printing what it collected")
+			return metrics
+		}
+	}
+}
+
+// writeMetrics prints metrics in one of the output formats, sorted by name
+// and dimensions
+func writeMetrics(w io.Writer, format string, metrics []metric.Metric) {
+	sort.Sort(byNameAndDimensions(metrics))
+
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		for _, m := range metrics {
+			encoder.Encode(m)
+		}
+	case formatGraphite:
+		for _, m := range metrics {
+			fmt.Fprint(w, handler.GraphiteDatapoint(m))
+		}
+	case formatTable:
+		table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "NAME\tTYPE\tVALUE\tDIMENSIONS")
+		for _, m := range metrics {
+			fmt.Fprintf(table, "%s\t%s\t%v\t%s\n", m.Name, m.MetricType, m.Value, formatDimensions(m.Dimensions))
+		}
+		table.Flush()
+	}
+}
+
+func formatDimensions(dimensions map[string]string) string {
+	pairs := make([]string, 0, len(dimensions))
+	for name, value := range dimensions {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+type byNameAndDimensions []metric.Metric
+
+func (b byNameAndDimensions) Len() int      { return len(b) }
+func (b byNameAndDimensions) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byNameAndDimensions) Less(i, j int) bool {
+	if b[i].Name != b[j].Name {
+		return b[i].Name < b[j].Name
+	}
+	return formatDimensions(b[i].Dimensions) < formatDimensions(b[j].Dimensions)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fullerite/collector"
+	"fullerite/metric"
+
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectOnce(t *testing.T) {
+	configFile, dir := writeCheckConfig(t, `{
+		"interval": 10,
+		"prefix": "global.",
+		"collectorsConfigPath": "%s",
+		"collectors": ["Test"],
+		"handlers": {"Log": {}}
+	}`, map[string]string{
+		"Test": `{"metricName": "TestMetric", "prefix": "test."}`,
+	})
+	defer os.RemoveAll(dir)
+
+	c, conf, err := collectOnceConfig("Test", configFile, "")
+	require.Nil(t, err)
+	assert.Equal(t, "test.", conf["prefix"])
+
+	inst := collector.New("Test")
+	inst.SetInterval(5)
+	inst.Configure(conf)
+	metrics := collectMetrics(inst, nil)
+
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "test.TestMetric", metrics[0].Name)
+	assert.Equal(t, "Test", metrics[0].Dimensions["collector"])
+	assert.Equal(t, "global.", c.Prefix, "the global prefix is added by the handlers")
+
+	otherFile := filepath.Join(dir, "other.conf")
+	writeTestFile(t, otherFile, `{"metricName": "TestMetric", "metrics_blacklist": ["TestMetric"]}`)
+	_, conf, err = collectOnceConfig("Test", filepath.Join(dir, "missing.conf"), otherFile)
+	require.Nil(t, err)
+	inst.Configure(conf)
+	assert.Empty(t, collectMetrics(inst, nil), "blacklisted metrics should not be printed")
+}
+
+func TestWriteMetrics(t *testing.T) {
+	second := metric.New("b.metric")
+	second.Value = 2
+	second.AddDimensions(map[string]string{"zone": "west", "app": "web"})
+	first := metric.New("a.metric")
+	first.Value = 1.5
+	first.MetricType = metric.Counter
+	metrics := []metric.Metric{second, first}
+
+	var out bytes.Buffer
+	writeMetrics(&out, formatTable, metrics)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"NAME", "TYPE", "VALUE", "DIMENSIONS"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"a.metric", "counter", "1.5"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"b.metric", "gauge", "2", "app=web,zone=west"}, strings.Fields(lines[2]))
+
+	out.Reset()
+	writeMetrics(&out, formatJSON, metrics)
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"name":"a.metric"`)
+
+	out.Reset()
+	writeMetrics(&out, formatGraphite, metrics)
+	assert.True(t, strings.HasPrefix(out.String(), "a_metric 1.500000 "), out.String())
+	assert.Contains(t, out.String(), "b_metric.app.web.zone.west 2.000000 ")
+}
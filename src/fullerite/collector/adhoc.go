@@ -7,6 +7,7 @@ import (
 	"os/user"
 
 	"encoding/json"
+	"fullerite/config"
 	"fullerite/metric"
 
 	l "github.com/Sirupsen/logrus"
@@ -37,6 +38,25 @@ func newAdHoc(channel chan metric.Metric, initialInterval int, log *l.Entry) Col
 	return a
 }
 
+// ConfigKeys describes the keys of the AdHoc config
+func (a *AdHoc) ConfigKeys() []config.Key {
+	return append(a.baseCollector.ConfigKeys(),
+		config.Key{Name: "collectorFile", Type: config.TypeString, Description: "executable that prints the metrics as JSON"},
+	)
+}
+
+// Description of the AdHoc collector
+func (a *AdHoc) Description() string {
+	return "Runs an executable and emits the metrics it prints as JSON"
+}
+
+// Metrics the AdHoc collector emits
+func (a *AdHoc) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "adhoc.<user>.<name>", Description: "the metrics the executable prints, with the type it gives"},
+	}
+}
+
 // Configure Override default parameters
 func (a *AdHoc) Configure(configMap map[string]interface{}) {
 	if collectorFile, exists := configMap["collectorFile"]; exists {
@@ -48,9 +68,9 @@ func (a *AdHoc) Configure(configMap map[string]interface{}) {
 }
 
 // Collect Emits the metrics produce by the AdHoc script
-func (a AdHoc) Collect() {
+func (a *AdHoc) Collect() {
 	a.log.Info("Collecting...")
-	cmd := exec.Command(a.collectorFile, []string{""}...)
+	cmd := exec.CommandContext(a.Context(), a.collectorFile, []string{""}...)
 	output, err := cmd.Output()
 	if err != nil {
 		a.log.Error("Could not run command: ", err)
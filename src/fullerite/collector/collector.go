@@ -4,7 +4,10 @@ import (
 	"fullerite/config"
 	"fullerite/metric"
 
+	"context"
+	"sort"
 	"strings"
+	"sync"
 
 	l "github.com/Sirupsen/logrus"
 )
@@ -34,6 +37,33 @@ type Collector interface {
 	SetPrefix(string)
 	Blacklist() []string
 	SetBlacklist([]string)
+
+	// ConfigKeys describes the keys of the collector config, collectors
+	// with keys of their own add them to the common ones
+	ConfigKeys() []config.Key
+	// Description says what the collector collects, in one sentence
+	Description() string
+	// Metrics describes the metrics the collector emits
+	Metrics() []metric.Info
+
+	// Stop asks the collector to stop. Listener collectors override it to
+	// release their sockets, their Collect() returns once it is called.
+	Stop()
+	// Stopping is closed once Stop has been called
+	Stopping() <-chan struct{}
+
+	// Context is done once the run in progress is past its deadline.
+	// Collectors hand it to the requests and commands they make, so
+	// that those are aborted.
+	Context() context.Context
+	// SetContext sets the context of the next run
+	SetContext(context.Context)
+}
+
+// Listener is implemented by the listener collectors that bind a socket
+type Listener interface {
+	// Listening tells whether the socket is bound
+	Listening() bool
 }
 
 var collectorConstructs map[string]func(chan metric.Metric, int, *l.Entry) Collector
@@ -46,6 +76,16 @@ func RegisterCollector(name string, f func(chan metric.Metric, int, *l.Entry) Co
 	collectorConstructs[name] = f
 }
 
+// Names returns the names of the registered collectors, sorted
+func Names() []string {
+	names := make([]string, 0, len(collectorConstructs))
+	for name := range collectorConstructs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // New creates a new Collector based on the requested collector name.
 func New(name string) Collector {
 	var collector Collector
@@ -71,6 +111,15 @@ func New(name string) Collector {
 	return collector
 }
 
+// stopSignal is shared between copies of a collector, so it is kept behind
+// a pointer that is created on first use
+type stopSignal struct {
+	once sync.Once
+	quit chan struct{}
+}
+
+var stopSignalLock sync.Mutex
+
 type baseCollector struct {
 	// fulfill most of the rote parts of the collector interface
 	channel       chan metric.Metric
@@ -80,6 +129,12 @@ type baseCollector struct {
 	canonicalName string
 	prefix        string
 	blacklist     []string
+	stop          *stopSignal
+
+	// the context of the run in progress, collectors read it from the
+	// goroutines they start while the next run sets it
+	ctxLock sync.Mutex
+	ctx     context.Context
 
 	// intentionally exported
 	log *l.Entry
@@ -102,6 +157,26 @@ func (col *baseCollector) configureCommonParams(configMap map[string]interface{}
 
 }
 
+// ConfigKeys : the keys every collector config may have
+func (col *baseCollector) ConfigKeys() []config.Key {
+	return []config.Key{
+		{Name: "interval", Type: config.TypeInt, Default: DefaultCollectionInterval,
+			Description: "seconds between collections, defaults to the global interval"},
+		{Name: "prefix", Type: config.TypeString, Description: "prefix of the names of the metrics"},
+		{Name: "metrics_blacklist", Type: config.TypeList, Description: "names of metrics not to emit"},
+	}
+}
+
+// Description : collectors override it
+func (col *baseCollector) Description() string {
+	return ""
+}
+
+// Metrics : collectors whose metrics are known override it
+func (col *baseCollector) Metrics() []metric.Info {
+	return nil
+}
+
 // SetInterval : set the interval to collect on
 func (col *baseCollector) SetInterval(interval int) {
 	col.interval = interval
@@ -143,22 +218,22 @@ func (col *baseCollector) Prefix() string {
 }
 
 // Channel : the channel on which the collector should send metrics
-func (col baseCollector) Channel() chan metric.Metric {
+func (col *baseCollector) Channel() chan metric.Metric {
 	return col.channel
 }
 
 // Name : the name of the collector
-func (col baseCollector) Name() string {
+func (col *baseCollector) Name() string {
 	return col.name
 }
 
 // Interval : the interval to collect the metrics on
-func (col baseCollector) Interval() int {
+func (col *baseCollector) Interval() int {
 	return col.interval
 }
 
 // String returns the collector name in printable format.
-func (col baseCollector) String() string {
+func (col *baseCollector) String() string {
 	return col.Name() + "Collector"
 }
 
@@ -166,3 +241,59 @@ func (col baseCollector) String() string {
 func (col *baseCollector) Blacklist() []string {
 	return col.blacklist
 }
+
+// Stop : signal the collector to stop
+func (col *baseCollector) Stop() {
+	signal := col.stopSignal()
+	signal.once.Do(func() {
+		close(signal.quit)
+	})
+}
+
+// Stopping : channel that is closed once the collector was asked to stop
+func (col *baseCollector) Stopping() <-chan struct{} {
+	return col.stopSignal().quit
+}
+
+// Context : the context of the run in progress, collectors that are run on
+// their own never time out
+func (col *baseCollector) Context() context.Context {
+	col.ctxLock.Lock()
+	defer col.ctxLock.Unlock()
+	if col.ctx == nil {
+		return context.Background()
+	}
+	return col.ctx
+}
+
+// SetContext : set the context of the next run
+func (col *baseCollector) SetContext(ctx context.Context) {
+	col.ctxLock.Lock()
+	defer col.ctxLock.Unlock()
+	col.ctx = ctx
+}
+
+// waitFor waits for the goroutines a run started, or until the run is past
+// its deadline, so that Collect() does not return while they still emit
+func (col *baseCollector) waitFor(wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-col.Context().Done():
+	}
+}
+
+func (col *baseCollector) stopSignal() *stopSignal {
+	stopSignalLock.Lock()
+	defer stopSignalLock.Unlock()
+
+	if col.stop == nil {
+		col.stop = &stopSignal{quit: make(chan struct{})}
+	}
+	return col.stop
+}
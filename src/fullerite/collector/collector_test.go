@@ -1,9 +1,14 @@
 package collector
 
 import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"context"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -37,3 +42,55 @@ func TestNewInvalidCollector(t *testing.T) {
 	c := New("INVALID COLLECTOR")
 	assert.Nil(t, c, "should not create a Collector")
 }
+
+func TestCollectorsDescribeThemselves(t *testing.T) {
+	for _, name := range Names() {
+		c := New(name)
+		assert.NotEmpty(t, c.Description(), "%s should have a description", name)
+		assert.Empty(t, config.CheckDefaults(c.ConfigKeys()), "%s should document defaults of the right type", name)
+		for _, key := range c.ConfigKeys() {
+			assert.NotEmpty(t, key.Description, "%s should describe %s", name, key.Name)
+		}
+	}
+}
+
+func TestFulleriteMetrics(t *testing.T) {
+	infos := New("Fullerite").Metrics()
+	names := make(map[string]string)
+	for _, info := range infos {
+		names[info.Name] = info.MetricType
+	}
+	assert.Equal(t, metric.Counter, names["NumGC"])
+	assert.Equal(t, metric.CumulativeCounter, names["fullerite.collector_runs"])
+	assert.Equal(t, metric.Gauge, names["fullerite.collector_last_success"])
+}
+
+func TestStop(t *testing.T) {
+	c := New("Test")
+
+	select {
+	case <-c.Stopping():
+		t.Fatal("should not be stopping before Stop() was called")
+	default:
+	}
+
+	c.Stop()
+	// stopping twice must not panic
+	c.Stop()
+
+	select {
+	case <-c.Stopping():
+	case <-time.After(time.Second):
+		t.Fatal("should be stopping after Stop() was called")
+	}
+}
+
+func TestContext(t *testing.T) {
+	c := New("Test")
+	assert.Nil(t, c.Context().Err(), "should never time out without a run context")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.SetContext(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, c.Context().Err())
+}
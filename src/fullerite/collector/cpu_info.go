@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
 
 	"bufio"
@@ -43,6 +44,25 @@ func newCPUInfo(channel chan metric.Metric, initialInterval int, log *l.Entry) C
 	return c
 }
 
+// ConfigKeys describes the keys of the CPUInfo config
+func (c *CPUInfo) ConfigKeys() []config.Key {
+	return append(c.baseCollector.ConfigKeys(),
+		config.Key{Name: "procPath", Type: config.TypeString, Default: defaultProcPath, Description: "file to read the CPU info from"},
+	)
+}
+
+// Description of the CPUInfo collector
+func (c *CPUInfo) Description() string {
+	return "Emits the number of physical CPUs and their model"
+}
+
+// Metrics the CPUInfo collector emits
+func (c *CPUInfo) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: metricName, MetricType: metric.Gauge, Description: "number of physical CPUs, the model is a dimension"},
+	}
+}
+
 // Configure Override default parameters
 func (c *CPUInfo) Configure(configMap map[string]interface{}) {
 	if procPath, exists := configMap["procPath"]; exists == true {
@@ -52,7 +72,7 @@ func (c *CPUInfo) Configure(configMap map[string]interface{}) {
 }
 
 // Collect Emits the no of CPUs and ModelName
-func (c CPUInfo) Collect() {
+func (c *CPUInfo) Collect() {
 	value, model, err := c.getCPUInfo()
 	if err != nil {
 		c.log.Error("Error while collecting metrics: ", err)
@@ -65,7 +85,7 @@ func (c CPUInfo) Collect() {
 	c.log.Debug(metric)
 }
 
-func (c CPUInfo) getCPUInfo() (float64, string, error) {
+func (c *CPUInfo) getCPUInfo() (float64, string, error) {
 
 	// Prepare to read file
 	file, err := os.Open(c.procPath)
@@ -1,12 +1,16 @@
 package collector
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
+	"fullerite/util"
 
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -22,8 +26,16 @@ const (
 type Diamond struct {
 	baseCollector
 	port          string
+	tlsConfig     *tls.Config
+	tlsErr        error
 	serverStarted bool
 	incoming      chan []byte
+
+	listenerLock sync.Mutex
+	listener     *net.TCPListener
+
+	// bindAddress and the TLS settings
+	listenerConfig map[string]interface{}
 }
 
 func init() {
@@ -46,54 +58,142 @@ func newDiamond(channel chan metric.Metric, initialInterval int, log *l.Entry) C
 	return d
 }
 
+// ConfigKeys describes the keys of the Diamond config
+func (d *Diamond) ConfigKeys() []config.Key {
+	keys := append(d.baseCollector.ConfigKeys(),
+		config.Key{Name: "port", Type: config.TypeString, Default: DefaultDiamondCollectorPort, Description: "TCP port the Diamond collectors send to"},
+	)
+	return append(keys, util.ListenerKeys...)
+}
+
+// Description of the Diamond collector
+func (d *Diamond) Description() string {
+	return "Receives the metrics of the Diamond collectors that fullerite_diamond_server runs"
+}
+
+// Metrics the Diamond collector emits
+func (d *Diamond) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "<name>", Description: "the metrics the Diamond collectors send, the collector is a dimension"},
+	}
+}
+
 // Configure the collector
 func (d *Diamond) Configure(configMap map[string]interface{}) {
 	if port, exists := configMap["port"]; exists {
 		d.port = port.(string)
 	}
+	d.listenerConfig = configMap
+	d.tlsConfig, d.tlsErr = util.ServerTLSConfig(configMap)
 	d.configureCommonParams(configMap)
 }
 
 // Port returns Diamond collectors listen port
 func (d *Diamond) Port() string {
+	d.listenerLock.Lock()
+	defer d.listenerLock.Unlock()
 	return d.port
 }
 
+// Listening returns true once the diamond socket is bound, until the
+// collector is stopped
+func (d *Diamond) Listening() bool {
+	select {
+	case <-d.Stopping():
+		return false
+	default:
+	}
+
+	d.listenerLock.Lock()
+	defer d.listenerLock.Unlock()
+	return d.listener != nil
+}
+
 // collectDiamond opens up and reads from the a TCP socket and
 // writes what it's read to a local channel. Diamond handler (running in
 // separate processes) write to the same port.
 //
 // When Collect() is called it reads from the local channel converts
 // strings to metrics and publishes metrics to handlers.
+//
+// With a server certificate the connections are TLS, and with a client CA
+// the Diamond handlers need a certificate it signed to connect.
 func (d *Diamond) collectDiamond() {
-	addr, err := net.ResolveTCPAddr("tcp", ":"+d.port)
+	// a socket that was meant to be TLS does not fall back to plaintext
+	if d.tlsErr != nil {
+		d.log.Fatal("Invalid TLS config of the diamond socket: ", d.tlsErr)
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", util.ListenAddress(d.listenerConfig, d.port))
 
 	if err != nil {
 		panic(err)
 	}
 
-	l, err := net.ListenTCP("tcp4", addr)
+	network := "tcp4"
+	if addr.IP != nil && addr.IP.To4() == nil {
+		network = "tcp6"
+	}
+	l, err := net.ListenTCP(network, addr)
 	if err != nil {
 		d.log.Fatal("Cannot listen on diamond socket", err)
 	}
 
+	d.listenerLock.Lock()
+	d.listener = l
+	d.listenerLock.Unlock()
+
+	select {
+	case <-d.Stopping():
+		// we were stopped before the socket was up
+		l.Close()
+	default:
+	}
+
 	// figure out the port bind for Port()
-	d.port = strings.Split(l.Addr().String(), ":")[1]
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	d.listenerLock.Lock()
+	d.port = port
+	d.listenerLock.Unlock()
 
 	for {
 		conn, err := l.AcceptTCP()
 		if err != nil {
+			select {
+			case <-d.Stopping():
+				d.log.Info("Stopped listening on diamond socket")
+				return
+			default:
+			}
 			d.log.Fatal(err)
 		}
 		go d.readDiamondMetrics(conn)
 	}
 }
 
-// readDiamondMetrics reads from the connection
-func (d *Diamond) readDiamondMetrics(conn *net.TCPConn) {
+// Stop closes the diamond socket and makes Collect return
+func (d *Diamond) Stop() {
+	d.baseCollector.Stop()
+
+	d.listenerLock.Lock()
+	defer d.listenerLock.Unlock()
+	if d.listener != nil {
+		d.listener.Close()
+	}
+}
+
+// readDiamondMetrics reads from the connection, over TLS if there is a
+// server certificate
+func (d *Diamond) readDiamondMetrics(tcpConn *net.TCPConn) {
+	tcpConn.SetKeepAlive(true)
+	tcpConn.SetKeepAlivePeriod(time.Second)
+
+	var conn net.Conn = tcpConn
+	if d.tlsConfig != nil {
+		conn = tls.Server(tcpConn, d.tlsConfig)
+	}
 	defer conn.Close()
-	conn.SetKeepAlive(true)
-	conn.SetKeepAlivePeriod(time.Second)
+
 	reader := bufio.NewReader(conn)
 	d.log.Info("Connection started: ", conn.RemoteAddr())
 	for {
@@ -103,7 +203,11 @@ func (d *Diamond) readDiamondMetrics(conn *net.TCPConn) {
 			break
 		}
 		d.log.Debug("Read: ", string(line))
-		d.incoming <- line
+		select {
+		case d.incoming <- line:
+		case <-d.Stopping():
+			return
+		}
 	}
 	d.log.Info("Connection closed: ", conn.RemoteAddr())
 }
@@ -116,11 +220,16 @@ func (d *Diamond) Collect() {
 		go d.collectDiamond()
 	}
 
-	for line := range d.incoming {
-		if metrics, ok := d.parseMetrics(line); ok {
-			for _, metric := range metrics {
-				d.Channel() <- metric
+	for {
+		select {
+		case line := <-d.incoming:
+			if metrics, ok := d.parseMetrics(line); ok {
+				for _, metric := range metrics {
+					d.Channel() <- metric
+				}
 			}
+		case <-d.Stopping():
+			return
 		}
 	}
 }
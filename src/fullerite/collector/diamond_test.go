@@ -4,6 +4,7 @@ import (
 	"fullerite/metric"
 	"test_utils"
 
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -67,6 +68,102 @@ func TestDiamondCollect(t *testing.T) {
 	}
 }
 
+func TestDiamondStop(t *testing.T) {
+	config := make(map[string]interface{})
+	config["port"] = "0"
+
+	testChannel := make(chan metric.Metric)
+	testLog := test_utils.BuildLogger()
+
+	d := newDiamond(testChannel, 123, testLog).(*Diamond)
+	d.Configure(config)
+	assert.False(t, d.Listening(), "should not listen before collecting")
+
+	done := make(chan struct{})
+	go func() {
+		d.Collect()
+		close(done)
+	}()
+
+	conn, err := connectToDiamondCollector(d)
+	require.Nil(t, err, "should connect")
+	conn.Close()
+	assert.True(t, d.Listening())
+
+	d.Stop()
+	assert.False(t, d.Listening(), "should not listen once stopped")
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("Collect should return once the collector is stopped")
+	}
+
+	_, err = net.Dial("tcp", "localhost:"+d.Port())
+	assert.NotNil(t, err, "should not accept connections anymore")
+}
+
+func TestDiamondTLS(t *testing.T) {
+	certs, err := test_utils.WriteTestCertificates()
+	require.Nil(t, err)
+	defer certs.Remove()
+
+	config := map[string]interface{}{
+		"port":        "0",
+		"bindAddress": "127.0.0.1",
+		"tlsCert":     certs.ServerCert,
+		"tlsKey":      certs.ServerKey,
+		"tlsClientCA": certs.CA,
+	}
+
+	testChannel := make(chan metric.Metric)
+	d := newDiamond(testChannel, 123, test_utils.BuildLogger()).(*Diamond)
+	d.Configure(config)
+	go d.Collect()
+	defer d.Stop()
+
+	for deadline := time.Now().Add(2 * time.Second); !d.Listening() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, d.Listening())
+	address := "127.0.0.1:" + d.Port()
+
+	conn, err := tls.Dial("tcp", address, certs.ClientTLSConfig(true))
+	require.Nil(t, err, "should connect with a client certificate")
+	defer conn.Close()
+	emitTestMetric(conn)
+
+	// the test metric is sent twice
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-d.Channel():
+			assert.Equal(t, "test", m.Name)
+		case <-time.After(1 * time.Second):
+			t.Fatal("should read the metrics over TLS")
+		}
+	}
+
+	plain, err := net.Dial("tcp", address)
+	require.Nil(t, err)
+	defer plain.Close()
+	emitTestMetric(plain)
+
+	select {
+	case <-d.Channel():
+		t.Fatal("should not read plaintext metrics")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestDiamondInvalidTLSConfig(t *testing.T) {
+	d := newDiamond(nil, 12, nil).(*Diamond)
+	d.Configure(map[string]interface{}{"tlsCert": "/missing/cert.pem", "tlsKey": "/missing/key.pem"})
+	assert.NotNil(t, d.tlsErr)
+
+	d.Configure(map[string]interface{}{})
+	assert.Nil(t, d.tlsErr)
+	assert.Nil(t, d.tlsConfig)
+}
+
 func TestParseJsonToMetric(t *testing.T) {
 	rawData := []byte(`
 [{
@@ -86,6 +183,25 @@ func TestParseJsonToMetric(t *testing.T) {
 	}
 }
 
+func TestParseJsonToMetricWithTimestamp(t *testing.T) {
+	rawData := []byte(`
+[{
+   "name": "foobar",
+   "type":  "GAUGE",
+   "value": 100.0,
+   "timestamp": 1450000000,
+   "dimensions": {
+      "host": "windrunner"
+   }
+}]
+        `)
+	d := newDiamond(nil, 12, nil).(*Diamond)
+	metrics, ok := d.parseMetrics(rawData)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, int64(1450000000), metrics[0].Timestamp.Unix())
+}
+
 func TestInvalidJsonToMetric(t *testing.T) {
 	rawData := []byte(`
 [{
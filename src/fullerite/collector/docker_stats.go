@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"fullerite/config"
 	"fullerite/metric"
 	"reflect"
@@ -70,6 +71,37 @@ func (d *DockerStats) GetEndpoint() string {
 	return d.endpoint
 }
 
+// ConfigKeys describes the keys of the DockerStats config
+func (d *DockerStats) ConfigKeys() []config.Key {
+	return append(d.baseCollector.ConfigKeys(),
+		config.Key{Name: "dockerEndPoint", Type: config.TypeString, Default: endpoint, Description: "endpoint of the Docker API"},
+		config.Key{Name: "dockerStatsTimeout", Type: config.TypeInt,
+			Description: "seconds the stats of a container may take, at most the interval"},
+		config.Key{Name: "generatedDimensions", Type: config.TypeObject,
+			Description: "dimensions taken from environment variables of the containers, by variable and regexp"},
+		config.Key{Name: "skipContainerRegex", Type: config.TypeString, Description: "regexp of the containers to skip"},
+	)
+}
+
+// Description of the DockerStats collector
+func (d *DockerStats) Description() string {
+	return "Emits the memory, CPU and network usage of the running Docker containers"
+}
+
+// Metrics the DockerStats collector emits, the container is a dimension
+func (d *DockerStats) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "DockerMemoryUsed", MetricType: metric.Gauge},
+		{Name: "DockerMemoryLimit", MetricType: metric.Gauge},
+		{Name: "DockerCpuPercentage", MetricType: metric.Gauge},
+		{Name: "DockerCpuThrottledPeriods", MetricType: metric.CumulativeCounter},
+		{Name: "DockerCpuThrottledNanoseconds", MetricType: metric.CumulativeCounter},
+		{Name: "DockerTxBytes", MetricType: metric.CumulativeCounter, Description: "by interface, the interface is a dimension"},
+		{Name: "DockerRxBytes", MetricType: metric.CumulativeCounter, Description: "by interface, the interface is a dimension"},
+		{Name: "DockerContainerCount", MetricType: metric.Counter},
+	}
+}
+
 // Configure takes a dictionary of values with which the handler can configure itself.
 func (d *DockerStats) Configure(configMap map[string]interface{}) {
 	if timeout, exists := configMap["dockerStatsTimeout"]; exists {
@@ -107,19 +139,29 @@ func (d *DockerStats) Configure(configMap map[string]interface{}) {
 
 // Collect iterates on all the docker containers alive and, if possible, collects the correspondent
 // memory and cpu statistics.
-// For each container a gorutine is started to spin up the collection process.
+// For each container a gorutine is started to spin up the collection process. The
+// containers left are skipped and the stats requests aborted once the run is past
+// its deadline. Collect returns once the goroutines are done.
 func (d *DockerStats) Collect() {
+	ctx := d.Context()
+	var wg sync.WaitGroup
+	defer d.waitFor(&wg)
+
 	if d.dockerClient == nil {
 		d.log.Error("Invalid endpoint: ", docker.ErrInvalidEndpoint)
 		return
 	}
-	containers, err := d.dockerClient.ListContainers(docker.ListContainersOptions{All: false})
+	containers, err := d.listContainers(ctx)
 	if err != nil {
 		d.log.Error("ListContainers() failed: ", err)
 		return
 	}
 	for _, apiContainer := range containers {
-		container, err := d.dockerClient.InspectContainer(apiContainer.ID)
+		if ctx.Err() != nil {
+			d.log.Error("Deadline exceeded, skipping the stats of the remaining containers")
+			return
+		}
+		container, err := d.inspectContainer(ctx, apiContainer.ID)
 
 		if err != nil {
 			d.log.Error("InspectContainer() failed: ", err)
@@ -133,19 +175,72 @@ func (d *DockerStats) Collect() {
 		if _, ok := d.previousCPUValues[container.ID]; !ok {
 			d.previousCPUValues[container.ID] = new(CPUValues)
 		}
-		go d.getDockerContainerInfo(container)
+		wg.Add(1)
+		go func(container *docker.Container) {
+			defer wg.Done()
+			d.getDockerContainerInfo(ctx, container)
+		}(container)
+	}
+}
+
+// listContainers lists the running containers, or gives up once the run is
+// past its deadline. The pinned client takes no context to abort the request
+// with, the request is left to finish in the background.
+func (d *DockerStats) listContainers(ctx context.Context) ([]docker.APIContainers, error) {
+	type result struct {
+		containers []docker.APIContainers
+		err        error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		containers, err := d.dockerClient.ListContainers(docker.ListContainersOptions{All: false})
+		resultC <- result{containers, err}
+	}()
+
+	select {
+	case r := <-resultC:
+		return r.containers, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// inspectContainer inspects a container, or gives up once the run is past
+// its deadline like listContainers
+func (d *DockerStats) inspectContainer(ctx context.Context, id string) (*docker.Container, error) {
+	type result struct {
+		container *docker.Container
+		err       error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		container, err := d.dockerClient.InspectContainer(id)
+		resultC <- result{container, err}
+	}()
+
+	select {
+	case r := <-resultC:
+		return r.container, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
 // getDockerContainerInfo gets container statistics for the given container.
 // results is a channel to make possible the synchronization between the main process and the gorutines (wait-notify pattern).
-func (d *DockerStats) getDockerContainerInfo(container *docker.Container) {
+func (d *DockerStats) getDockerContainerInfo(ctx context.Context, container *docker.Container) {
 	errC := make(chan error, 1)
 	statsC := make(chan *docker.Stats, 1)
 	done := make(chan bool, 1)
 
 	go func() {
-		errC <- d.dockerClient.Stats(docker.StatsOptions{container.ID, statsC, false, done, time.Second * time.Duration(d.interval)})
+		errC <- d.dockerClient.Stats(docker.StatsOptions{
+			ID:      container.ID,
+			Stats:   statsC,
+			Stream:  false,
+			Done:    done,
+			Timeout: time.Second * time.Duration(d.interval),
+		})
 	}()
 	select {
 	case stats, ok := <-statsC:
@@ -164,6 +259,10 @@ func (d *DockerStats) getDockerContainerInfo(container *docker.Container) {
 		d.log.Error("Timed out collecting stats for container ", container.ID)
 		done <- true
 		break
+	case <-ctx.Done():
+		d.log.Error("Deadline exceeded collecting stats for container ", container.ID)
+		done <- true
+		break
 	}
 }
 
@@ -178,7 +277,7 @@ func (d *DockerStats) extractMetrics(container *docker.Container, stats *docker.
 }
 
 // buildMetrics creates the actual metrics for the given container.
-func (d DockerStats) buildMetrics(container *docker.Container, containerStats *docker.Stats, cpuPercentage float64) []metric.Metric {
+func (d *DockerStats) buildMetrics(container *docker.Container, containerStats *docker.Stats, cpuPercentage float64) []metric.Metric {
 	ret := []metric.Metric{
 		buildDockerMetric("DockerMemoryUsed", metric.Gauge, float64(containerStats.MemoryStats.Usage)),
 		buildDockerMetric("DockerMemoryLimit", metric.Gauge, float64(containerStats.MemoryStats.Limit)),
@@ -207,7 +306,7 @@ func (d DockerStats) buildMetrics(container *docker.Container, containerStats *d
 }
 
 // sendMetrics writes all the metrics received to the collector channel.
-func (d DockerStats) sendMetrics(metrics []metric.Metric) {
+func (d *DockerStats) sendMetrics(metrics []metric.Metric) {
 	for _, m := range metrics {
 		d.Channel() <- m
 	}
@@ -215,7 +314,7 @@ func (d DockerStats) sendMetrics(metrics []metric.Metric) {
 
 // Function that extracts additional dimensions from the docker environmental variables set up by the user
 // in the configuration file.
-func (d DockerStats) extractDimensions(container *docker.Container) map[string]string {
+func (d *DockerStats) extractDimensions(container *docker.Container) map[string]string {
 	envVars := container.Config.Env
 	ret := map[string]string{}
 
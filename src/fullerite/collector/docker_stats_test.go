@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"fullerite/metric"
 
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	l "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
@@ -112,14 +116,14 @@ func TestDockerStatsBuildMetrics(t *testing.T) {
 		"instance_name": "main",
 	}
 	expectedMetrics := []metric.Metric{
-		metric.Metric{"DockerMemoryUsed", "gauge", 50, baseDims},
-		metric.Metric{"DockerMemoryLimit", "gauge", 70, baseDims},
-		metric.Metric{"DockerCpuPercentage", "gauge", 0.5, baseDims},
-		metric.Metric{"DockerCpuThrottledPeriods", "cumcounter", 123, baseDims},
-		metric.Metric{"DockerCpuThrottledNanoseconds", "cumcounter", 456, baseDims},
-		metric.Metric{"DockerTxBytes", "cumcounter", 20, netDims},
-		metric.Metric{"DockerRxBytes", "cumcounter", 10, netDims},
-		metric.Metric{"DockerContainerCount", "counter", 1, expectedDimsGen},
+		metric.Metric{Name: "DockerMemoryUsed", MetricType: "gauge", Value: 50, Dimensions: baseDims},
+		metric.Metric{Name: "DockerMemoryLimit", MetricType: "gauge", Value: 70, Dimensions: baseDims},
+		metric.Metric{Name: "DockerCpuPercentage", MetricType: "gauge", Value: 0.5, Dimensions: baseDims},
+		metric.Metric{Name: "DockerCpuThrottledPeriods", MetricType: "cumcounter", Value: 123, Dimensions: baseDims},
+		metric.Metric{Name: "DockerCpuThrottledNanoseconds", MetricType: "cumcounter", Value: 456, Dimensions: baseDims},
+		metric.Metric{Name: "DockerTxBytes", MetricType: "cumcounter", Value: 20, Dimensions: netDims},
+		metric.Metric{Name: "DockerRxBytes", MetricType: "cumcounter", Value: 10, Dimensions: netDims},
+		metric.Metric{Name: "DockerContainerCount", MetricType: "counter", Value: 1, Dimensions: expectedDimsGen},
 	}
 
 	d := getSUT()
@@ -171,12 +175,12 @@ func TestDockerStatsBuildMetricsWithNameAsEnvVariable(t *testing.T) {
 		"service_name": "my_service",
 	}
 	expectedMetrics := []metric.Metric{
-		metric.Metric{"DockerMemoryUsed", "gauge", 50, expectedDims},
-		metric.Metric{"DockerMemoryLimit", "gauge", 70, expectedDims},
-		metric.Metric{"DockerCpuPercentage", "gauge", 0.5, expectedDims},
-		metric.Metric{"DockerCpuThrottledPeriods", "cumcounter", 123, expectedDims},
-		metric.Metric{"DockerCpuThrottledNanoseconds", "cumcounter", 456, expectedDims},
-		metric.Metric{"DockerContainerCount", "counter", 1, expectedDimsGen},
+		metric.Metric{Name: "DockerMemoryUsed", MetricType: "gauge", Value: 50, Dimensions: expectedDims},
+		metric.Metric{Name: "DockerMemoryLimit", MetricType: "gauge", Value: 70, Dimensions: expectedDims},
+		metric.Metric{Name: "DockerCpuPercentage", MetricType: "gauge", Value: 0.5, Dimensions: expectedDims},
+		metric.Metric{Name: "DockerCpuThrottledPeriods", MetricType: "cumcounter", Value: 123, Dimensions: expectedDims},
+		metric.Metric{Name: "DockerCpuThrottledNanoseconds", MetricType: "cumcounter", Value: 456, Dimensions: expectedDims},
+		metric.Metric{Name: "DockerContainerCount", MetricType: "counter", Value: 1, Dimensions: expectedDimsGen},
 	}
 
 	d := getSUT()
@@ -204,3 +208,26 @@ func TestDockerStatsCalculateCPUPercent(t *testing.T) {
 
 	assert.Equal(t, 0.060815135225936505, calculateCPUPercent(previousTotalUsage, previousSystem, stats))
 }
+
+func TestDockerStatsGivesUpPastTheDeadline(t *testing.T) {
+	// a Docker daemon that never answers
+	hang := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer ts.Close()
+	defer close(hang)
+
+	d := getSUT()
+	d.Configure(map[string]interface{}{"dockerEndPoint": ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := d.listContainers(ctx)
+	assert.NotNil(t, err)
+	_, err = d.inspectContainer(ctx, "test-id")
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < time.Second, "should not wait for the daemon past the deadline")
+}
@@ -2,8 +2,10 @@ package collector
 
 import (
 	"fullerite/metric"
+	"fullerite/stats"
 
 	"runtime"
+	"sort"
 
 	l "github.com/Sirupsen/logrus"
 )
@@ -14,6 +16,7 @@ type memStatRetriever func() *runtime.MemStats
 type Fullerite struct {
 	baseCollector
 	memStats memStatRetriever
+	stats    *stats.Registry
 }
 
 func init() {
@@ -30,22 +33,58 @@ func newFullerite(channel chan metric.Metric, initialInterval int, log *l.Entry)
 
 	f.name = "Fullerite"
 	f.memStats = getMemStats
+	f.stats = stats.Default
 	return f
 }
 
+// Description of the Fullerite collector
+func (f *Fullerite) Description() string {
+	return "Emits the memory stats of fullerite and the stats of its collectors"
+}
+
+// Metrics the Fullerite collector emits, taken from what it would emit now
+func (f *Fullerite) Metrics() []metric.Info {
+	var infos []metric.Info
+	for _, m := range f.getGoMetrics() {
+		infos = append(infos, metric.Info{Name: m.Name, MetricType: m.MetricType})
+	}
+
+	registry := stats.NewRegistry()
+	registry.StartRun("<collector>").Finish()
+	types := make(map[string]string)
+	var names []string
+	for _, m := range (&Fullerite{stats: registry}).getCollectorMetrics() {
+		types[m.Name] = m.MetricType
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		infos = append(infos, metric.Info{
+			Name:        name,
+			MetricType:  types[name],
+			Description: "stats of a collector, the collector is a dimension",
+		})
+	}
+	return infos
+}
+
 // Configure this takes a dictionary of values with which the handler can configure itself
 func (f *Fullerite) Configure(configMap map[string]interface{}) {
 	f.configureCommonParams(configMap)
 }
 
-// Collect produces some random test metrics.
-func (f Fullerite) Collect() {
+// Collect emits the memory stats of the process and the stats of every
+// collector
+func (f *Fullerite) Collect() {
 	for _, m := range f.getGoMetrics() {
 		f.Channel() <- m
 	}
+	for _, m := range f.getCollectorMetrics() {
+		f.Channel() <- m
+	}
 }
 
-func (f Fullerite) getGoMetrics() []metric.Metric {
+func (f *Fullerite) getGoMetrics() []metric.Metric {
 	m := f.memStats()
 
 	ret := []metric.Metric{
@@ -80,6 +119,27 @@ func (f Fullerite) getGoMetrics() []metric.Metric {
 	return ret
 }
 
+// getCollectorMetrics turns the stats of the collectors into metrics with the
+// name of the collector as dimension. The counters only ever grow, so they
+// are cumulative.
+func (f *Fullerite) getCollectorMetrics() []metric.Metric {
+	ret := []metric.Metric{}
+	for name, internal := range f.stats.InternalMetrics() {
+		for key, value := range internal.Counters {
+			m := metric.WithValue(key, value)
+			m.MetricType = metric.CumulativeCounter
+			m.AddDimension("collector", name)
+			ret = append(ret, m)
+		}
+		for key, value := range internal.Gauges {
+			m := metric.WithValue(key, value)
+			m.AddDimension("collector", name)
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
 // ----------------------------------------------------------------------------
 // utility methods
 // ----------------------------------------------------------------------------
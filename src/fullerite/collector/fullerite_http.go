@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"fullerite/config"
 	"fullerite/internalserver"
 	"fullerite/metric"
 
@@ -19,6 +20,12 @@ const (
 	defaultFulleriteProtocol = "http"
 )
 
+var defaultFulleriteEndpoint = fmt.Sprintf("%s://%s:%d/%s",
+	defaultFulleriteProtocol,
+	defaultFulleriteHost,
+	defaultFulleritePort,
+	defaultFulleritePath)
+
 // collects stats from fullerite's http endpoint
 type fulleriteHTTP struct {
 	baseHTTPCollector
@@ -38,11 +45,7 @@ func newFulleriteHTTP(channel chan metric.Metric, initialInterval int, log *l.En
 
 	inst.name = "FulleriteHTTP"
 
-	inst.endpoint = fmt.Sprintf("%s://%s:%d/%s",
-		defaultFulleriteProtocol,
-		defaultFulleriteHost,
-		defaultFulleritePort,
-		defaultFulleritePath)
+	inst.endpoint = defaultFulleriteEndpoint
 
 	inst.rspHandler = inst.handleResponse
 	inst.errHandler = inst.handleError
@@ -51,6 +54,27 @@ func newFulleriteHTTP(channel chan metric.Metric, initialInterval int, log *l.En
 	return inst
 }
 
+// ConfigKeys describes the keys of the fulleriteHTTP config
+func (inst *fulleriteHTTP) ConfigKeys() []config.Key {
+	return append(inst.baseHTTPCollector.ConfigKeys(),
+		config.Key{Name: "endpoint", Type: config.TypeString, Default: defaultFulleriteEndpoint,
+			Description: "URL of the internal server of the fullerite to collect from"},
+	)
+}
+
+// Description of the FulleriteHTTP collector
+func (inst *fulleriteHTTP) Description() string {
+	return "Emits the internal metrics of a fullerite, read from its internal server"
+}
+
+// Metrics the FulleriteHTTP collector emits
+func (inst *fulleriteHTTP) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "<name>", Description: "the memory stats and the metrics of the handlers and collectors, " +
+			"the handler or collector is a dimension"},
+	}
+}
+
 func (inst *fulleriteHTTP) Configure(configMap map[string]interface{}) {
 	if endpoint, exists := configMap["endpoint"]; exists {
 		inst.endpoint = endpoint.(string)
@@ -59,13 +83,13 @@ func (inst *fulleriteHTTP) Configure(configMap map[string]interface{}) {
 	inst.configureCommonParams(configMap)
 }
 
-func (inst fulleriteHTTP) handleError(err error) {
+func (inst *fulleriteHTTP) handleError(err error) {
 	inst.log.Error("Failed to make GET to ", inst.endpoint, " error is: ", err)
 }
 
 // handleResponse assumes the format of the response is a JSON dictionary. It then converts
 // them to individual metrics.
-func (inst fulleriteHTTP) handleResponse(rsp *http.Response) []metric.Metric {
+func (inst *fulleriteHTTP) handleResponse(rsp *http.Response) []metric.Metric {
 	results := []metric.Metric{}
 
 	txt, err := ioutil.ReadAll(rsp.Body)
@@ -84,7 +108,7 @@ func (inst fulleriteHTTP) handleResponse(rsp *http.Response) []metric.Metric {
 	return results
 }
 
-func (inst fulleriteHTTP) buildMetrics(counters *map[string]float64, isCounter bool) []metric.Metric {
+func (inst *fulleriteHTTP) buildMetrics(counters *map[string]float64, isCounter bool) []metric.Metric {
 	results := make([]metric.Metric, 0, len(*counters))
 	for key, val := range *counters {
 		m := metric.New(key)
@@ -100,7 +124,7 @@ func (inst fulleriteHTTP) buildMetrics(counters *map[string]float64, isCounter b
 // parseResponseText takes the raw JSON string and parses that into metrics. The
 // format of the JSON string is assumed to be a dictionary and then each key
 // creates a metric.
-func (inst fulleriteHTTP) parseResponseText(raw *[]byte) ([]metric.Metric, error) {
+func (inst *fulleriteHTTP) parseResponseText(raw *[]byte) ([]metric.Metric, error) {
 	var parsedRsp internalserver.ResponseFormat
 
 	err := json.Unmarshal(*raw, &parsedRsp)
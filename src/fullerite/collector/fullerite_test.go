@@ -2,6 +2,7 @@ package collector
 
 import (
 	"fullerite/metric"
+	"fullerite/stats"
 	"test_utils"
 
 	"testing"
@@ -55,3 +56,22 @@ func TestFulleriteCollect(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFulleriteCollectorMetrics(t *testing.T) {
+	f := newFullerite(nil, 123, nil).(*Fullerite)
+	f.stats = stats.NewRegistry()
+	f.stats.AddEmitted("ProcStatus", 3)
+	f.stats.StartRun("ProcStatus").Finish()
+
+	metrics := map[string]metric.Metric{}
+	for _, m := range f.getCollectorMetrics() {
+		assert.Equal(t, "ProcStatus", m.Dimensions["collector"])
+		metrics[m.Name] = m
+	}
+
+	assert.Equal(t, 3.0, metrics["fullerite.collector_datapoints"].Value)
+	assert.Equal(t, metric.CumulativeCounter, metrics["fullerite.collector_datapoints"].MetricType)
+	assert.Equal(t, 1.0, metrics["fullerite.collector_runs"].Value)
+	assert.Equal(t, metric.Gauge, metrics["fullerite.collector_last_success"].MetricType)
+	assert.Contains(t, metrics, "fullerite.collector_run_duration")
+}
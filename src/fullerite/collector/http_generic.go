@@ -4,6 +4,7 @@ import (
 	"fullerite/metric"
 	"time"
 
+	"context"
 	"net/http"
 )
 
@@ -20,7 +21,7 @@ type baseHTTPCollector struct {
 }
 
 // Collect first queries the config'd endpoint and then passes the results to the handler functions
-func (base baseHTTPCollector) Collect() {
+func (base *baseHTTPCollector) Collect() {
 	base.log.Info("Starting to collect metrics from ", base.endpoint)
 
 	metrics := base.makeRequest()
@@ -36,7 +37,7 @@ func (base baseHTTPCollector) Collect() {
 }
 
 // makeRequest is what is responsible for actually doing the HTTP GET
-func (base baseHTTPCollector) makeRequest() []metric.Metric {
+func (base *baseHTTPCollector) makeRequest() []metric.Metric {
 	if base.endpoint == "" {
 		base.log.Warn("Ignoring attempt to make request because no endpoint provided")
 		return []metric.Metric{}
@@ -46,7 +47,7 @@ func (base baseHTTPCollector) makeRequest() []metric.Metric {
 		Timeout: time.Duration(2) * time.Second,
 	}
 
-	rsp, err := client.Get(base.endpoint)
+	rsp, err := getWithContext(base.Context(), &client, base.endpoint)
 	if err != nil {
 		base.errHandler(err)
 		return nil
@@ -54,3 +55,12 @@ func (base baseHTTPCollector) makeRequest() []metric.Metric {
 
 	return base.rspHandler(rsp)
 }
+
+// getWithContext makes a GET request that is aborted once the context is done
+func getWithContext(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req.WithContext(ctx))
+}
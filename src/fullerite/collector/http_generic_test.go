@@ -3,11 +3,13 @@ package collector
 import (
 	"fullerite/metric"
 
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	l "github.com/Sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -81,3 +83,34 @@ func TestWorkingGenericHTTP(t *testing.T) {
 	assert.NotNil(t, m, "should have produced a single metric")
 	assert.True(t, ensureEmpty(col.Channel()), "There should have only been a single metric")
 }
+
+func TestGenericHTTPAbortedAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	col := buildBaseHTTPCollector(server.URL)
+	errs := make(chan error, 1)
+	col.errHandler = func(err error) {
+		errs <- err
+	}
+	col.rspHandler = func(rsp *http.Response) []metric.Metric {
+		t.Fatal("should not get a response")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	col.SetContext(ctx)
+	assert.Nil(t, col.makeRequest())
+
+	select {
+	case err := <-errs:
+		assert.NotNil(t, err)
+	default:
+		t.Fatal("should report the aborted request")
+	}
+}
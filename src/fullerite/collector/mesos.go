@@ -8,7 +8,9 @@ import (
 	"fullerite/util"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -100,6 +102,40 @@ func newMesosStats(channel chan metric.Metric, intialInterval int, log *l.Entry)
 	return m
 }
 
+// ConfigKeys describes the keys of the MesosStats config
+func (m *MesosStats) ConfigKeys() []config.Key {
+	return append(m.baseCollector.ConfigKeys(),
+		config.Key{Name: "mesosNodes", Type: config.TypeString, Required: true,
+			Description: "comma separated URLs of the Mesos masters"},
+	)
+}
+
+// Description of the MesosStats collector
+func (m *MesosStats) Description() string {
+	return "Emits the metrics of the leading Mesos master, when run on it"
+}
+
+// Metrics the MesosStats collector emits
+func (m *MesosStats) Metrics() []metric.Info {
+	return mesosMetrics(mesosMasterCumulativeCountersList, "the metrics of /metrics/snapshot of the master")
+}
+
+// mesosMetrics describes the metrics of a Mesos snapshot, all of them are
+// gauges except for the counters
+func mesosMetrics(counters map[string]int, description string) []metric.Info {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := []metric.Info{{Name: "mesos.<name>", MetricType: metric.Gauge, Description: description}}
+	for _, name := range names {
+		infos = append(infos, metric.Info{Name: "mesos." + name, MetricType: metric.CumulativeCounter})
+	}
+	return infos
+}
+
 // Configure Override *baseCollector.Configure(). Will create the required MesosLeaderElect instance.
 func (m *MesosStats) Configure(configMap map[string]interface{}) {
 	m.configureCommonParams(configMap)
@@ -124,7 +160,13 @@ func (m *MesosStats) Collect() {
 		return
 	}
 
-	go sendMetrics(m)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendMetrics(m)
+	}()
+	m.waitFor(&wg)
 }
 
 // sendMetrics Send to baseCollector channel.
@@ -138,7 +180,7 @@ func (m *MesosStats) sendMetrics() {
 // getMetrics Get metrics from the :5050/metrics/snapshot mesos endpoint.
 func (m *MesosStats) getMetrics(ip string) map[string]float64 {
 	url := getMetricsURL(ip)
-	r, err := m.client.Get(url)
+	r, err := getWithContext(m.Context(), &m.client, url)
 
 	if err != nil {
 		m.log.Error("Could not load metrics from mesos", err.Error())
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -74,6 +75,24 @@ func newMesosSlaveStats(channel chan metric.Metric, intialInterval int, log *l.E
 	return m
 }
 
+// ConfigKeys describes the keys of the MesosSlaveStats config
+func (m *MesosSlaveStats) ConfigKeys() []config.Key {
+	return append(m.baseCollector.ConfigKeys(),
+		config.Key{Name: "httpTimeout", Type: config.TypeString, Default: "10", Description: "seconds the snapshot request may take"},
+		config.Key{Name: "slaveSnapshotPort", Type: config.TypeString, Default: "5051", Description: "port of the Mesos slave"},
+	)
+}
+
+// Description of the MesosSlaveStats collector
+func (m *MesosSlaveStats) Description() string {
+	return "Emits the metrics of the local Mesos slave"
+}
+
+// Metrics the MesosSlaveStats collector emits
+func (m *MesosSlaveStats) Metrics() []metric.Info {
+	return mesosMetrics(mesosSlaveCumulativeCountersList, "the metrics of /metrics/snapshot of the slave")
+}
+
 // Configure Override *baseCollector.Configure(). Will create the required MesosLeaderElect instance.
 func (m *MesosSlaveStats) Configure(configMap map[string]interface{}) {
 	m.configureCommonParams(configMap)
@@ -94,7 +113,13 @@ func (m *MesosSlaveStats) Collect() {
 		m.log.Error("Cannot get external IP. Skipping collection.")
 		return
 	}
-	go m.sendMetrics()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.sendMetrics()
+	}()
+	m.waitFor(&wg)
 }
 
 // sendMetrics Send to baseCollector channel.
@@ -109,7 +134,7 @@ func (m *MesosSlaveStats) sendMetrics() {
 // getMetrics Get metrics from the :5051/metrics/snapshot mesos endpoint.
 func (m *MesosSlaveStats) getSlaveMetrics(ip string) map[string]float64 {
 	url := getSlaveMetricsURL(m, ip)
-	r, err := m.client.Get(url)
+	r, err := getWithContext(m.Context(), &m.client, url)
 
 	if err != nil {
 		m.log.Error("Could not load metrics from mesos", err.Error())
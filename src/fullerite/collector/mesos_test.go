@@ -101,7 +101,8 @@ func TestMesosStatsCollect(t *testing.T) {
 	newMLE = func() util.MesosLeaderElectInterface { return &MockMLE{} }
 
 	sendMetricsCalled := false
-	c := make(chan bool)
+	// Collect waits for sendMetrics to return
+	c := make(chan bool, 1)
 	sendMetrics = func(m *MesosStats) {
 		sendMetricsCalled = true
 		c <- true
@@ -141,7 +142,7 @@ func TestMesosStatsSendMetrics(t *testing.T) {
 	oldGetMetrics := getMetrics
 	defer func() { getMetrics = oldGetMetrics }()
 
-	expected := metric.Metric{"mesos.test", "gauge", 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "mesos.test", MetricType: "gauge", Value: 0.1, Dimensions: map[string]string{}}
 	getMetrics = func(m *MesosStats, ip string) map[string]float64 {
 		return map[string]float64{
 			"test": 0.1,
@@ -224,7 +225,7 @@ func TestMesosStatsGetMetricsHandleNon200s(t *testing.T) {
 }
 
 func TestMesosStatsBuildMetric(t *testing.T) {
-	expected := metric.Metric{"mesos.test", "gauge", 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "mesos.test", MetricType: "gauge", Value: 0.1, Dimensions: map[string]string{}}
 
 	actual := buildMetric("test", 0.1)
 
@@ -232,7 +233,7 @@ func TestMesosStatsBuildMetric(t *testing.T) {
 }
 
 func TestMesosStatsBuildMetricCumCounter(t *testing.T) {
-	expected := metric.Metric{"mesos.master.slave_reregistrations", metric.CumulativeCounter, 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "mesos.master.slave_reregistrations", MetricType: metric.CumulativeCounter, Value: 0.1, Dimensions: map[string]string{}}
 
 	actual := buildMetric("master.slave_reregistrations", 0.1)
 
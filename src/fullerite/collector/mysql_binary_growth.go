@@ -7,6 +7,7 @@ import (
 	"path"
 	"strings"
 
+	"fullerite/config"
 	"fullerite/metric"
 	"fullerite/util"
 
@@ -63,6 +64,25 @@ func newMySQLBinlogGrowth(channel chan metric.Metric, initialInterval int, log *
 	return d
 }
 
+// ConfigKeys describes the keys of the MySQLBinlogGrowth config
+func (m *MySQLBinlogGrowth) ConfigKeys() []config.Key {
+	return append(m.baseCollector.ConfigKeys(),
+		config.Key{Name: "mycnf", Type: config.TypeString, Default: defaultCnfPath, Description: "MySQL config naming the binlog and data directory"},
+	)
+}
+
+// Description of the MySQLBinlogGrowth collector
+func (m *MySQLBinlogGrowth) Description() string {
+	return "Emits the total size of the MySQL binlogs, which graphs as their growth rate"
+}
+
+// Metrics the MySQLBinlogGrowth collector emits
+func (m *MySQLBinlogGrowth) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "mysql.binlog_growth_rate", MetricType: metric.CumulativeCounter, Description: "total size of the binlogs in bytes"},
+	}
+}
+
 // Configure takes a dictionary of values with which the handler can configure itself.
 func (m *MySQLBinlogGrowth) Configure(configMap map[string]interface{}) {
 	m.configureCommonParams(configMap)
@@ -3,6 +3,7 @@ package collector
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"fullerite/config"
@@ -11,6 +12,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -85,6 +87,38 @@ func newNerveHTTPD(channel chan metric.Metric, initialInterval int, log *l.Entry
 	return c
 }
 
+// ConfigKeys describes the keys of the NerveHTTPD config
+func (c *NerveHTTPD) ConfigKeys() []config.Key {
+	return append(c.baseCollector.ConfigKeys(),
+		config.Key{Name: "queryPath", Type: config.TypeString, Default: "server-status?auto", Description: "path of the Apache status page"},
+		config.Key{Name: "configFilePath", Type: config.TypeString, Default: "/etc/nerve/nerve.conf.json", Description: "Nerve config listing the services"},
+		config.Key{Name: "host", Type: config.TypeString, Default: "localhost", Description: "host the services listen on"},
+		config.Key{Name: "status_ttl", Type: config.TypeInt, Default: 3600, Description: "seconds to skip a service whose status page was not found"},
+		config.Key{Name: "servicesWhitelist", Type: config.TypeList, Description: "services to collect from, as <name>.<namespace>"},
+	)
+}
+
+// Description of the NerveHTTPD collector
+func (c *NerveHTTPD) Description() string {
+	return "Emits the Apache status of the services in the Nerve config"
+}
+
+// Metrics the NerveHTTPD collector emits, the service and port are dimensions
+func (c *NerveHTTPD) Metrics() []metric.Info {
+	whiteRegexp := regexp.MustCompile(`\s+`)
+	var names []string
+	for name := range knownApacheMetrics {
+		names = append(names, whiteRegexp.ReplaceAllString(name, ""))
+	}
+	sort.Strings(names)
+
+	infos := make([]metric.Info, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, metric.Info{Name: name, MetricType: metric.Gauge})
+	}
+	return infos
+}
+
 // Configure the collector
 func (c *NerveHTTPD) Configure(configMap map[string]interface{}) {
 	if val, exists := configMap["queryPath"]; exists {
@@ -125,13 +159,19 @@ func (c *NerveHTTPD) Collect() {
 	}
 	c.log.Debug("Finished parsing Nerve config into ", servicePortMap)
 
+	var wg sync.WaitGroup
 	for port, service := range servicePortMap {
 		if c.serviceInWhitelist(service) {
 			if !c.checkIfFailed(service.Name, port) {
-				go c.emitHTTPDMetric(service, port)
+				wg.Add(1)
+				go func(service util.NerveService, port int) {
+					defer wg.Done()
+					c.emitHTTPDMetric(service, port)
+				}(service, port)
 			}
 		}
 	}
+	c.waitFor(&wg)
 }
 
 func (c *NerveHTTPD) serviceInWhitelist(service util.NerveService) bool {
@@ -170,7 +210,7 @@ func (c *NerveHTTPD) getMetrics(service util.NerveService, port int) []metric.Me
 	endpoint := fmt.Sprintf("http://%s:%d/%s", c.host, port, c.queryPath)
 	serviceLog.Debug("making GET request to ", endpoint)
 
-	httpResponse := fetchApacheMetrics(endpoint, port)
+	httpResponse := fetchApacheMetrics(c.Context(), endpoint, port)
 
 	if httpResponse.status != 200 {
 		c.updateFailedStatus(service.Name, port, httpResponse.status)
@@ -258,13 +298,13 @@ func (c *NerveHTTPD) updateFailedStatus(serviceName string, port int, statusCode
 	}
 }
 
-func fetchApacheMetrics(endpoint string, timeout int) *nerveHTTPDResponse {
+func fetchApacheMetrics(ctx context.Context, endpoint string, timeout int) *nerveHTTPDResponse {
 	response := new(nerveHTTPDResponse)
 	client := http.Client{
 		Timeout: time.Duration(timeout) * time.Second,
 	}
 
-	rsp, err := client.Get(endpoint)
+	rsp, err := getWithContext(ctx, &client, endpoint)
 	response.err = err
 	if rsp != nil {
 		response.status = rsp.StatusCode
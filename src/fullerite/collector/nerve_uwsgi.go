@@ -5,6 +5,7 @@ import (
 	"fullerite/metric"
 	"fullerite/util"
 
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -110,6 +112,28 @@ func newNerveUWSGI(channel chan metric.Metric, initialInterval int, log *l.Entry
 	return col
 }
 
+// ConfigKeys describes the keys of the nerveUWSGICollector config
+func (n *nerveUWSGICollector) ConfigKeys() []config.Key {
+	return append(n.baseCollector.ConfigKeys(),
+		config.Key{Name: "queryPath", Type: config.TypeString, Default: "status/metrics", Description: "path of the metrics of the services"},
+		config.Key{Name: "configFilePath", Type: config.TypeString, Default: "/etc/nerve/nerve.conf.json", Description: "Nerve config listing the services"},
+		config.Key{Name: "servicesWhitelist", Type: config.TypeList, Description: "services whose counters are emitted as cumulative counters"},
+	)
+}
+
+// Description of the NerveUWSGI collector
+func (n *nerveUWSGICollector) Description() string {
+	return "Emits the uWSGI, Java or Dropwizard metrics of the services in the Nerve config"
+}
+
+// Metrics the NerveUWSGI collector emits
+func (n *nerveUWSGICollector) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "<name>", Description: "the metrics of the services, the service and port are dimensions"},
+		{Name: "<name>.<rollup>", Description: "the rollups of timers and histograms, e.g. p99 or count"},
+	}
+}
+
 func (n *nerveUWSGICollector) Configure(configMap map[string]interface{}) {
 	if val, exists := configMap["queryPath"]; exists {
 		n.queryPath = val.(string)
@@ -138,9 +162,15 @@ func (n *nerveUWSGICollector) Collect() {
 	}
 	n.log.Debug("Finished parsing Nerve config into ", servicePortMap)
 
+	var wg sync.WaitGroup
 	for port, service := range servicePortMap {
-		go n.queryService(service.Name, port)
+		wg.Add(1)
+		go func(serviceName string, port int) {
+			defer wg.Done()
+			n.queryService(serviceName, port)
+		}(service.Name, port)
 	}
+	n.waitFor(&wg)
 }
 
 func (n *nerveUWSGICollector) queryService(serviceName string, port int) {
@@ -149,7 +179,7 @@ func (n *nerveUWSGICollector) queryService(serviceName string, port int) {
 	endpoint := fmt.Sprintf("http://localhost:%d/%s", port, n.queryPath)
 	serviceLog.Debug("making GET request to ", endpoint)
 
-	rawResponse, schemaVer, err := queryEndpoint(endpoint, n.timeout)
+	rawResponse, schemaVer, err := queryEndpoint(n.Context(), endpoint, n.timeout)
 	if err != nil {
 		serviceLog.Warn("Failed to query endpoint ", endpoint, ": ", err)
 		return
@@ -171,12 +201,12 @@ func (n *nerveUWSGICollector) queryService(serviceName string, port int) {
 	}
 }
 
-func queryEndpoint(endpoint string, timeout int) ([]byte, string, error) {
+func queryEndpoint(ctx context.Context, endpoint string, timeout int) ([]byte, string, error) {
 	client := http.Client{
 		Timeout: time.Duration(timeout) * time.Second,
 	}
 
-	rsp, err := client.Get(endpoint)
+	rsp, err := getWithContext(ctx, &client, endpoint)
 
 	if rsp != nil {
 		defer func() {
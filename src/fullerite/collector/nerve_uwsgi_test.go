@@ -6,6 +6,7 @@ import (
 	"path"
 	"test_utils"
 
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -504,7 +505,7 @@ func TestErrorQueryEndpointResponse(t *testing.T) {
 	endpoint := ts.URL + "/status/metrics"
 	ts.Close()
 
-	_, _, queryEndpointError := queryEndpoint(endpoint, 10)
+	_, _, queryEndpointError := queryEndpoint(context.Background(), endpoint, 10)
 	assert.NotNil(t, queryEndpointError)
 
 	//Socket closed test
@@ -513,7 +514,7 @@ func TestErrorQueryEndpointResponse(t *testing.T) {
 	}))
 	tsClosed.Close()
 	closedEndpoint := tsClosed.URL + "/status/metrics"
-	_, queryClosedEndpointResponse, queryClosedEndpointError := queryEndpoint(closedEndpoint, 10)
+	_, queryClosedEndpointResponse, queryClosedEndpointError := queryEndpoint(context.Background(), closedEndpoint, 10)
 	assert.NotNil(t, queryClosedEndpointError)
 	assert.Equal(t, "", queryClosedEndpointResponse)
 
@@ -18,12 +18,12 @@ type ProcStatus struct {
 }
 
 // Pattern returns ProcStatus collectors search pattern
-func (ps ProcStatus) Pattern() *regexp.Regexp {
+func (ps *ProcStatus) Pattern() *regexp.Regexp {
 	return ps.pattern
 }
 
 // MatchCommandLine returns ProcStatus collectors matches command line
-func (ps ProcStatus) MatchCommandLine() bool {
+func (ps *ProcStatus) MatchCommandLine() bool {
 	return ps.matchCommandLine
 }
 
@@ -47,6 +47,32 @@ func newProcStatus(channel chan metric.Metric, initialInterval int, log *l.Entry
 	return ps
 }
 
+// ConfigKeys describes the keys of the ProcStatus config
+func (ps *ProcStatus) ConfigKeys() []config.Key {
+	return append(ps.baseCollector.ConfigKeys(),
+		config.Key{Name: "pattern", Type: config.TypeString, Default: "", Description: "regexp the processes to collect from match"},
+		config.Key{Name: "matchCommandLine", Type: config.TypeBool, Default: true,
+			Description: "match the pattern against the command line rather than the process name"},
+		config.Key{Name: "generatedDimensions", Type: config.TypeMap,
+			Description: "dimensions taken from the command line, by the regexp that extracts them"},
+	)
+}
+
+// Description of the ProcStatus collector
+func (ps *ProcStatus) Description() string {
+	return "Emits the memory and CPU usage of the processes matching a pattern"
+}
+
+// Metrics the ProcStatus collector emits, the process name and pid are
+// dimensions
+func (ps *ProcStatus) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "VirtualMemory", MetricType: metric.Gauge, Description: "virtual memory size in bytes"},
+		{Name: "ResidentMemory", MetricType: metric.Gauge, Description: "resident set size in bytes"},
+		{Name: "CPUTime", MetricType: metric.CumulativeCounter, Description: "CPU time in seconds"},
+	}
+}
+
 // Configure this takes a dictionary of values with which the handler can configure itself
 func (ps *ProcStatus) Configure(configMap map[string]interface{}) {
 	if pattern, exists := configMap["pattern"]; exists {
@@ -12,7 +12,7 @@ import (
 )
 
 // Collect produces some random test metrics.
-func (ps ProcStatus) Collect() {
+func (ps *ProcStatus) Collect() {
 	for _, m := range ps.procStatusMetrics() {
 		ps.Channel() <- m
 	}
@@ -26,7 +26,7 @@ func procStatusPoint(name string, value float64, dimensions map[string]string, m
 	return m
 }
 
-func (ps ProcStatus) getMetrics(proc procfs.Proc, cmdOutput []string) []metric.Metric {
+func (ps *ProcStatus) getMetrics(proc procfs.Proc, cmdOutput []string) []metric.Metric {
 	stat, err := proc.NewStat()
 	if err != nil {
 		ps.log.Warn("Error getting stats: ", err)
@@ -54,7 +54,7 @@ func (ps ProcStatus) getMetrics(proc procfs.Proc, cmdOutput []string) []metric.M
 	return ret
 }
 
-func (ps ProcStatus) procStatusMetrics() []metric.Metric {
+func (ps *ProcStatus) procStatusMetrics() []metric.Metric {
 	procs, err := procfs.AllProcs()
 	if err != nil {
 		ps.log.Warn("Error getting processes: ", err)
@@ -78,7 +78,7 @@ func (ps ProcStatus) procStatusMetrics() []metric.Metric {
 	return ret
 }
 
-func (ps ProcStatus) extractDimensions(cmd string) map[string]string {
+func (ps *ProcStatus) extractDimensions(cmd string) map[string]string {
 	ret := map[string]string{}
 
 	for dimension, procRegex := range ps.compiledRegex {
@@ -91,7 +91,7 @@ func (ps ProcStatus) extractDimensions(cmd string) map[string]string {
 	return ret
 }
 
-func (ps ProcStatus) matches(cmdline []string, comm func() (string, error)) bool {
+func (ps *ProcStatus) matches(cmdline []string, comm func() (string, error)) bool {
 	var s string
 	if ps.matchCommandLine {
 		s = strings.Join(cmdline, " ")
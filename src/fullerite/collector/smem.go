@@ -38,7 +38,7 @@ type SmemStats struct {
 var (
 	requiredConfigs = []string{"user", "procsWhitelist"}
 
-	execCommand   = exec.Command
+	execCommand   = exec.CommandContext
 	commandOutput = (*exec.Cmd).Output
 	getSmemStats  = (*SmemStats).getSmemStats
 	allMetrics    = []string{"rss", "vss", "pss"}
@@ -58,6 +58,30 @@ func newSmemStats(channel chan metric.Metric, initialInterval int, log *l.Entry)
 	return s
 }
 
+// ConfigKeys describes the keys of the SmemStats config
+func (s *SmemStats) ConfigKeys() []config.Key {
+	return append(s.baseCollector.ConfigKeys(),
+		config.Key{Name: "user", Type: config.TypeString, Required: true, Description: "user to run smem as"},
+		config.Key{Name: "procsWhitelist", Type: config.TypeString, Required: true, Description: "regexp of the processes to report on"},
+		config.Key{Name: "smemPath", Type: config.TypeString, Required: true, Description: "path of the smem executable"},
+		config.Key{Name: "metricsBlacklist", Type: config.TypeList, Description: "of rss, vss and pss, the ones not to emit"},
+	)
+}
+
+// Description of the SmemStats collector
+func (s *SmemStats) Description() string {
+	return "Emits the memory usage smem reports for the whitelisted processes"
+}
+
+// Metrics the SmemStats collector emits
+func (s *SmemStats) Metrics() []metric.Info {
+	infos := make([]metric.Info, 0, len(allMetrics))
+	for _, name := range allMetrics {
+		infos = append(infos, metric.Info{Name: "<process>.smem." + name, MetricType: metric.Gauge})
+	}
+	return infos
+}
+
 // Configure Override *baseCollector.Configure(); will fetch the whitelisted processes
 func (s *SmemStats) Configure(configMap map[string]interface{}) {
 	s.configureCommonParams(configMap)
@@ -112,6 +136,7 @@ func (s *SmemStats) getSmemStats() []smemStatLine {
 	var err error
 
 	cmd := execCommand(
+		s.Context(),
 		"/usr/bin/sudo",
 		"-u", s.user,
 		s.smemPath,
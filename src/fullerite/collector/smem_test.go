@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"fullerite/metric"
 	"os/exec"
 	"testing"
@@ -79,7 +80,7 @@ func TestSmemStatsCollect(t *testing.T) {
 		commandOutput = oldCommandOutput
 	}()
 
-	execCommand = func(string, ...string) *exec.Cmd {
+	execCommand = func(context.Context, string, ...string) *exec.Cmd {
 		return &exec.Cmd{}
 	}
 
@@ -37,6 +37,25 @@ func newSocketQueue(channel chan metric.Metric, initialInterval int, log *l.Entr
 	return ss
 }
 
+// ConfigKeys describes the keys of the SocketQueue config
+func (ss *SocketQueue) ConfigKeys() []config.Key {
+	return append(ss.baseCollector.ConfigKeys(),
+		config.Key{Name: "PortList", Type: config.TypeList, Required: true, Description: "ports of the listening sockets"},
+	)
+}
+
+// Description of the SocketQueue collector
+func (ss *SocketQueue) Description() string {
+	return "Emits the receive queue size of listening TCP sockets"
+}
+
+// Metrics the SocketQueue collector emits
+func (ss *SocketQueue) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "sq.listen", MetricType: metric.Gauge, Description: "receive queue size, the port is a dimension"},
+	}
+}
+
 // Configure Override default parameters
 func (ss *SocketQueue) Configure(configMap map[string]interface{}) {
 	ss.configureCommonParams(configMap)
@@ -48,7 +67,7 @@ func (ss *SocketQueue) Configure(configMap map[string]interface{}) {
 }
 
 // Collect the receive queue size (RecvQ)
-func (ss SocketQueue) Collect() {
+func (ss *SocketQueue) Collect() {
 	if len(ss.portList) == 0 {
 		ss.log.Warn("At least one port must be specified in the config")
 		return
@@ -59,7 +78,7 @@ func (ss SocketQueue) Collect() {
 	*/
 	filter := "sport = :" + strings.Join(ss.portList, " or sport = :")
 
-	cmd := exec.Command("ss", "-ntl", filter)
+	cmd := exec.CommandContext(ss.Context(), "ss", "-ntl", filter)
 	output, err := cmdOutput(cmd)
 	if err != nil {
 		ss.log.Error("Error while collecting metrics: ", err)
@@ -68,7 +87,7 @@ func (ss SocketQueue) Collect() {
 	ss.emitSocketQueueMetrics(output)
 }
 
-func (ss SocketQueue) emitSocketQueueMetrics(output []byte) {
+func (ss *SocketQueue) emitSocketQueueMetrics(output []byte) {
 	// Capture the receive queue size and the corres. port number from the output.
 	lines := strings.Split(string(output), "\n")
 
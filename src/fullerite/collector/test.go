@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
 
 	"math/rand"
@@ -40,6 +41,25 @@ func NewTest(channel chan metric.Metric, initialInterval int, log *l.Entry) Coll
 	return t
 }
 
+// ConfigKeys describes the keys of the Test config
+func (t *Test) ConfigKeys() []config.Key {
+	return append(t.baseCollector.ConfigKeys(),
+		config.Key{Name: "metricName", Type: config.TypeString, Default: "TestMetric", Description: "name of the metric"},
+	)
+}
+
+// Description of the Test collector
+func (t *Test) Description() string {
+	return "Emits a random value, for testing"
+}
+
+// Metrics the Test collector emits
+func (t *Test) Metrics() []metric.Info {
+	return []metric.Info{
+		{Name: "<metricName>", MetricType: metric.Gauge, Description: "a random value"},
+	}
+}
+
 // Configure this takes a dictionary of values with which the handler can configure itself
 func (t *Test) Configure(configMap map[string]interface{}) {
 	if metricName, exists := configMap["metricName"]; exists {
@@ -49,7 +69,7 @@ func (t *Test) Configure(configMap map[string]interface{}) {
 }
 
 // Collect produces some random test metrics.
-func (t Test) Collect() {
+func (t *Test) Collect() {
 	metric := metric.New(t.metricName)
 	metric.Value = t.generator()
 	metric.AddDimension("testing", "yes")
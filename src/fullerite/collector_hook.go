@@ -4,15 +4,15 @@ import (
 	"fmt"
 	"os"
 
-	"fullerite/handler"
 	"fullerite/metric"
+	"fullerite/stats"
 
 	"github.com/Sirupsen/logrus"
 )
 
 // LogErrorHook to send errors via handlers.
 type LogErrorHook struct {
-	handlers []handler.Handler
+	handlers *handlerSet
 
 	// intentionally exported
 	log *logrus.Entry
@@ -20,7 +20,7 @@ type LogErrorHook struct {
 
 // NewLogErrorHook creates a hook to be added to the collector logger
 // so that errors are forwarded as a metric to the handlers.
-func NewLogErrorHook(handlers []handler.Handler) *LogErrorHook {
+func NewLogErrorHook(handlers *handlerSet) *LogErrorHook {
 	hookLog := log.WithFields(logrus.Fields{"hook": "LogErrorHook"})
 	return &LogErrorHook{handlers, hookLog}
 }
@@ -33,6 +33,11 @@ func (hook *LogErrorHook) Fire(entry *logrus.Entry) error {
 		return err
 	}
 
+	// counted right away so that the run in progress is not taken for
+	// a success
+	if name, ok := entry.Data["collector"].(string); ok {
+		stats.Default.AddError(name)
+	}
 	go hook.reportErrors(entry)
 	return nil
 }
@@ -49,10 +54,10 @@ func (hook *LogErrorHook) Levels() []logrus.Level {
 func (hook *LogErrorHook) reportErrors(entry *logrus.Entry) {
 	metric := metric.New("fullerite.collector_errors")
 	metric.Value = 1
-	if val, exists := entry.Data["collector"]; exists {
-		metric.AddDimension("collector", val.(string))
+	if name, ok := entry.Data["collector"].(string); ok {
+		metric.AddDimension("collector", name)
 	}
 
-	writeToHandlers(hook.handlers, metric)
+	hook.handlers.write(metric)
 	return
 }
@@ -7,6 +7,7 @@ import (
 	"fullerite/collector"
 	"fullerite/handler"
 	"fullerite/metric"
+	"fullerite/stats"
 	"test_utils"
 
 	"github.com/stretchr/testify/assert"
@@ -25,7 +26,7 @@ func TestCollectorLogsErrors(t *testing.T) {
 	timeout := time.Duration(5 * time.Second)
 	h := handler.NewTest(channel, 10, 10, timeout, testLogger)
 
-	hook := NewLogErrorHook([]handler.Handler{h})
+	hook := NewLogErrorHook(newHandlerSet([]handler.Handler{h}, nil))
 	testLogger.Logger.Hooks.Add(hook)
 
 	go testCol.Collect()
@@ -36,8 +37,15 @@ func TestCollectorLogsErrors(t *testing.T) {
 		assert.Equal(t, "fullerite.collector_errors", m.Name)
 		assert.Equal(t, 1.0, m.Value)
 		assert.Equal(t, "Test", m.Dimensions["collector"])
+		assert.True(t, stats.Default.Collectors()["Test"].Errors > 0, "should count the error")
 		return
 	case <-time.After(1 * time.Second):
 		t.Fail()
 	}
 }
+
+func TestCollectorHookIgnoresOddCollectorFields(t *testing.T) {
+	hook := NewLogErrorHook(newHandlerSet(nil, nil))
+	entry := test_utils.BuildLogger().WithField("collector", 42)
+	assert.NotPanics(t, func() { hook.Fire(entry) })
+}
@@ -3,26 +3,77 @@ package main
 import (
 	"fullerite/collector"
 	"fullerite/config"
-	"fullerite/handler"
 	"fullerite/metric"
+	"fullerite/stats"
 
+	"context"
 	"fmt"
 	"regexp"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// runningCollectors has a channel per collector that is closed once the
+// goroutine scheduling the collector returned, so that shutdown can wait for
+// the collections in progress. The controls let the control API of the
+// internal server steer the schedule.
+var runningCollectors = struct {
+	sync.Mutex
+	done     map[collector.Collector]chan struct{}
+	controls map[collector.Collector]*collectorControl
+}{
+	done:     make(map[collector.Collector]chan struct{}),
+	controls: make(map[collector.Collector]*collectorControl),
+}
+
+// collectorControl runs a collector right away or pauses its schedule
+type collectorControl struct {
+	trigger chan struct{}
+	paused  int32
+	sched   schedule
+}
+
+func newCollectorControl(sched schedule) *collectorControl {
+	return &collectorControl{trigger: make(chan struct{}, 1), sched: sched}
+}
+
+// collectNow asks for a run, a run that was asked for and has not started
+// yet is not asked for twice
+func (c *collectorControl) collectNow() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// setPaused makes the scheduled runs be skipped or taken again, runs that
+// are asked for still happen
+func (c *collectorControl) setPaused(paused bool) {
+	value := int32(0)
+	if paused {
+		value = 1
+	}
+	atomic.StoreInt32(&c.paused, value)
+}
+
+func (c *collectorControl) isPaused() bool {
+	return atomic.LoadInt32(&c.paused) == 1
+}
+
+// collectorControlOf returns the control of a running collector
+func collectorControlOf(c collector.Collector) (*collectorControl, bool) {
+	runningCollectors.Lock()
+	defer runningCollectors.Unlock()
+	control, exists := runningCollectors.controls[c]
+	return control, exists
+}
+
 func startCollectors(c config.Config) (collectors []collector.Collector) {
 	log.Info("Starting collectors...")
 
 	for _, name := range c.Collectors {
-		configFile := strings.Join([]string{c.CollectorsConfigPath, name}, "/") + ".conf"
-		// Since collector naems can be defined with a space in order to instantiate multiple
-		// instances of the same collector, we want their files
-		// will not have that space and needs to have it replaced with an underscore
-		// instead
-		configFile = strings.Replace(configFile, " ", "_", -1)
-		conf, err := config.ReadCollectorConfig(configFile)
+		conf, err := readCollectorConfig(c, name)
 		if err != nil {
 			log.Error("Collector config failed to load for: ", name)
 			continue
@@ -36,6 +87,19 @@ func startCollectors(c config.Config) (collectors []collector.Collector) {
 	return collectors
 }
 
+// readCollectorConfig returns the inline config of a collector if it has
+// one, and reads its config file otherwise
+func readCollectorConfig(c config.Config, name string) (map[string]interface{}, error) {
+	if conf, exists := c.CollectorConfigs[name]; exists {
+		return conf, nil
+	}
+	return config.ReadCollectorConfig(collectorConfigFile(c, name))
+}
+
+func collectorConfigFile(c config.Config, name string) string {
+	return config.CollectorConfigFile(c.CollectorsConfigPath, name)
+}
+
 func startCollector(name string, globalConfig config.Config, instanceConfig map[string]interface{}) collector.Collector {
 	log.Debug("Starting collector ", name)
 	collectorInst := collector.New(name)
@@ -49,109 +113,282 @@ func startCollector(name string, globalConfig config.Config, instanceConfig map[
 	// apply the instance configs
 	collectorInst.Configure(instanceConfig)
 
-	go runCollector(collectorInst)
+	sched, err := newSchedule(name, collectorInst.Interval(), instanceConfig)
+	if err != nil {
+		log.Error("Invalid schedule for collector ", name, ", running it every interval: ", err)
+		sched, _ = newSchedule(name, collectorInst.Interval(), nil)
+	}
+
+	done := make(chan struct{})
+	control := newCollectorControl(sched)
+	runningCollectors.Lock()
+	runningCollectors.done[collectorInst] = done
+	runningCollectors.controls[collectorInst] = control
+	runningCollectors.Unlock()
+
+	go func() {
+		defer close(done)
+		runCollector(collectorInst, sched, control)
+	}()
 	return collectorInst
 }
 
-func runCollector(collector collector.Collector) {
+func runCollector(collector collector.Collector, sched schedule, control *collectorControl) {
 	log.Info("Running ", collector)
 
-	ticker := time.NewTicker(time.Duration(collector.Interval()) * time.Second)
-	collect := ticker.C
+	next := sched.next(time.Now())
+	timer := time.NewTimer(next.Sub(time.Now()))
+	defer timer.Stop()
+
+	// a run has until the next one is due
+	staggerValue := 1 * time.Second
 
-	staggerValue := 1
-	collectionDeadline := time.Duration(collector.Interval() + staggerValue)
+	// running is closed once the run in progress returned
+	var running chan struct{}
+	cancelRun := func() {}
+	defer func() {
+		if running != nil {
+			<-running
+		}
+		cancelRun()
+		log.Info("Stopped ", collector)
+	}()
 
 	for {
 		select {
-		case <-collect:
-			if collector.CollectorType() == "listener" {
-				collector.Collect()
-			} else {
-				countdownTimer := time.AfterFunc(collectionDeadline*time.Second, func() {
-					reportCollector(collector)
-				})
-				collector.Collect()
-				countdownTimer.Stop()
+		case <-collector.Stopping():
+			return
+		case <-control.trigger:
+			log.Info("Running ", collector, " on request")
+		case <-timer.C:
+			// a tick may be pending as well once we are asked to stop,
+			// the stop wins
+			select {
+			case <-collector.Stopping():
+				return
+			default:
+			}
+
+			now := time.Now()
+			next = sched.next(now)
+			timer.Reset(next.Sub(now))
+
+			if control.isPaused() {
+				log.Debug(collector, " is paused, skipping this run")
+				continue
+			}
+		}
+
+		if collector.CollectorType() == "listener" {
+			collector.Collect()
+			continue
+		}
+
+		if running != nil {
+			select {
+			case <-running:
+			default:
+				reportSkipped(collector)
+				continue
 			}
 		}
+
+		// the context of the previous run is only released now since
+		// some collectors keep working on it after Collect() returned
+		cancelRun()
+		ctx, cancel := context.WithDeadline(context.Background(), next.Add(staggerValue))
+		cancelRun = cancel
+		collector.SetContext(ctx)
+
+		running = make(chan struct{})
+		go collectOnce(collector, ctx, running)
 	}
-	ticker.Stop()
 }
 
-func readFromCollectors(collectors []collector.Collector,
-	handlers []handler.Handler,
-	collectorStatChans ...chan<- metric.CollectorEmission) {
-	for i := range collectors {
-		go readFromCollector(collectors[i], handlers, collectorStatChans...)
-	}
-}
-
-func readFromCollector(collector collector.Collector,
-	handlers []handler.Handler,
-	collectorStatChans ...chan<- metric.CollectorEmission) {
-	// In case of Diamond collectors, metric from multiple collectors are read
-	// from Single channel (owned by Go Diamond Collector) and hence we use a map
-	// for keeping track of metrics from each individual collector
-	emissionCounter := map[string]uint64{}
-	lastEmission := time.Now()
-	statDuration := time.Duration(collector.Interval()) * time.Second
-	for m := range collector.Channel() {
-		var exists bool
-		c := collector.CanonicalName()
-		if _, exists = m.GetDimensionValue("collector"); !exists {
-			m.AddDimension("collector", collector.Name())
-		}
-		// We allow external collectors to provide us their collector's CanonicalName
-		// by sending it as a metric dimension. For example in the case of Diamond the
-		// individual python collectors can send their names this way.
-		if val, ok := m.GetDimensionValue("collectorCanonicalName"); ok {
-			c = val
-			m.RemoveDimension("collectorCanonicalName")
+// collectOnce runs the collector and reports it if it is still running once
+// the context is done. The done channel is closed after the report is out,
+// so that the reader of the collector is not stopped before.
+func collectOnce(collector collector.Collector, ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+
+	run := stats.Default.StartRun(collector.CanonicalName())
+	finished := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			reportCollector(collector)
 		}
-		// check if the metric is blacklisted, if so skip it and
-		// process the next one
-		if stringInSlice(m.Name, collector.Blacklist()) {
+	}()
+
+	collector.Collect()
+	close(finished)
+	<-reported
+	run.Finish()
+}
+
+// stopCollectors stops all collectors and waits for the collections that are
+// in progress to finish, for no longer than the timeout. Collectors that do
+// not heed their context may not finish at all, they are left behind.
+func stopCollectors(collectors []collector.Collector, timeout time.Duration) {
+	for _, c := range collectors {
+		c.Stop()
+	}
+	deadline := time.Now().Add(timeout)
+	for _, c := range collectors {
+		runningCollectors.Lock()
+		done, exists := runningCollectors.done[c]
+		delete(runningCollectors.done, c)
+		delete(runningCollectors.controls, c)
+		runningCollectors.Unlock()
+
+		if !exists {
 			continue
 		}
-		emissionCounter[c]++
-		// collectorStatChans is an optional parameter. In case of ad-hoc collector
-		// this parameter is not supplied at all. Using variadic arguments is pretty much
-		// only way of doing this in go.
-		if len(collectorStatChans) > 0 {
-			collectorStatChan := collectorStatChans[0]
-			currentTime := time.Now()
-			if currentTime.After(lastEmission.Add(statDuration)) {
-				emitCollectorStats(emissionCounter, collectorStatChan)
-				lastEmission = time.Now()
-			}
+		select {
+		case <-done:
+		case <-time.After(deadline.Sub(time.Now())):
+			log.Warn(c.CanonicalName(), " collector did not stop within ", timeout, ", leaving it behind")
 		}
+	}
+}
 
-		if len(collector.Prefix()) > 0 {
-			m.Name = collector.Prefix() + m.Name
+// collectorReaders has a channel per collector that is closed to stop the
+// reader of the collector. The channels of the collectors themselves are
+// never closed, collectors may still have goroutines around that emit.
+var collectorReaders = struct {
+	sync.Mutex
+	stop map[collector.Collector]chan struct{}
+}{
+	stop: make(map[collector.Collector]chan struct{}),
+}
+
+// readFromCollectors starts reading from all collectors. The returned wait
+// group is done once the readers of all collectors were stopped.
+func readFromCollectors(collectors []collector.Collector, handlers *handlerSet) *sync.WaitGroup {
+	readers := new(sync.WaitGroup)
+	for i := range collectors {
+		startReader(readers, collectors[i], handlers)
+	}
+	return readers
+}
+
+// startReader reads from the collector in the background, the wait group
+// is done and the returned channel closed once the reader was stopped with
+// stopReaders and drained the channel of the collector.
+func startReader(readers *sync.WaitGroup, collector collector.Collector, handlers *handlerSet) <-chan struct{} {
+	stop := make(chan struct{})
+	collectorReaders.Lock()
+	collectorReaders.stop[collector] = stop
+	collectorReaders.Unlock()
+
+	done := make(chan struct{})
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		defer close(done)
+		readFromCollector(collector, handlers, stop)
+	}()
+	return done
+}
+
+// stopReaders stops the readers of the collectors, which should have been
+// stopped with stopCollectors before so that nothing is emitted anymore
+func stopReaders(collectors []collector.Collector) {
+	collectorReaders.Lock()
+	defer collectorReaders.Unlock()
+	for _, c := range collectors {
+		if stop, exists := collectorReaders.stop[c]; exists {
+			close(stop)
+			delete(collectorReaders.stop, c)
 		}
+	}
+}
 
-		for i := range handlers {
-			if _, exists := handlers[i].CollectorChannels()[c]; exists {
-				handlers[i].CollectorChannels()[c] <- m
+// readFromCollector hands the metrics of the collector to the handlers and
+// counts them in the stats of the collector until the channel of the
+// collector is closed, or the stop channel is closed and what is left on the
+// channel was read. In case of Diamond collectors, metrics from multiple
+// collectors are read from a single channel (owned by the Go Diamond
+// collector) and counted by the name each metric carries.
+func readFromCollector(collector collector.Collector, handlers *handlerSet, stop <-chan struct{}) {
+	for {
+		var m metric.Metric
+		var open bool
+		select {
+		case m, open = <-collector.Channel():
+		case <-stop:
+			// a stopped collector emits nothing anymore, the
+			// metrics left are picked up without waiting
+			select {
+			case m, open = <-collector.Channel():
+			default:
+				return
 			}
 		}
-	}
-	// Closing the stat channel after collector loop finishes
-	for _, statChannel := range collectorStatChans {
-		close(statChannel)
+		if !open {
+			return
+		}
+
+		c, keep := prepareMetric(collector, &m)
+		if !keep {
+			stats.Default.AddBlacklisted(c, 1)
+			continue
+		}
+		stats.Default.AddEmitted(c, 1)
+
+		if dropped := handlers.enqueue(c, m); dropped > 0 {
+			stats.Default.AddDropped(c, dropped)
+		}
 	}
 }
 
-func emitCollectorStats(data map[string]uint64,
-	collectorStatChan chan<- metric.CollectorEmission) {
-	for collectorName, count := range data {
-		collectorStatChan <- metric.CollectorEmission{collectorName, count}
+// prepareMetric turns a metric of the collector into what the handlers get:
+// it gets the collector dimension, the prefix of the collector and a
+// timestamp. It returns the canonical name of the collector the metric
+// belongs to, and false if the metric is blacklisted.
+func prepareMetric(collector collector.Collector, m *metric.Metric) (string, bool) {
+	c := collector.CanonicalName()
+	if _, exists := m.GetDimensionValue("collector"); !exists {
+		m.AddDimension("collector", collector.Name())
 	}
+	// We allow external collectors to provide us their collector's CanonicalName
+	// by sending it as a metric dimension. For example in the case of Diamond the
+	// individual python collectors can send their names this way.
+	if val, ok := m.GetDimensionValue("collectorCanonicalName"); ok {
+		c = val
+		m.RemoveDimension("collectorCanonicalName")
+	}
+	// check if the metric is blacklisted, if so skip it and
+	// process the next one
+	if stringInSlice(m.Name, collector.Blacklist()) {
+		return c, false
+	}
+
+	if len(collector.Prefix()) > 0 {
+		m.Name = collector.Prefix() + m.Name
+	}
+
+	// collectors that do not set a timestamp themselves get stamped
+	// with the time we read the point off their channel
+	m.SetTimestampIfUnset(time.Now())
+	return c, true
+}
+
+func reportSkipped(collector collector.Collector) {
+	log.Warn(fmt.Sprintf("%s collector is still running, skipping this run", collector.Name()))
+	stats.Default.AddSkipped(collector.CanonicalName())
+	metric := metric.New("fullerite.collection_skipped")
+	metric.Value = 1
+	metric.AddDimension("interval", fmt.Sprintf("%d", collector.Interval()))
+	collector.Channel() <- metric
 }
 
 func reportCollector(collector collector.Collector) {
 	log.Warn(fmt.Sprintf("%s collector took too long to run, reporting incident!", collector.Name()))
+	stats.Default.AddTimeout(collector.CanonicalName())
 	metric := metric.New("fullerite.collection_time_exceeded")
 	metric.Value = 1
 	metric.AddDimension("interval", fmt.Sprintf("%d", collector.Interval()))
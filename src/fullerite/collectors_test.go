@@ -5,6 +5,7 @@ import (
 	"fullerite/config"
 	"fullerite/handler"
 	"fullerite/metric"
+	"fullerite/stats"
 	"sync"
 
 	"io/ioutil"
@@ -91,51 +92,91 @@ func TestStartCollectorTooLong(t *testing.T) {
 	c["interval"] = 1
 	collector := startCollector("Test", config.Config{}, c)
 
-	select {
-	case m := <-collector.Channel():
-		assert.Equal(t, 1.0, m.Value)
-		assert.Equal(t, "fullerite.collection_time_exceeded", m.Name)
-		assert.Equal(t, "1", m.Dimensions["interval"])
-		return
-	case <-time.After(5 * time.Second):
-		t.Fail()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-collector.Channel():
+			// the runs in between are skipped
+			if m.Name == "fullerite.collection_skipped" {
+				continue
+			}
+			assert.Equal(t, 1.0, m.Value)
+			assert.Equal(t, "fullerite.collection_time_exceeded", m.Name)
+			assert.Equal(t, "1", m.Dimensions["interval"])
+			assert.True(t, stats.Default.Collectors()["Test"].Timeouts > 0, "should count the timeout")
+			return
+		case <-timeout:
+			t.Fatal("should report the collector")
+		}
+	}
+}
+
+func TestStartCollectorSkipsOverlappingRuns(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+	c := make(map[string]interface{})
+	c["interval"] = 1
+	collector := startCollector("Test Overlapping", config.Config{}, c)
+	defer collector.Stop()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-collector.Channel():
+			if m.Name != "fullerite.collection_skipped" {
+				continue
+			}
+			assert.Equal(t, "1", m.Dimensions["interval"])
+			assert.True(t, stats.Default.Collectors()["Test Overlapping"].Skipped > 0, "should count the skipped run")
+			return
+		case <-timeout:
+			t.Fatal("should skip a run while the previous one is in progress")
+		}
 	}
 }
 
+func TestStopCollectorsTimeout(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+	col := collector.New("Test Stuck")
+
+	// a collection that never finishes
+	runningCollectors.Lock()
+	runningCollectors.done[col] = make(chan struct{})
+	runningCollectors.Unlock()
+
+	start := time.Now()
+	stopCollectors([]collector.Collector{col}, 100*time.Millisecond)
+	assert.True(t, time.Since(start) < time.Second, "should not wait for the collector past the timeout")
+}
+
 func TestReadFromCollector(t *testing.T) {
 	logrus.SetLevel(logrus.ErrorLevel)
 	c := make(map[string]interface{})
 	c["interval"] = 1
-	collector := collector.New("Test")
+	collector := collector.New("Test ReadFromCollector")
 	collector.SetInterval(1)
 	collector.Configure(c)
 
 	var wg sync.WaitGroup
-	wg.Add(2)
-	collectorStatChannel := make(chan metric.CollectorEmission)
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		collector.Channel() <- metric.New("hello")
 		time.Sleep(time.Duration(2) * time.Second)
 		m2 := metric.New("world")
-		m2.AddDimension("collectorCanonicalName", "Foobar")
+		m2.AddDimension("collectorCanonicalName", "Foobar ReadFromCollector")
 		collector.Channel() <- m2
 		time.Sleep(time.Duration(2) * time.Second)
-		m2.AddDimension("collectorCanonicalName", "Foobar")
-		collector.Channel() <- m2
+		m3 := metric.New("world")
+		m3.AddDimension("collectorCanonicalName", "Foobar ReadFromCollector")
+		collector.Channel() <- m3
 		close(collector.Channel())
 	}()
-	collectorMetrics := map[string]uint64{}
-	go func() {
-		defer wg.Done()
-		for collectorMetric := range collectorStatChannel {
-			collectorMetrics[collectorMetric.Name] = collectorMetric.EmissionCount
-		}
-	}()
-	readFromCollector(collector, []handler.Handler{}, collectorStatChannel)
+	readFromCollector(collector, newHandlerSet([]handler.Handler{}, nil), nil)
 	wg.Wait()
-	assert.Equal(t, uint64(1), collectorMetrics["Test"])
-	assert.Equal(t, uint64(2), collectorMetrics["Foobar"])
+
+	collectorStats := stats.Default.Collectors()
+	assert.Equal(t, uint64(1), collectorStats["Test ReadFromCollector"].PointsEmitted)
+	assert.Equal(t, uint64(2), collectorStats["Foobar ReadFromCollector"].PointsEmitted)
 }
 
 func TestCollectorPrefix(t *testing.T) {
@@ -165,8 +206,9 @@ func TestCollectorPrefix(t *testing.T) {
 		defer wg.Done()
 		testMetric := <-collectorChannel["Test"]
 		assert.Equal(t, "px.hello", testMetric.Name)
+		assert.False(t, testMetric.Timestamp.IsZero(), "timestamp should be filled in")
 	}()
-	readFromCollector(collector, []handler.Handler{testHandler})
+	readFromCollector(collector, newHandlerSet([]handler.Handler{testHandler}, nil), nil)
 	wg.Wait()
 }
 
@@ -176,32 +218,53 @@ func TestCollectorBlacklist(t *testing.T) {
 	c := make(map[string]interface{})
 	c["interval"] = 1
 	c["metrics_blacklist"] = []string{"m[0-9]+$"}
-	col := collector.New("Test")
+	col := collector.New("Test Blacklist")
 	col.SetInterval(1)
 	col.Configure(c)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-	collectorStatChannel := make(chan metric.CollectorEmission)
-
 	go func() {
-		defer wg.Done()
 		col.Channel() <- metric.New("m1")
-		time.Sleep(time.Duration(2) * time.Second)
 		col.Channel() <- metric.New("m2")
-		time.Sleep(time.Duration(2) * time.Second)
 		col.Channel() <- metric.New("metric3")
 		close(col.Channel())
 	}()
-	collectorMetrics := map[string]uint64{}
+	readFromCollector(col, newHandlerSet([]handler.Handler{}, nil), nil)
+
+	collectorStats, _ := stats.Default.Collectors()["Test Blacklist"]
+	assert.Equal(t, uint64(1), collectorStats.PointsEmitted)
+	assert.Equal(t, uint64(2), collectorStats.PointsBlacklisted)
+}
+
+func TestReadFromCollectorDoesNotBlockOnFullHandler(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+	c := make(map[string]interface{})
+	c["interval"] = 1
+	col := collector.New("Test")
+	col.SetInterval(1)
+	col.Configure(c)
+
+	stuckHandler := handler.New("Log")
+	stuckHandler.Configure(map[string]interface{}{
+		"queueCapacity":  1,
+		"overflowPolicy": handler.OverflowDropNewest,
+	})
+	stuckHandler.InitListeners(config.Config{Collectors: []string{"Test"}})
+
+	done := make(chan bool)
 	go func() {
-		defer wg.Done()
-		for collectorMetric := range collectorStatChannel {
-			collectorMetrics[collectorMetric.Name] = collectorMetric.EmissionCount
-		}
+		readFromCollector(col, newHandlerSet([]handler.Handler{stuckHandler}, nil), nil)
+		done <- true
 	}()
-	readFromCollector(col, []handler.Handler{}, collectorStatChannel)
-	wg.Wait()
 
-	assert.Equal(t, uint64(1), collectorMetrics["Test"])
+	col.Channel() <- metric.New("m1")
+	col.Channel() <- metric.New("m2")
+	col.Channel() <- metric.New("m3")
+	close(col.Channel())
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("a full handler queue should not block the collector")
+	}
+	assert.Equal(t, 2.0, stuckHandler.InternalMetrics().Counters["metricsOverflowed"])
 }
@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 
 	"github.com/Sirupsen/logrus"
@@ -22,40 +25,101 @@ type Config struct {
 	Collectors            []string                          `json:"collectors"`
 	DefaultDimensions     map[string]string                 `json:"defaultDimensions"`
 	InternalServerConfig  map[string]interface{}            `json:"internalServer"`
+	ShutdownTimeout       interface{}                       `json:"shutdownTimeout"`
+	Processors            []map[string]interface{}          `json:"processors"`
+	Cardinality           map[string]interface{}            `json:"cardinality"`
+	Include               string                            `json:"include"`
+	CollectorConfigs      map[string]map[string]interface{} `json:"collectorConfigs"`
 }
 
-// ReadConfig reads a fullerite configuration file
+// ReadConfig reads a fullerite configuration file along with the files in
+// its include directory
 func ReadConfig(configFile string) (c Config, e error) {
-	log.Info("Reading configuration file at ", configFile)
-	contents, e := ioutil.ReadFile(configFile)
+	contents, e := ReadConfigMap(configFile)
 	if e != nil {
-		log.Error("Config file error: ", e)
 		return c, e
 	}
-	err := json.Unmarshal(contents, &c)
-	if err != nil {
-		log.Error("Invalid JSON in config: ", err)
-		return c, err
+	c, e = ParseConfig(contents)
+	if e != nil {
+		log.Error("Invalid config in ", configFile, ": ", e)
 	}
-	return c, nil
+	return c, e
 }
 
-// ReadCollectorConfig reads a fullerite collector configuration file
-func ReadCollectorConfig(configFile string) (c map[string]interface{}, e error) {
-	log.Info("Reading collector configuration file at ", configFile)
-	contents, e := ioutil.ReadFile(configFile)
-	if e != nil {
-		log.Error("Config file error: ", e)
+// ParseConfig converts the contents of a configuration file to a Config.
+// Collectors with an inline config are added to the collectors if they are
+// not listed already.
+func ParseConfig(contents map[string]interface{}) (c Config, e error) {
+	if e = convert(contents, &c); e != nil {
 		return c, e
 	}
-	err := json.Unmarshal(contents, &c)
-	if err != nil {
-		log.Error("Invalid JSON in config: ", err)
-		return c, err
+
+	names := make([]string, 0, len(c.CollectorConfigs))
+	for name := range c.CollectorConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !contains(c.Collectors, name) {
+			c.Collectors = append(c.Collectors, name)
+		}
 	}
 	return c, nil
 }
 
+// ReadConfigMap reads a fullerite configuration file along with the files
+// in its include directory, without converting it to a Config. The included
+// files are merged in lexical order: objects are merged key by key, lists
+// are appended to and any other value is replaced.
+func ReadConfigMap(configFile string) (map[string]interface{}, error) {
+	log.Info("Reading configuration file at ", configFile)
+	contents, err := readFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	include, exists := contents["include"]
+	if !exists {
+		return contents, nil
+	}
+	includeDir, ok := include.(string)
+	if !ok {
+		err = fmt.Errorf("include must be a directory, got %v", include)
+		log.Error("Config file error: ", err)
+		return nil, err
+	}
+	if !filepath.IsAbs(includeDir) {
+		includeDir = filepath.Join(filepath.Dir(configFile), includeDir)
+	}
+
+	files, err := ioutil.ReadDir(includeDir)
+	if err != nil {
+		log.Error("Config file error: ", err)
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !IsConfigFile(file.Name()) {
+			continue
+		}
+		includeFile := filepath.Join(includeDir, file.Name())
+		log.Info("Including configuration file at ", includeFile)
+		included, err := readFile(includeFile)
+		if err != nil {
+			return nil, err
+		}
+		// included files cannot include further files
+		delete(included, "include")
+		merge(contents, included)
+	}
+	return contents, nil
+}
+
+// ReadCollectorConfig reads a fullerite collector configuration file
+func ReadCollectorConfig(configFile string) (c map[string]interface{}, e error) {
+	log.Info("Reading collector configuration file at ", configFile)
+	return readFile(configFile)
+}
+
 // GetAsFloat parses a string to a float or returns the float if float is passed in
 func GetAsFloat(value interface{}, defaultValue float64) (result float64) {
 	result = defaultValue
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// The formats of the configuration files by extension. Files with any
+// other extension are JSON.
+var formats = map[string]string{
+	".conf": "JSON",
+	".json": "JSON",
+	".yaml": "YAML",
+	".yml":  "YAML",
+	".toml": "TOML",
+}
+
+// collectorConfigExtensions are tried in order to find the config of a
+// collector
+var collectorConfigExtensions = []string{".conf", ".yaml", ".yml", ".toml"}
+
+// IsConfigFile returns true if the file has the extension of a
+// configuration format
+func IsConfigFile(path string) bool {
+	_, exists := formats[filepath.Ext(path)]
+	return exists
+}
+
+// CollectorConfigFile returns the config file of a collector in the
+// directory. Since collector names can contain a space to run several
+// instances of the same collector, it is replaced with an underscore. The
+// file may be JSON, YAML or TOML, the JSON name is returned if there is none.
+func CollectorConfigFile(dir string, name string) string {
+	base := filepath.Join(dir, strings.Replace(name, " ", "_", -1))
+	for _, ext := range collectorConfigExtensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return base + collectorConfigExtensions[0]
+}
+
+// readFile reads a configuration file in the format of its extension. The
+// values are converted to what JSON decodes to, so that the GetAs functions
+// work the same whatever the format.
+func readFile(path string) (map[string]interface{}, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Error("Config file error: ", err)
+		return nil, err
+	}
+
+	format, exists := formats[filepath.Ext(path)]
+	if !exists {
+		format = "JSON"
+	}
+
+	var decoded interface{}
+	switch format {
+	case "YAML":
+		err = yaml.Unmarshal(contents, &decoded)
+	case "TOML":
+		var table map[string]interface{}
+		_, err = toml.Decode(string(contents), &table)
+		decoded = table
+	default:
+		err = json.Unmarshal(contents, &decoded)
+	}
+	if err != nil {
+		log.Error("Invalid ", format, " in config: ", err)
+		return nil, err
+	}
+
+	var c map[string]interface{}
+	if err = convert(decoded, &c); err != nil {
+		log.Error("Invalid ", format, " in config: ", err)
+		return nil, err
+	}
+	if err = interpolate(c); err != nil {
+		log.Error("Invalid reference in config ", path, ": ", err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// convert turns a decoded value into another type by way of JSON
+func convert(value interface{}, result interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, result)
+}
+
+// merge merges src into dst: objects are merged key by key, lists are
+// appended to and any other value is replaced
+func merge(dst, src map[string]interface{}) {
+	for key, value := range src {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if existing, ok := dst[key].(map[string]interface{}); ok {
+				merge(existing, v)
+				continue
+			}
+		case []interface{}:
+			if existing, ok := dst[key].([]interface{}); ok {
+				dst[key] = append(existing, v...)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package config_test
+
+import (
+	"fullerite/config"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "fullerite")
+	require.Nil(t, err)
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	return dir
+}
+
+func TestReadYAMLConfig(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"fullerite.yaml": `
+prefix: test.
+interval: 10
+collectors: [Test]
+defaultDimensions:
+  application: fullerite
+handlers:
+  Graphite:
+    server: localhost
+    port: 2003
+    timeout: 2
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := config.ReadConfig(filepath.Join(dir, "fullerite.yaml"))
+	require.Nil(t, err)
+	assert.Equal(t, "test.", c.Prefix)
+	assert.Equal(t, 10, config.GetAsInt(c.Interval, 0))
+	assert.Equal(t, []string{"Test"}, c.Collectors)
+	assert.Equal(t, map[string]string{"application": "fullerite"}, c.DefaultDimensions)
+	assert.Equal(t, 2003, config.GetAsInt(c.Handlers["Graphite"]["port"], 0))
+	assert.Equal(t, 2.0, config.GetAsFloat(c.Handlers["Graphite"]["timeout"], 0))
+}
+
+func TestReadTOMLCollectorConfig(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"ProcStatus.toml": `
+interval = 5
+pattern = "^fullerite$"
+metrics_blacklist = ["cpu"]
+
+[generatedDimensions]
+service = "^(\\w+)"
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	path := config.CollectorConfigFile(dir, "ProcStatus")
+	assert.Equal(t, filepath.Join(dir, "ProcStatus.toml"), path)
+
+	c, err := config.ReadCollectorConfig(path)
+	require.Nil(t, err)
+	assert.Equal(t, 5, config.GetAsInt(c["interval"], 0))
+	assert.Equal(t, 5.0, config.GetAsFloat(c["interval"], 0))
+	assert.Equal(t, []string{"cpu"}, config.GetAsSlice(c["metrics_blacklist"]))
+	assert.Equal(t, map[string]string{"service": `^(\w+)`}, config.GetAsMap(c["generatedDimensions"]))
+}
+
+func TestCollectorConfigFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Test_second.yml":  "interval: 5",
+		"Test_second.conf": `{"interval": 5}`,
+	})
+	defer os.RemoveAll(dir)
+
+	assert.Equal(t, filepath.Join(dir, "Test_second.conf"), config.CollectorConfigFile(dir, "Test second"),
+		"should prefer the JSON config")
+	assert.Equal(t, filepath.Join(dir, "Missing.conf"), config.CollectorConfigFile(dir, "Missing"))
+}
+
+func TestReadConfigIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"fullerite.conf": `{
+			"prefix": "test.",
+			"include": "conf.d",
+			"collectors": ["Test"],
+			"handlers": {"Graphite": {"server": "localhost", "port": 2003}}
+		}`,
+		"conf.d/10-handlers.yaml": `
+handlers:
+  Graphite:
+    port: 2004
+  Log: {}
+`,
+		"conf.d/20-collectors.toml": `
+prefix = "prod."
+collectors = ["ProcStatus"]
+
+[collectorConfigs.ProcStatus]
+pattern = "^fullerite$"
+`,
+		"conf.d/README": "not a config",
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := config.ReadConfig(filepath.Join(dir, "fullerite.conf"))
+	require.Nil(t, err)
+	assert.Equal(t, "prod.", c.Prefix, "should let later files override values")
+	assert.Equal(t, []string{"Test", "ProcStatus"}, c.Collectors, "should append to lists")
+	assert.Equal(t, "localhost", c.Handlers["Graphite"]["server"], "should merge objects")
+	assert.Equal(t, 2004, config.GetAsInt(c.Handlers["Graphite"]["port"], 0))
+	assert.Contains(t, c.Handlers, "Log")
+	assert.Equal(t, "^fullerite$", c.CollectorConfigs["ProcStatus"]["pattern"])
+}
+
+func TestReadConfigInlineCollectors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"fullerite.yaml": `
+collectors: [Test]
+collectorConfigs:
+  Test:
+    metricName: TestMetric
+  ProcStatus:
+    pattern: ^fullerite$
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := config.ReadConfig(filepath.Join(dir, "fullerite.yaml"))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Test", "ProcStatus"}, c.Collectors,
+		"should run the collectors with an inline config")
+	assert.Equal(t, "TestMetric", c.CollectorConfigs["Test"]["metricName"])
+}
+
+func TestReadConfigInvalidInclude(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"fullerite.conf": `{"include": "missing"}`,
+		"bad.yaml":       "handlers: [",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := config.ReadConfig(filepath.Join(dir, "fullerite.conf"))
+	assert.NotNil(t, err, "should fail without the include directory")
+
+	_, err = config.ReadConfig(filepath.Join(dir, "bad.yaml"))
+	assert.NotNil(t, err, "should fail on invalid YAML")
+}
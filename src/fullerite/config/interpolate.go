@@ -0,0 +1,249 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// String values in configuration files can refer to environment variables
+// and files, which are expanded when the file is read:
+//
+//	"${DATADOG_API_KEY}"         the value of an environment variable
+//	"${GRAPHITE_PORT:-2003}"     with a default if it is unset or empty
+//	"${file:/etc/fullerite/key}" the contents of a file, without the
+//	                             trailing newline
+//	"${file:/etc/key:-none}"     with a default if the file does not exist
+//	"$${literal}"                is left as "${literal}"
+//
+// A reference without a default to a variable that is not set or to a file
+// that does not exist fails the configuration. Values read from files and
+// the values of keys that look like credentials are secrets: they are
+// redacted from the logs and from Redact.
+const (
+	fileReference = "file:"
+	defaultMarker = ":-"
+
+	// RedactedValue replaces secrets
+	RedactedValue = "******"
+
+	// secrets shorter than this are not redacted from text, so that a
+	// secret like "1" does not mangle every log line
+	minRedactedLength = 4
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretKeyParts mark the keys whose values are secrets, matched case
+// insensitively
+var secretKeyParts = []string{"password", "secret", "token", "apikey", "api_key"}
+
+var secrets = struct {
+	sync.RWMutex
+	values map[string]bool
+	sorted []string
+}{values: make(map[string]bool)}
+
+// interpolate expands the references in every string value of a
+// configuration in place
+func interpolate(conf map[string]interface{}) error {
+	for key, value := range conf {
+		expanded, err := interpolateValue(key, value)
+		if err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+		conf[key] = expanded
+	}
+	return nil
+}
+
+func interpolateValue(key string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		expanded, secret, err := expand(v)
+		if err != nil {
+			return nil, err
+		}
+		if secret || IsSecretKey(key) {
+			addSecret(expanded)
+		}
+		return expanded, nil
+	case map[string]interface{}:
+		return v, interpolate(v)
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := interpolateValue(key, item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+	return value, nil
+}
+
+// expand replaces the references in a string. It reports whether any of
+// them was to a file.
+func expand(s string) (string, bool, error) {
+	var result bytes.Buffer
+	fromFile := false
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			result.WriteString(s)
+			return result.String(), fromFile, nil
+		}
+		if start > 0 && s[start-1] == '$' {
+			result.WriteString(s[:start-1] + "${")
+			s = s[start+2:]
+			continue
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return "", false, fmt.Errorf("unterminated reference in %q", s)
+		}
+		reference := s[start+2 : start+end]
+
+		value, isFile, err := resolve(reference)
+		if err != nil {
+			return "", false, err
+		}
+		fromFile = fromFile || isFile
+		result.WriteString(s[:start] + value)
+		s = s[start+end+1:]
+	}
+}
+
+// resolve looks up a single reference, the part between "${" and "}"
+func resolve(reference string) (string, bool, error) {
+	name, defaultValue, hasDefault := reference, "", false
+	if i := strings.Index(reference, defaultMarker); i >= 0 {
+		name, defaultValue, hasDefault = reference[:i], reference[i+len(defaultMarker):], true
+	}
+
+	if strings.HasPrefix(name, fileReference) {
+		path := strings.TrimPrefix(name, fileReference)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			if hasDefault && os.IsNotExist(err) {
+				return defaultValue, false, nil
+			}
+			return "", false, err
+		}
+		return strings.TrimRight(string(contents), "\r\n"), true, nil
+	}
+
+	if !variableName.MatchString(name) {
+		return "", false, fmt.Errorf("invalid reference ${%s}", reference)
+	}
+	value, isSet := os.LookupEnv(name)
+	if value == "" && hasDefault {
+		return defaultValue, false, nil
+	}
+	if !isSet {
+		return "", false, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, false, nil
+}
+
+// IsSecretKey returns true if the values of the key are secrets
+func IsSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, part := range secretKeyParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func addSecret(value string) {
+	if len(value) < minRedactedLength {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	if secrets.values[value] {
+		return
+	}
+	secrets.values[value] = true
+	// longer secrets go first, so that a secret containing another one
+	// is redacted as a whole
+	secrets.sorted = append(secrets.sorted, value)
+	for i := len(secrets.sorted) - 1; i > 0 && len(secrets.sorted[i]) > len(secrets.sorted[i-1]); i-- {
+		secrets.sorted[i], secrets.sorted[i-1] = secrets.sorted[i-1], secrets.sorted[i]
+	}
+}
+
+// RedactString replaces the secrets of the configuration in a text
+func RedactString(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, secret := range secrets.sorted {
+		s = strings.Replace(s, secret, RedactedValue, -1)
+	}
+	return s
+}
+
+// Redact returns a copy of a configuration or a part of it that is safe to
+// show: the values of secret keys are replaced and secrets are redacted from
+// strings. A Config is returned as a map.
+func Redact(value interface{}) interface{} {
+	return redactValue("", value)
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if IsSecretKey(key) && v != "" {
+			return RedactedValue
+		}
+		return RedactString(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = redactValue(k, item)
+		}
+		return redacted
+	case map[string]map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = redactValue(k, item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactValue(key, item)
+		}
+		return redacted
+	case Config:
+		var contents map[string]interface{}
+		if err := convert(v, &contents); err != nil {
+			return nil
+		}
+		return redactValue(key, contents)
+	}
+	return value
+}
+
+// RedactingFormatter redacts the secrets of the configuration from the log
+// entries the wrapped formatter writes
+type RedactingFormatter struct {
+	logrus.Formatter
+}
+
+// Format formats the entry and redacts the secrets from it
+func (f RedactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	formatted, err := f.Formatter.Format(entry)
+	if err != nil {
+		return formatted, err
+	}
+	return []byte(RedactString(string(formatted))), nil
+}
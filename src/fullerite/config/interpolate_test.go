@@ -0,0 +1,120 @@
+package config_test
+
+import (
+	"fullerite/config"
+
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigInterpolation(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"fullerite.conf": `{
+			"prefix": "${FULLERITE_TEST_PREFIX}",
+			"collectors": ["Test"],
+			"handlers": {
+				"Graphite": {
+					"server": "${FULLERITE_TEST_UNSET:-localhost}",
+					"port": "${FULLERITE_TEST_PORT:-2003}"
+				},
+				"Datadog": {
+					"apiKey": "${file:` + "%s" + `}",
+					"endpoint": "https://${FULLERITE_TEST_PREFIX}example.com/$${literal}"
+				}
+			}
+		}`,
+		"datadog.key": "s3cr3t-datadog-key\n",
+	})
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "fullerite.conf")
+	contents, err := ioutil.ReadFile(configFile)
+	require.Nil(t, err)
+	contents = []byte(fmt.Sprintf(string(contents), filepath.Join(dir, "datadog.key")))
+	require.Nil(t, ioutil.WriteFile(configFile, contents, 0644))
+
+	os.Setenv("FULLERITE_TEST_PREFIX", "dev.")
+	os.Setenv("FULLERITE_TEST_PORT", "2004")
+	defer os.Unsetenv("FULLERITE_TEST_PREFIX")
+	defer os.Unsetenv("FULLERITE_TEST_PORT")
+
+	c, err := config.ReadConfig(configFile)
+	require.Nil(t, err)
+	assert.Equal(t, "dev.", c.Prefix)
+	assert.Equal(t, "localhost", c.Handlers["Graphite"]["server"])
+	assert.Equal(t, 2004, config.GetAsInt(c.Handlers["Graphite"]["port"], 0))
+	assert.Equal(t, "s3cr3t-datadog-key", c.Handlers["Datadog"]["apiKey"], "should strip the trailing newline")
+	assert.Equal(t, "https://dev.example.com/${literal}", c.Handlers["Datadog"]["endpoint"])
+
+	redacted := config.Redact(c).(map[string]interface{})
+	handlers := redacted["handlers"].(map[string]interface{})
+	assert.Equal(t, config.RedactedValue, handlers["Datadog"].(map[string]interface{})["apiKey"])
+	assert.Equal(t, "localhost", handlers["Graphite"].(map[string]interface{})["server"])
+	assert.Equal(t, "key is "+config.RedactedValue, config.RedactString("key is s3cr3t-datadog-key"))
+}
+
+func TestReadCollectorConfigInterpolation(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Test.yaml": `
+metricName: ${FULLERITE_TEST_METRIC:-TestMetric}
+metrics_blacklist: ["${FULLERITE_TEST_METRIC:-other}"]
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := config.ReadCollectorConfig(filepath.Join(dir, "Test.yaml"))
+	require.Nil(t, err)
+	assert.Equal(t, "TestMetric", c["metricName"])
+	assert.Equal(t, []string{"other"}, config.GetAsSlice(c["metrics_blacklist"]))
+}
+
+func TestReadConfigInvalidReferences(t *testing.T) {
+	os.Unsetenv("FULLERITE_TEST_UNSET")
+	for _, value := range []string{
+		"${FULLERITE_TEST_UNSET}",
+		"${file:/does/not/exist}",
+		"${not a name}",
+		"${FULLERITE_TEST_UNSET",
+	} {
+		dir := writeConfigFiles(t, map[string]string{
+			"Test.conf": `{"metricName": "` + value + `"}`,
+		})
+		_, err := config.ReadCollectorConfig(filepath.Join(dir, "Test.conf"))
+		assert.NotNil(t, err, value)
+		os.RemoveAll(dir)
+	}
+
+	dir := writeConfigFiles(t, map[string]string{
+		"Test.conf": `{"metricName": "${file:/does/not/exist:-TestMetric}"}`,
+	})
+	defer os.RemoveAll(dir)
+	c, err := config.ReadCollectorConfig(filepath.Join(dir, "Test.conf"))
+	require.Nil(t, err)
+	assert.Equal(t, "TestMetric", c["metricName"], "should fall back to the default of a missing file")
+}
+
+func TestRedactingFormatter(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"SignalFx.conf": `{"authToken": "plaintext-token-value"}`,
+	})
+	defer os.RemoveAll(dir)
+	_, err := config.ReadCollectorConfig(filepath.Join(dir, "SignalFx.conf"))
+	require.Nil(t, err)
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &out
+	logger.Formatter = config.RedactingFormatter{Formatter: &logrus.TextFormatter{DisableColors: true}}
+	logger.Level = logrus.InfoLevel
+	logger.Info("Failed to post with token plaintext-token-value")
+
+	assert.NotContains(t, out.String(), "plaintext-token-value")
+	assert.Contains(t, out.String(), config.RedactedValue)
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// The types a configuration value can have. They accept what the GetAs
+// functions accept, e.g. an int may be given as a string.
+const (
+	TypeString = "string"
+	TypeBool   = "bool"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	// TypeList is a list of strings, or a JSON encoded one
+	TypeList = "list"
+	// TypeMap is an object with string values, or a JSON encoded one
+	TypeMap = "map"
+	// TypeObject is an object with values of any type
+	TypeObject = "object"
+	// TypeAny is checked by the code that reads the value
+	TypeAny = "any"
+)
+
+// Key describes a key of a configuration. The default and the description
+// are documentation only, the code that reads the key applies the default.
+type Key struct {
+	Name        string
+	Type        string
+	Required    bool
+	Default     interface{}
+	Description string
+}
+
+// GlobalKeys are the keys of fullerite.conf
+var GlobalKeys = []Key{
+	{Name: "prefix", Type: TypeString, Description: "prefix of the names of all metrics, added by the handlers"},
+	{Name: "interval", Type: TypeInt, Default: 10, Description: "seconds between collections, unless a collector sets its own"},
+	{Name: "collectorsConfigPath", Type: TypeString, Description: "directory of the collector configs"},
+	{Name: "diamondCollectorsPath", Type: TypeString, Description: "directory of the Diamond collector configs"},
+	{Name: "diamondCollectors", Type: TypeList, Description: "Diamond collectors the Diamond server runs"},
+	{Name: "fulleritePort", Type: TypeInt, Default: 19191, Description: "port of the Diamond collector the Diamond collectors send to"},
+	{Name: "fulleriteHost", Type: TypeString, Default: "127.0.0.1", Description: "address of the Diamond collector the Diamond collectors send to"},
+	{Name: "fulleriteCA", Type: TypeString,
+		Description: "PEM file of the CA that signs the certificate of the Diamond collector, the Diamond collectors use TLS if set"},
+	{Name: "fulleriteCert", Type: TypeString, Description: "PEM file of the client certificate the Diamond collectors present"},
+	{Name: "fulleriteKey", Type: TypeString, Description: "PEM file of the key of the client certificate"},
+	{Name: "fulleriteServerName", Type: TypeString,
+		Description: "name the certificate of the Diamond collector is checked against, fulleriteHost if empty"},
+	{Name: "handlers", Type: TypeObject, Description: "handlers to emit to, with their configs"},
+	{Name: "collectors", Type: TypeList, Description: "collectors to run"},
+	{Name: "defaultDimensions", Type: TypeMap, Description: "dimensions added to every metric"},
+	{Name: "internalServer", Type: TypeObject, Description: "config of the internal HTTP server"},
+	{Name: "shutdownTimeout", Type: TypeInt, Default: 30, Description: "seconds a shutdown may take to flush the handlers"},
+	{Name: "processors", Type: TypeAny, Description: "processors applied to every metric"},
+	{Name: "cardinality", Type: TypeObject, Description: "limits on the number of series per metric"},
+	{Name: "include", Type: TypeString, Description: "directory of config files merged into this one"},
+	{Name: "collectorConfigs", Type: TypeObject, Description: "inline collector configs, by collector name"},
+}
+
+// CheckKeys checks a configuration against the keys it may have. It reports
+// keys that are unknown, have a value of the wrong type or are required but
+// missing, sorted by key.
+func CheckKeys(conf map[string]interface{}, keys []Key) []error {
+	known := make(map[string]Key, len(keys))
+	for _, key := range keys {
+		known[key.Name] = key
+	}
+
+	names := make([]string, 0, len(conf))
+	for name := range conf {
+		names = append(names, name)
+	}
+	for _, key := range keys {
+		if _, exists := conf[key.Name]; !exists && key.Required {
+			names = append(names, key.Name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		key, isKnown := known[name]
+		value, exists := conf[name]
+		switch {
+		case !isKnown:
+			errs = append(errs, fmt.Errorf("unknown key %q", name))
+		case !exists:
+			errs = append(errs, fmt.Errorf("missing required key %q", name))
+		case !hasType(value, key.Type):
+			errs = append(errs, fmt.Errorf("%q must be of type %s, got %s", name, key.Type, describe(name, value)))
+		}
+	}
+	return errs
+}
+
+// CheckDefaults reports the keys whose documented default is not of the
+// type of the key
+func CheckDefaults(keys []Key) []error {
+	var errs []error
+	for _, key := range keys {
+		if key.Default != nil && !hasType(key.Default, key.Type) {
+			errs = append(errs, fmt.Errorf("default of %q must be of type %s, got %s", key.Name, key.Type, describe(key.Name, key.Default)))
+		}
+	}
+	return errs
+}
+
+func hasType(value interface{}, valueType string) bool {
+	switch valueType {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeBool:
+		_, ok := value.(bool)
+		return ok
+	case TypeInt:
+		switch v := value.(type) {
+		case int, int32, int64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		case string:
+			_, err := strconv.ParseInt(v, 10, 64)
+			return err == nil
+		}
+		return false
+	case TypeFloat:
+		switch v := value.(type) {
+		case int, int32, int64, float64:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(v, 64)
+			return err == nil
+		}
+		return false
+	case TypeList:
+		switch v := value.(type) {
+		case []string:
+			return true
+		case []interface{}:
+			for _, item := range v {
+				if _, ok := item.(string); !ok {
+					return false
+				}
+			}
+			return true
+		case string:
+			var list []string
+			return json.Unmarshal([]byte(v), &list) == nil
+		}
+		return false
+	case TypeMap:
+		switch v := value.(type) {
+		case map[string]string:
+			return true
+		case map[string]interface{}:
+			for _, item := range v {
+				if _, ok := item.(string); !ok {
+					return false
+				}
+			}
+			return true
+		case string:
+			var m map[string]string
+			return json.Unmarshal([]byte(v), &m) == nil
+		}
+		return false
+	case TypeObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+// describe formats a value for an error message, leaving out secrets
+func describe(key string, value interface{}) string {
+	value = redactValue(key, value)
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
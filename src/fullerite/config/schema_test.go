@@ -0,0 +1,83 @@
+package config_test
+
+import (
+	"fullerite/config"
+
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeys = []config.Key{
+	{Name: "server", Type: config.TypeString, Required: true},
+	{Name: "port", Type: config.TypeInt},
+	{Name: "timeout", Type: config.TypeFloat},
+	{Name: "enabled", Type: config.TypeBool},
+	{Name: "whitelist", Type: config.TypeList},
+	{Name: "dimensions", Type: config.TypeMap},
+	{Name: "aggregation", Type: config.TypeObject},
+	{Name: "processors", Type: config.TypeAny},
+}
+
+func TestCheckKeysValid(t *testing.T) {
+	var conf map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"server": "localhost",
+		"port": "2003",
+		"timeout": 0.5,
+		"enabled": true,
+		"whitelist": "[\"a\", \"b\"]",
+		"dimensions": {"region": "uswest1"},
+		"aggregation": {"rollups": ["sum"]},
+		"processors": [{"type": "rename"}]
+	}`), &conf)
+	assert.Nil(t, err)
+
+	assert.Empty(t, config.CheckKeys(conf, testKeys))
+	assert.Empty(t, config.CheckKeys(map[string]interface{}{
+		"server":    "localhost",
+		"port":      2003,
+		"whitelist": []string{"a"},
+	}, testKeys))
+}
+
+func TestCheckKeysInvalid(t *testing.T) {
+	conf := map[string]interface{}{
+		"port":        2003.5,
+		"timeout":     "soon",
+		"enabled":     "yes",
+		"whitelist":   []interface{}{"a", 1.0},
+		"dimensions":  map[string]interface{}{"region": 1.0},
+		"aggregation": []interface{}{},
+		"sever":       "localhost",
+	}
+
+	var messages []string
+	for _, err := range config.CheckKeys(conf, testKeys) {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		`"aggregation" must be of type object, got []`,
+		`"dimensions" must be of type map, got {"region":1}`,
+		`"enabled" must be of type bool, got "yes"`,
+		`"port" must be of type int, got 2003.5`,
+		`missing required key "server"`,
+		`unknown key "sever"`,
+		`"timeout" must be of type float, got "soon"`,
+		`"whitelist" must be of type list, got ["a",1]`,
+	}, messages)
+}
+
+func TestCheckDefaults(t *testing.T) {
+	assert.Empty(t, config.CheckDefaults(config.GlobalKeys))
+
+	errs := config.CheckDefaults([]config.Key{
+		{Name: "port", Type: config.TypeInt, Default: "2003"},
+		{Name: "timeout", Type: config.TypeFloat, Default: 2},
+		{Name: "server", Type: config.TypeString, Default: 2003},
+		{Name: "whitelist", Type: config.TypeList},
+	})
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], `default of "server" must be of type string, got 2003`)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fullerite/config"
+	"fullerite/internalserver"
+
+	"errors"
+	"sort"
+)
+
+// The agent is the Controller of the control API of the internal server.
+// Pauses only last as long as the collector runs, a reload that restarts
+// the collector resumes it.
+
+var errListenerControl = errors.New("listener collectors do not run on a schedule")
+
+// Collectors lists the running collectors with their configs
+func (a *agent) Collectors() []internalserver.ComponentInfo {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	infos := make([]internalserver.ComponentInfo, 0, len(a.collectors))
+	for name, running := range a.collectors {
+		info := internalserver.ComponentInfo{
+			Name:     name,
+			Type:     running.collector.CollectorType(),
+			Interval: running.collector.Interval(),
+			Config:   config.Redact(running.settings.config),
+		}
+		if control, exists := collectorControlOf(running.collector); exists {
+			info.Paused = control.isPaused()
+		}
+		infos = append(infos, info)
+	}
+	sort.Sort(byComponentName(infos))
+	return infos
+}
+
+// Handlers lists the running handlers with their configs
+func (a *agent) Handlers() []internalserver.ComponentInfo {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	infos := make([]internalserver.ComponentInfo, 0, len(a.handlers))
+	for name, running := range a.handlers {
+		infos = append(infos, internalserver.ComponentInfo{
+			Name:     name,
+			Interval: running.handler.Interval(),
+			Config:   config.Redact(running.settings.config),
+		})
+	}
+	sort.Sort(byComponentName(infos))
+	return infos
+}
+
+// Collect runs the named collector right away
+func (a *agent) Collect(name string) error {
+	control, err := a.collectorControl(name)
+	if err != nil {
+		return err
+	}
+	control.collectNow()
+	return nil
+}
+
+// Pause skips the scheduled runs of the named collector
+func (a *agent) Pause(name string) error {
+	control, err := a.collectorControl(name)
+	if err != nil {
+		return err
+	}
+	control.setPaused(true)
+	log.Info("Paused collector ", name)
+	return nil
+}
+
+// Resume takes the scheduled runs of the named collector again
+func (a *agent) Resume(name string) error {
+	control, err := a.collectorControl(name)
+	if err != nil {
+		return err
+	}
+	control.setPaused(false)
+	log.Info("Resumed collector ", name)
+	return nil
+}
+
+// Flush emits what the named handler has buffered. The lock is not held
+// while flushing, a slow backend would hold up reloads otherwise.
+func (a *agent) Flush(name string) error {
+	a.lock.Lock()
+	running, exists := a.handlers[name]
+	a.lock.Unlock()
+
+	if !exists {
+		return internalserver.ErrNotRunning
+	}
+	running.handler.Flush()
+	return nil
+}
+
+func (a *agent) collectorControl(name string) (*collectorControl, error) {
+	a.lock.Lock()
+	running, exists := a.collectors[name]
+	a.lock.Unlock()
+
+	if !exists {
+		return nil, internalserver.ErrNotRunning
+	}
+	if running.collector.CollectorType() == "listener" {
+		return nil, errListenerControl
+	}
+	control, exists := collectorControlOf(running.collector)
+	if !exists {
+		return nil, internalserver.ErrNotRunning
+	}
+	return control, nil
+}
+
+type byComponentName []internalserver.ComponentInfo
+
+func (b byComponentName) Len() int           { return len(b) }
+func (b byComponentName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byComponentName) Less(i, j int) bool { return b[i].Name < b[j].Name }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fullerite/internalserver"
+	"fullerite/stats"
+
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentControlListing(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+	defer a.shutdown()
+
+	collectors := a.Collectors()
+	require.Len(t, collectors, 1)
+	assert.Equal(t, "Test", collectors[0].Name)
+	assert.Equal(t, "collector", collectors[0].Type)
+	assert.Equal(t, 10, collectors[0].Interval)
+	assert.Equal(t, map[string]interface{}{"metricName": "TestMetric"}, collectors[0].Config)
+
+	handlers := a.Handlers()
+	require.Len(t, handlers, 1)
+	assert.Equal(t, "Log", handlers[0].Name)
+}
+
+func TestAgentControlCollector(t *testing.T) {
+	a, dir := newTestAgent(t)
+	defer os.RemoveAll(dir)
+	defer a.shutdown()
+
+	require.Nil(t, a.Pause("Test"))
+	assert.True(t, a.Collectors()[0].Paused)
+	require.Nil(t, a.Resume("Test"))
+	assert.False(t, a.Collectors()[0].Paused)
+
+	runs := stats.Default.Collectors()["Test"].Runs
+	require.Nil(t, a.Collect("Test"))
+	// a run of the test collector takes three seconds
+	deadline := time.Now().Add(5 * time.Second)
+	for stats.Default.Collectors()["Test"].Runs == runs && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, runs+1, stats.Default.Collectors()["Test"].Runs, "should have run right away")
+
+	assert.Equal(t, internalserver.ErrNotRunning, a.Collect("Missing"))
+	assert.Equal(t, internalserver.ErrNotRunning, a.Pause("Missing"))
+	assert.Nil(t, a.Flush("Log"))
+	assert.Equal(t, internalserver.ErrNotRunning, a.Flush("Missing"))
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/processor"
+
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The rollups the aggregation of a handler can produce for every series
+const (
+	RollupSum   = "sum"
+	RollupCount = "count"
+	RollupMin   = "min"
+	RollupMax   = "max"
+	RollupAvg   = "avg"
+	RollupLast  = "last"
+)
+
+// DefaultRollups are produced unless the aggregation lists its own
+var DefaultRollups = []string{RollupCount, RollupMin, RollupMax, RollupAvg}
+
+// aggregationConfig describes how a handler collapses the points of a
+// series within one interval, e.g.
+//
+//	"aggregation": {
+//		"rollups": ["count", "avg", "max"],
+//		"percentiles": [50, 99],
+//		"match": {"name": "^timers\\."}
+//	}
+//
+// Every rollup becomes a metric named after the series with the rollup as
+// suffix, e.g. "timers.login.avg" or "timers.login.p99". Cumulative counters
+// only keep their last value and their name.
+type aggregationConfig struct {
+	rollups     []string
+	percentiles []float64
+	match       *processor.Matcher
+}
+
+func newAggregationConfig(value interface{}) (*aggregationConfig, error) {
+	conf, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("aggregation must be an object, got %v", value)
+	}
+
+	aggregation := &aggregationConfig{rollups: DefaultRollups}
+	if rollups, exists := conf["rollups"]; exists {
+		aggregation.rollups = config.GetAsSlice(rollups)
+		for _, rollup := range aggregation.rollups {
+			switch rollup {
+			case RollupSum, RollupCount, RollupMin, RollupMax, RollupAvg, RollupLast:
+			default:
+				return nil, fmt.Errorf("unknown rollup %q", rollup)
+			}
+		}
+	}
+
+	if percentiles, exists := conf["percentiles"]; exists {
+		list, ok := percentiles.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("percentiles must be a list, got %v", percentiles)
+		}
+		for _, p := range list {
+			percentile := config.GetAsFloat(p, -1)
+			if percentile <= 0 || percentile > 100 {
+				return nil, fmt.Errorf("percentiles must be between 0 and 100, got %v", p)
+			}
+			aggregation.percentiles = append(aggregation.percentiles, percentile)
+		}
+	}
+
+	match, err := processor.NewMatcher(conf["match"])
+	if err != nil {
+		return nil, err
+	}
+	aggregation.match = match
+
+	return aggregation, nil
+}
+
+// newAggregator returns an empty aggregator, or nil if there is no
+// aggregation configured
+func (conf *aggregationConfig) newAggregator() *aggregator {
+	if conf == nil {
+		return nil
+	}
+	return &aggregator{
+		conf:   conf,
+		series: make(map[string]*seriesAggregate),
+	}
+}
+
+// aggregator collects the points of one interval. It is not safe for
+// concurrent use, every listener of a handler has its own.
+type aggregator struct {
+	conf   *aggregationConfig
+	series map[string]*seriesAggregate
+}
+
+type seriesAggregate struct {
+	metric metric.Metric
+	count  int
+	sum    float64
+	min    float64
+	max    float64
+	values []float64
+}
+
+// add takes a point into the aggregation. It returns false if the point is
+// not aggregated and should be emitted as it is.
+func (a *aggregator) add(m metric.Metric) bool {
+	if a == nil || !a.conf.match.Matches(&m) {
+		return false
+	}
+
+	key := m.SeriesKey()
+	s, exists := a.series[key]
+	if !exists {
+		s = &seriesAggregate{min: m.Value, max: m.Value}
+		a.series[key] = s
+	}
+
+	s.metric = m
+	s.count++
+	s.sum += m.Value
+	s.min = math.Min(s.min, m.Value)
+	s.max = math.Max(s.max, m.Value)
+	if len(a.conf.percentiles) > 0 {
+		s.values = append(s.values, m.Value)
+	}
+	return true
+}
+
+// flush returns the rollups of all series and starts a new interval
+func (a *aggregator) flush(now time.Time) []metric.Metric {
+	if a == nil || len(a.series) == 0 {
+		return nil
+	}
+
+	results := []metric.Metric{}
+	for _, s := range a.series {
+		if s.metric.MetricType == metric.CumulativeCounter {
+			results = append(results, s.metric)
+			continue
+		}
+
+		for _, rollup := range a.conf.rollups {
+			var value float64
+			switch rollup {
+			case RollupSum:
+				value = s.sum
+			case RollupCount:
+				value = float64(s.count)
+			case RollupMin:
+				value = s.min
+			case RollupMax:
+				value = s.max
+			case RollupAvg:
+				value = s.sum / float64(s.count)
+			case RollupLast:
+				value = s.metric.Value
+			}
+			results = append(results, s.rollup(rollup, value, now))
+		}
+
+		if len(a.conf.percentiles) > 0 {
+			sort.Float64s(s.values)
+			for _, p := range a.conf.percentiles {
+				results = append(results, s.rollup(percentileName(p), percentile(s.values, p), now))
+			}
+		}
+	}
+
+	a.series = make(map[string]*seriesAggregate)
+	return results
+}
+
+func (s *seriesAggregate) rollup(suffix string, value float64, now time.Time) metric.Metric {
+	m := metric.New(s.metric.Name + "." + suffix)
+	m.Value = value
+	m.Timestamp = now
+	m.AddDimensions(s.metric.Dimensions)
+	if suffix == RollupCount {
+		m.MetricType = metric.Counter
+	}
+	return m
+}
+
+// percentile picks the nearest rank from sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// percentileName turns e.g. 99.9 into p99_9
+func percentileName(p float64) string {
+	return "p" + strings.Replace(fmt.Sprintf("%g", p), ".", "_", -1)
+}
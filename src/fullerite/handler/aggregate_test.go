@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"encoding/json"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseAggregation(t *testing.T, conf string) *aggregationConfig {
+	var parsed interface{}
+	require.Nil(t, json.Unmarshal([]byte(conf), &parsed))
+	aggregation, err := newAggregationConfig(parsed)
+	require.Nil(t, err)
+	return aggregation
+}
+
+func byName(metrics []metric.Metric) map[string]metric.Metric {
+	result := map[string]metric.Metric{}
+	for _, m := range metrics {
+		result[m.Name] = m
+	}
+	return result
+}
+
+func TestAggregatorRollups(t *testing.T) {
+	agg := parseAggregation(t, `{"rollups": ["sum", "count", "min", "max", "avg", "last"]}`).newAggregator()
+
+	for _, value := range []float64{4, 1, 7} {
+		m := metric.WithValue("latency", value)
+		m.AddDimension("host", "web01")
+		assert.True(t, agg.add(m))
+	}
+
+	now := time.Now()
+	results := byName(agg.flush(now))
+	require.Equal(t, 6, len(results))
+	assert.Equal(t, 12.0, results["latency.sum"].Value)
+	assert.Equal(t, 3.0, results["latency.count"].Value)
+	assert.Equal(t, metric.Counter, results["latency.count"].MetricType)
+	assert.Equal(t, 1.0, results["latency.min"].Value)
+	assert.Equal(t, 7.0, results["latency.max"].Value)
+	assert.Equal(t, 4.0, results["latency.avg"].Value)
+	assert.Equal(t, 7.0, results["latency.last"].Value)
+	assert.Equal(t, "web01", results["latency.avg"].Dimensions["host"])
+	assert.Equal(t, now, results["latency.avg"].Timestamp)
+
+	assert.Empty(t, agg.flush(now), "should start a new interval")
+}
+
+func TestAggregatorPercentiles(t *testing.T) {
+	agg := parseAggregation(t, `{"rollups": [], "percentiles": [50, 90, 99.9]}`).newAggregator()
+
+	for i := 100; i > 0; i-- {
+		agg.add(metric.WithValue("timer", float64(i)))
+	}
+
+	results := byName(agg.flush(time.Now()))
+	require.Equal(t, 3, len(results))
+	assert.Equal(t, 50.0, results["timer.p50"].Value)
+	assert.Equal(t, 90.0, results["timer.p90"].Value)
+	assert.Equal(t, 100.0, results["timer.p99_9"].Value)
+}
+
+func TestAggregatorKeepsSeriesApart(t *testing.T) {
+	agg := parseAggregation(t, `{"rollups": ["count"]}`).newAggregator()
+
+	web01 := metric.WithValue("requests", 1)
+	web01.AddDimension("host", "web01")
+	web02 := metric.WithValue("requests", 1)
+	web02.AddDimension("host", "web02")
+	agg.add(web01)
+	agg.add(web01)
+	agg.add(web02)
+
+	counts := map[string]float64{}
+	for _, m := range agg.flush(time.Now()) {
+		counts[m.Dimensions["host"]] = m.Value
+	}
+	assert.Equal(t, map[string]float64{"web01": 2, "web02": 1}, counts)
+}
+
+func TestAggregatorCumulativeCounters(t *testing.T) {
+	agg := parseAggregation(t, `{}`).newAggregator()
+
+	for _, value := range []float64{10, 20} {
+		m := metric.WithValue("total", value)
+		m.MetricType = metric.CumulativeCounter
+		agg.add(m)
+	}
+
+	results := agg.flush(time.Now())
+	require.Equal(t, 1, len(results))
+	assert.Equal(t, "total", results[0].Name)
+	assert.Equal(t, 20.0, results[0].Value)
+}
+
+func TestAggregatorMatch(t *testing.T) {
+	agg := parseAggregation(t, `{"match": {"name": "^timers\\."}}`).newAggregator()
+
+	assert.True(t, agg.add(metric.New("timers.login")))
+	assert.False(t, agg.add(metric.New("gauges.users")))
+
+	var none *aggregator
+	assert.False(t, none.add(metric.New("timers.login")), "no aggregation configured")
+	assert.Nil(t, none.flush(time.Now()))
+}
+
+func TestAggregationConfigInvalid(t *testing.T) {
+	for _, conf := range []interface{}{
+		"avg",
+		map[string]interface{}{"rollups": []interface{}{"median"}},
+		map[string]interface{}{"percentiles": []interface{}{0.0}},
+		map[string]interface{}{"percentiles": []interface{}{101.0}},
+		map[string]interface{}{"percentiles": 99.0},
+	} {
+		_, err := newAggregationConfig(conf)
+		assert.NotNil(t, err, conf)
+	}
+}
+
+func TestHandlerAggregates(t *testing.T) {
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_aggregation")
+	base.interval = 60
+	base.maxBufferSize = 10
+	base.channel = make(chan metric.Metric)
+	base.configureCommonParams(map[string]interface{}{
+		"aggregation": map[string]interface{}{
+			"rollups": []interface{}{"avg"},
+			"match":   map[string]interface{}{"name": "^latency$"},
+		},
+	})
+
+	emitted := make(chan []metric.Metric, 1)
+	base.run(func(metrics []metric.Metric) bool {
+		emitted <- metrics
+		return true
+	})
+
+	base.channel <- metric.WithValue("latency", 1)
+	base.channel <- metric.WithValue("latency", 3)
+	base.channel <- metric.WithValue("other", 5)
+	base.Stop()
+
+	results := byName(<-emitted)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, 2.0, results["latency.avg"].Value)
+	assert.Equal(t, 5.0, results["other"].Value)
+	assert.Equal(t, 2.0, base.InternalMetrics().Counters["metricsAggregated"])
+}
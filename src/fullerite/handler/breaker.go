@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for retrying emissions and for the circuit breaker
+const (
+	DefaultMaxRetries       = 3
+	DefaultRetryBackoff     = 0.5 // seconds
+	DefaultMaxRetryBackoff  = 10  // seconds
+	DefaultMaxInFlight      = 4
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 // seconds
+)
+
+// The states a circuit breaker can be in. They are reported as a gauge so
+// the numbers matter.
+const (
+	breakerClosed = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+// breakerStateNames are the names of the states in the health of a handler
+var breakerStateNames = []string{"closed", "half-open", "open"}
+
+// circuitBreaker stops emissions to a backend after too many consecutive
+// failures. Once the cooldown has passed a single trial emission is let
+// through, which closes the breaker again if it succeeds.
+type circuitBreaker struct {
+	lock      sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     int
+	openedAt  time.Time
+	trips     uint64
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+	}
+}
+
+// allow returns true if an emission may go out right now
+func (b *circuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		// this caller gets to do the trial emission
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures = 0
+	b.state = breakerClosed
+}
+
+func (b *circuitBreaker) failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		if b.state != breakerOpen {
+			b.trips++
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) currentState() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+func (b *circuitBreaker) tripCount() uint64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.trips
+}
+
+// emitGuard wraps the emit functions of a handler with retries, a cap on the
+// number of concurrent emissions and a circuit breaker.
+type emitGuard struct {
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	inFlight   chan struct{}
+	breaker    *circuitBreaker
+
+	retries  uint64
+	rejected uint64
+}
+
+// emitGuardKeys are the handler config keys of the guard
+var emitGuardKeys = []config.Key{
+	{Name: "maxRetries", Type: config.TypeInt, Default: DefaultMaxRetries, Description: "retries of a failed emission"},
+	{Name: "retryBackoff", Type: config.TypeFloat, Default: DefaultRetryBackoff, Description: "seconds before the first retry, doubled for each one"},
+	{Name: "maxRetryBackoff", Type: config.TypeFloat, Default: DefaultMaxRetryBackoff, Description: "most seconds between retries"},
+	{Name: "maxInFlight", Type: config.TypeInt, Default: DefaultMaxInFlight, Description: "emissions that may be in flight at once"},
+	{Name: "breakerThreshold", Type: config.TypeInt, Default: DefaultBreakerThreshold,
+		Description: "consecutive failures after which emissions stop for the cooldown"},
+	{Name: "breakerCooldown", Type: config.TypeFloat, Default: DefaultBreakerCooldown, Description: "seconds emissions stop for"},
+}
+
+// newEmitGuard builds a guard from the handler config, anything that is not
+// set falls back to the defaults.
+func newEmitGuard(configMap map[string]interface{}) *emitGuard {
+	maxRetries := DefaultMaxRetries
+	if asInterface, exists := configMap["maxRetries"]; exists {
+		maxRetries = config.GetAsInt(asInterface, DefaultMaxRetries)
+	}
+
+	backoff := DefaultRetryBackoff
+	if asInterface, exists := configMap["retryBackoff"]; exists {
+		backoff = config.GetAsFloat(asInterface, DefaultRetryBackoff)
+	}
+
+	maxBackoff := float64(DefaultMaxRetryBackoff)
+	if asInterface, exists := configMap["maxRetryBackoff"]; exists {
+		maxBackoff = config.GetAsFloat(asInterface, DefaultMaxRetryBackoff)
+	}
+
+	maxInFlight := DefaultMaxInFlight
+	if asInterface, exists := configMap["maxInFlight"]; exists {
+		maxInFlight = config.GetAsInt(asInterface, DefaultMaxInFlight)
+	}
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	threshold := DefaultBreakerThreshold
+	if asInterface, exists := configMap["breakerThreshold"]; exists {
+		threshold = config.GetAsInt(asInterface, DefaultBreakerThreshold)
+	}
+
+	cooldown := float64(DefaultBreakerCooldown)
+	if asInterface, exists := configMap["breakerCooldown"]; exists {
+		cooldown = config.GetAsFloat(asInterface, DefaultBreakerCooldown)
+	}
+
+	return &emitGuard{
+		maxRetries: maxRetries,
+		backoff:    time.Duration(backoff * float64(time.Second)),
+		maxBackoff: time.Duration(maxBackoff * float64(time.Second)),
+		inFlight:   make(chan struct{}, maxInFlight),
+		breaker:    newCircuitBreaker(threshold, time.Duration(cooldown*float64(time.Second))),
+	}
+}
+
+// acquire blocks until there is room for another emission
+func (g *emitGuard) acquire() {
+	g.inFlight <- struct{}{}
+}
+
+func (g *emitGuard) release() {
+	<-g.inFlight
+}
+
+// emit calls emitFunc until it succeeds, the retries are used up or the
+// breaker opens.
+func (g *emitGuard) emit(metrics []metric.Metric, emitFunc func([]metric.Metric) bool) bool {
+	for attempt := 0; attempt <= g.maxRetries; attempt++ {
+		if attempt > 0 {
+			atomic.AddUint64(&g.retries, 1)
+			time.Sleep(g.backoffFor(attempt))
+		}
+
+		if !g.breaker.allow() {
+			atomic.AddUint64(&g.rejected, 1)
+			return false
+		}
+
+		if emitFunc(metrics) {
+			g.breaker.success()
+			return true
+		}
+		g.breaker.failure()
+	}
+	return false
+}
+
+// backoffFor returns an exponential backoff with jitter for the given attempt.
+// The result is somewhere between half and the full exponential delay.
+func (g *emitGuard) backoffFor(attempt int) time.Duration {
+	delay := g.backoff << uint(attempt-1)
+	if delay <= 0 || delay > g.maxBackoff {
+		delay = g.maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker(2, time.Hour)
+
+	assert.True(t, b.allow())
+	b.failure()
+	assert.Equal(t, breakerClosed, b.currentState())
+	b.failure()
+	assert.Equal(t, breakerOpen, b.currentState())
+	assert.False(t, b.allow())
+	assert.Equal(t, uint64(1), b.tripCount())
+}
+
+func TestBreakerHalfOpenTrial(t *testing.T) {
+	b := newCircuitBreaker(1, time.Millisecond)
+	b.failure()
+	assert.Equal(t, breakerOpen, b.currentState())
+
+	time.Sleep(5 * time.Millisecond)
+	assert.True(t, b.allow(), "the first caller after the cooldown gets a trial")
+	assert.Equal(t, breakerHalfOpen, b.currentState())
+	assert.False(t, b.allow(), "only a single trial is let through")
+
+	b.success()
+	assert.Equal(t, breakerClosed, b.currentState())
+	assert.True(t, b.allow())
+}
+
+func TestBreakerFailedTrialReopens(t *testing.T) {
+	b := newCircuitBreaker(1, time.Millisecond)
+	b.failure()
+	time.Sleep(5 * time.Millisecond)
+	assert.True(t, b.allow())
+
+	b.failure()
+	assert.Equal(t, breakerOpen, b.currentState())
+	assert.Equal(t, uint64(2), b.tripCount())
+}
+
+func TestNewEmitGuardDefaults(t *testing.T) {
+	g := newEmitGuard(map[string]interface{}{})
+
+	assert.Equal(t, DefaultMaxRetries, g.maxRetries)
+	assert.Equal(t, DefaultMaxInFlight, cap(g.inFlight))
+	assert.Equal(t, DefaultBreakerThreshold, g.breaker.threshold)
+	assert.Equal(t, time.Duration(DefaultBreakerCooldown)*time.Second, g.breaker.cooldown)
+}
+
+func TestNewEmitGuardConfig(t *testing.T) {
+	g := newEmitGuard(map[string]interface{}{
+		"maxRetries":       "1",
+		"retryBackoff":     0.1,
+		"maxRetryBackoff":  "2",
+		"maxInFlight":      8,
+		"breakerThreshold": 3,
+		"breakerCooldown":  "5",
+	})
+
+	assert.Equal(t, 1, g.maxRetries)
+	assert.Equal(t, 100*time.Millisecond, g.backoff)
+	assert.Equal(t, 2*time.Second, g.maxBackoff)
+	assert.Equal(t, 8, cap(g.inFlight))
+	assert.Equal(t, 3, g.breaker.threshold)
+	assert.Equal(t, 5*time.Second, g.breaker.cooldown)
+}
+
+func TestEmitGuardRetries(t *testing.T) {
+	g := newEmitGuard(map[string]interface{}{
+		"maxRetries":   2,
+		"retryBackoff": 0.001,
+	})
+
+	calls := 0
+	emitFunc := func([]metric.Metric) bool {
+		calls++
+		return calls == 3
+	}
+
+	assert.True(t, g.emit([]metric.Metric{metric.New("test")}, emitFunc))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, uint64(2), g.retries)
+	assert.Equal(t, breakerClosed, g.breaker.currentState())
+}
+
+func TestEmitGuardStopsWhenBreakerOpens(t *testing.T) {
+	g := newEmitGuard(map[string]interface{}{
+		"maxRetries":       5,
+		"retryBackoff":     0.001,
+		"breakerThreshold": 2,
+	})
+
+	calls := 0
+	emitFunc := func([]metric.Metric) bool {
+		calls++
+		return false
+	}
+
+	assert.False(t, g.emit([]metric.Metric{metric.New("test")}, emitFunc))
+	assert.Equal(t, 2, calls, "the backend should not be called once the breaker is open")
+	assert.Equal(t, uint64(1), g.rejected)
+	assert.Equal(t, breakerOpen, g.breaker.currentState())
+}
+
+func TestBackoffIsBounded(t *testing.T) {
+	g := newEmitGuard(map[string]interface{}{
+		"retryBackoff":    1.0,
+		"maxRetryBackoff": 4.0,
+	})
+
+	for attempt := 1; attempt < 10; attempt++ {
+		delay := g.backoffFor(attempt)
+		assert.True(t, delay <= 4*time.Second, "backoff should be capped")
+		assert.True(t, delay >= 500*time.Millisecond, "backoff should not drop below half the base")
+	}
+}
+
+func TestStartEmissionCapsInFlight(t *testing.T) {
+	base := BaseHandler{}
+	base.log = defaultLog
+	base.guard = newEmitGuard(map[string]interface{}{"maxInFlight": 1})
+
+	release := make(chan bool)
+	emitFunc := func([]metric.Metric) bool {
+		<-release
+		return true
+	}
+	callbackChannel := make(chan emissionTiming, 2)
+
+	base.startEmission([]metric.Metric{metric.New("first")}, emitFunc, callbackChannel)
+
+	started := make(chan bool)
+	go func() {
+		base.startEmission([]metric.Metric{metric.New("second")}, emitFunc, callbackChannel)
+		started <- true
+	}()
+
+	select {
+	case <-started:
+		t.Fatal("second emission should wait for the first one")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release <- true
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("second emission should start once the first one is done")
+	}
+	release <- true
+}
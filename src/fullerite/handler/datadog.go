@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
 
 	"bytes"
@@ -58,6 +59,19 @@ func newDatadog(
 	return inst
 }
 
+// ConfigKeys describes the keys of the Datadog config
+func (d *Datadog) ConfigKeys() []config.Key {
+	return append(d.BaseHandler.ConfigKeys(),
+		config.Key{Name: "apiKey", Type: config.TypeString, Required: true, Description: "Datadog API key"},
+		config.Key{Name: "endpoint", Type: config.TypeString, Required: true, Description: "URL of the Datadog API"},
+	)
+}
+
+// Description of the Datadog handler
+func (d *Datadog) Description() string {
+	return "Posts the metrics to the Datadog API"
+}
+
 // Configure the Datadog handler
 func (d *Datadog) Configure(configMap map[string]interface{}) {
 	if apiKey, exists := configMap["apiKey"]; exists {
@@ -74,7 +88,7 @@ func (d *Datadog) Configure(configMap map[string]interface{}) {
 }
 
 // Endpoint returns the Datadog API endpoint
-func (d Datadog) Endpoint() string {
+func (d *Datadog) Endpoint() string {
 	return d.endpoint
 }
 
@@ -157,11 +171,11 @@ func (d *Datadog) emitMetrics(metrics []metric.Metric) bool {
 	return false
 }
 
-func (d Datadog) dialTimeout(network, addr string) (net.Conn, error) {
+func (d *Datadog) dialTimeout(network, addr string) (net.Conn, error) {
 	return net.DialTimeout(network, addr, d.timeout)
 }
 
-func (d Datadog) serializedDimensions(m metric.Metric) (dimensions []string) {
+func (d *Datadog) serializedDimensions(m metric.Metric) (dimensions []string) {
 	for name, value := range m.GetDimensions(d.DefaultDimensions()) {
 		dimensions = append(dimensions, name+":"+value)
 	}
@@ -169,6 +183,6 @@ func (d Datadog) serializedDimensions(m metric.Metric) (dimensions []string) {
 }
 
 func makeDatadogPoints(m metric.Metric) []datadogPoint {
-	point := datadogPoint{float64(time.Now().Unix()), m.Value}
+	point := datadogPoint{float64(m.Timestamp.Unix()), m.Value}
 	return []datadogPoint{point}
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"fmt"
+	"fullerite/config"
 	"fullerite/metric"
 	"fullerite/util"
 	"net"
@@ -46,15 +47,28 @@ func newGraphite(
 }
 
 // Server returns the Graphite server's name or IP
-func (g Graphite) Server() string {
+func (g *Graphite) Server() string {
 	return g.server
 }
 
 // Port returns the Graphite server's port number
-func (g Graphite) Port() string {
+func (g *Graphite) Port() string {
 	return g.port
 }
 
+// ConfigKeys describes the keys of the Graphite config
+func (g *Graphite) ConfigKeys() []config.Key {
+	return append(g.BaseHandler.ConfigKeys(),
+		config.Key{Name: "server", Type: config.TypeString, Required: true, Description: "host of the Graphite server"},
+		config.Key{Name: "port", Type: config.TypeInt, Required: true, Description: "plaintext port of the Graphite server"},
+	)
+}
+
+// Description of the Graphite handler
+func (g *Graphite) Description() string {
+	return "Sends the metrics to Graphite over the plaintext protocol, the dimensions are part of the name"
+}
+
 // Configure accepts the different configuration options for the Graphite handler
 func (g *Graphite) Configure(configMap map[string]interface{}) {
 	if server, exists := configMap["server"]; exists {
@@ -76,7 +90,13 @@ func (g *Graphite) Run() {
 	g.run(g.emitMetrics)
 }
 
-func (g Graphite) convertToGraphite(incomingMetric metric.Metric) (datapoint string) {
+// GraphiteDatapoint formats a metric the way the Graphite handler sends it,
+// without a handler prefix or default dimensions
+func GraphiteDatapoint(m metric.Metric) string {
+	return new(Graphite).convertToGraphite(m)
+}
+
+func (g *Graphite) convertToGraphite(incomingMetric metric.Metric) (datapoint string) {
 	//orders dimensions so datapoint keeps consistent name
 	var keys []string
 	dimensions := g.getSanitizedDimensions(incomingMetric)
@@ -89,11 +109,11 @@ func (g Graphite) convertToGraphite(incomingMetric metric.Metric) (datapoint str
 	for _, key := range keys {
 		datapoint = fmt.Sprintf("%s.%s.%s", datapoint, key, dimensions[key])
 	}
-	datapoint = fmt.Sprintf("%s %f %d\n", datapoint, incomingMetric.Value, time.Now().Unix())
+	datapoint = fmt.Sprintf("%s %f %d\n", datapoint, incomingMetric.Value, incomingMetric.Timestamp.Unix())
 	return datapoint
 }
 
-func (g Graphite) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
+func (g *Graphite) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
 	dimSanitized := make(map[string]string)
 	dimensions := incomingMetric.GetDimensions(g.DefaultDimensions())
 	for key, value := range dimensions {
@@ -90,3 +90,13 @@ func TestGraphiteSanitation(t *testing.T) {
 
 	assert.Equal(t, strings.Split(datapoint1, " ")[0], datapoint2, "the two metrics should be the same")
 }
+
+func TestGraphiteUsesMetricTimestamp(t *testing.T) {
+	g := getTestGraphiteHandler(12, 12, 12)
+
+	m := metric.New("Test")
+	m.Timestamp = time.Unix(1450000000, 0)
+	datapoint := g.convertToGraphite(m)
+
+	assert.Equal(t, "Test 0.000000 1450000000\n", datapoint)
+}
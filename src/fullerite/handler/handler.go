@@ -3,11 +3,14 @@ package handler
 import (
 	"fullerite/config"
 	"fullerite/metric"
+	"fullerite/processor"
 	"sync/atomic"
 
 	"container/list"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -34,6 +37,16 @@ func RegisterHandler(name string, f func(chan metric.Metric, int, int, time.Dura
 	handlerConstructs[name] = f
 }
 
+// Names returns the names of the registered handlers, sorted
+func Names() []string {
+	names := make([]string, 0, len(handlerConstructs))
+	for name := range handlerConstructs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // New creates a new Handler based on the requested handler name.
 func New(name string) Handler {
 	channel := make(chan metric.Metric)
@@ -56,12 +69,28 @@ func New(name string) Handler {
 // Handler defines the interface of a generic handler.
 type Handler interface {
 	Run()
+	// Stop flushes the buffered metrics and waits for the emissions
+	// that are still in flight
+	Stop()
+	// Flush emits the buffered metrics without waiting for the interval
+	Flush()
 	Configure(map[string]interface{})
 	InitListeners(config.Config)
+	// UpdateListeners adds and removes collector queues to match the
+	// collectors of the config, also while the handler runs
+	UpdateListeners(config.Config)
+
+	// ConfigKeys describes the keys of the handler config, handlers
+	// with keys of their own add them to the common ones
+	ConfigKeys() []config.Key
+	// Description says where the handler emits to, in one sentence
+	Description() string
 
 	// InternalMetrics is to publish a set of values
 	// that are relevant to the handler itself.
 	InternalMetrics() metric.InternalMetrics
+	// Health is what the health checks of the internal server look at
+	Health() Health
 
 	// taken care of by the base
 	Name() string
@@ -71,6 +100,15 @@ type Handler interface {
 	CollectorChannels() map[string]chan metric.Metric
 	SetCollectorChannels(map[string]chan metric.Metric)
 
+	// Enqueue passes a metric from a collector to the handler according
+	// to the overflow policy, it returns false if a metric was dropped
+	Enqueue(string, metric.Metric) bool
+	// Write passes a metric straight to the handler, it returns false
+	// if the handler was stopped
+	Write(metric.Metric) bool
+	QueueCapacity() int
+	OverflowPolicy() string
+
 	Interval() int
 	SetInterval(int)
 
@@ -102,12 +140,56 @@ type Handler interface {
 	IsCollectorWhiteListed(string) (bool, bool)
 }
 
+// handlerRun keeps track of the goroutines started by run() so that
+// Stop can wait for them
+type handlerRun struct {
+	listeners sync.WaitGroup
+	emissions sync.WaitGroup
+
+	// what the listeners of collectors added later are started with
+	emitFunc        func([]metric.Metric) bool
+	emissionResults chan emissionTiming
+
+	// the listeners by the name of their collector, the one of the metrics
+	// written straight to the handler goes by the empty name
+	controls map[string]listenerControl
+	stopped  bool
+	stop     sync.Once
+}
+
+// listenerControl asks a listener to flush its buffer, which it answers
+// once the buffer is handed to the emissions, or to quit, which it does
+// after emitting what is left in its queue
+type listenerControl struct {
+	flush chan chan struct{}
+	quit  chan struct{}
+}
+
+// emissionWindow holds the timings of the emissions within the last
+// interval, recordEmissions adds to it while InternalMetrics reads it
+type emissionWindow struct {
+	sync.Mutex
+	timings list.List
+}
+
 type emissionTiming struct {
 	timestamp   time.Time
 	duration    time.Duration
 	metricsSent int
 }
 
+// Health tells whether a handler gets its metrics out
+type Health struct {
+	// LastEmission is zero until an emission succeeded
+	LastEmission time.Time
+	// BreakerState is "closed", "half-open" or "open", and empty if
+	// the handler has no circuit breaker
+	BreakerState string
+	MetricsSent  uint64
+	// MetricsDropped includes the metrics that overflowed the queues
+	MetricsDropped uint64
+}
+
 // BaseHandler is class to handle the boiler plate parts of the handlers
 type BaseHandler struct {
 	channel           chan metric.Metric
@@ -125,11 +207,37 @@ type BaseHandler struct {
 	maxIdleConnectionsPerHost int
 	keepAliveInterval         int
 
+	// the queues between collectors and the handler
+	queueCapacity     int
+	overflowPolicy    string
+	metricsOverflowed uint64
+
 	// for tracking
-	emissionTimes  list.List
+	emissionTimes  emissionWindow
 	totalEmissions uint64
 	metricsSent    uint64
 	metricsDropped uint64
+	lastEmission   int64 // unix nanoseconds
+
+	// retries, in-flight cap and circuit breaker for emissions
+	guard *emitGuard
+
+	// set once the handler runs, the lock guards it along with the
+	// collector queues and the guard
+	running *handlerRun
+	lock    sync.Mutex
+
+	// processors that only apply to the metrics of this handler
+	processors *processor.Chain
+
+	// optional rollups of the points of a series within an interval
+	aggregation       *aggregationConfig
+	metricsAggregated uint64
+
+	// optional on-disk spool for batches that failed to emit
+	spool           *spool
+	metricsSpooled  uint64
+	metricsReplayed uint64
 
 	// List of blacklisted collectors
 	// the handler won't accept metrics from
@@ -165,45 +273,51 @@ func (base *BaseHandler) SetDefaultDimensions(defaults map[string]string) {
 }
 
 // Channel : the channel to handler listens for metrics on
-func (base BaseHandler) Channel() chan metric.Metric {
+func (base *BaseHandler) Channel() chan metric.Metric {
 	return base.channel
 }
 
 // CollectorChannels : the channels to handler listens for metrics on
-func (base BaseHandler) CollectorChannels() map[string]chan metric.Metric {
+func (base *BaseHandler) CollectorChannels() map[string]chan metric.Metric {
+	base.lock.Lock()
+	defer base.lock.Unlock()
 	return base.collectorChannels
 }
 
 // SetCollectorChannels : the channels to handler listens for metrics on
 func (base *BaseHandler) SetCollectorChannels(c map[string]chan metric.Metric) {
-	base.collectorChannels = make(map[string]chan metric.Metric)
+	collectorChannels := make(map[string]chan metric.Metric)
 	for name, channel := range c {
-		base.collectorChannels[name] = channel
+		collectorChannels[name] = channel
 	}
+
+	base.lock.Lock()
+	defer base.lock.Unlock()
+	base.collectorChannels = collectorChannels
 }
 
 // Name : the name of the handler
-func (base BaseHandler) Name() string {
+func (base *BaseHandler) Name() string {
 	return base.name
 }
 
 // MaxBufferSize : the maximum number of metrics that should be buffered before sending
-func (base BaseHandler) MaxBufferSize() int {
+func (base *BaseHandler) MaxBufferSize() int {
 	return base.maxBufferSize
 }
 
 // Prefix : the prefix (with punctuation) to use on each emitted metric
-func (base BaseHandler) Prefix() string {
+func (base *BaseHandler) Prefix() string {
 	return base.prefix
 }
 
 // DefaultDimensions : dimensions that should be included in any metric
-func (base BaseHandler) DefaultDimensions() map[string]string {
+func (base *BaseHandler) DefaultDimensions() map[string]string {
 	return base.defaultDimensions
 }
 
 // Interval : the maximum interval that the handler should buffer stats for
-func (base BaseHandler) Interval() int {
+func (base *BaseHandler) Interval() int {
 	return base.interval
 }
 
@@ -226,13 +340,13 @@ func (base *BaseHandler) SetCollectorBlackList(blackList []string) {
 }
 
 // IsCollectorBlackListed : return true if collectorName is blacklisted in the handler
-func (base BaseHandler) IsCollectorBlackListed(collectorName string) (bool, bool) {
+func (base *BaseHandler) IsCollectorBlackListed(collectorName string) (bool, bool) {
 	val, exists := base.blackListedCollectors[collectorName]
 	return val, exists
 }
 
 // CollectorBlackList : return handler specific black listed collectors
-func (base BaseHandler) CollectorBlackList() map[string]bool {
+func (base *BaseHandler) CollectorBlackList() map[string]bool {
 	return base.blackListedCollectors
 }
 
@@ -245,85 +359,173 @@ func (base *BaseHandler) SetCollectorWhiteList(whiteList []string) {
 }
 
 // IsCollectorWhiteListed : return true if collectorName is blacklisted in the handler
-func (base BaseHandler) IsCollectorWhiteListed(collectorName string) (bool, bool) {
+func (base *BaseHandler) IsCollectorWhiteListed(collectorName string) (bool, bool) {
 	val, exists := base.whiteListedCollectors[collectorName]
 	return val, exists
 }
 
 // CollectorWhiteList : return handler specific black listed collectors
-func (base BaseHandler) CollectorWhiteList() map[string]bool {
+func (base *BaseHandler) CollectorWhiteList() map[string]bool {
 	return base.whiteListedCollectors
 }
 
 // MaxIdleConnectionsPerHost : return max idle connections per host
-func (base BaseHandler) MaxIdleConnectionsPerHost() int {
+func (base *BaseHandler) MaxIdleConnectionsPerHost() int {
 	return base.maxIdleConnectionsPerHost
 }
 
 // InitListeners - initiate listener channels for collectors
 func (base *BaseHandler) InitListeners(globalConfig config.Config) {
 	collectorChannels := make(map[string]chan metric.Metric)
-	for _, c := range append(globalConfig.Collectors, globalConfig.DiamondCollectors...) {
-
-		// If the handler's whitelist is set, then only metrics from collectors in it will be emitted. If the same
-		// collector is also in the blacklist, it will be skipped.
-		// If the handler's whitelist is not set and its blacklist is not empty, only metrics from collectors not in
-		// the blacklist will be emitted.
-		isWhiteListed, _ := base.IsCollectorWhiteListed(c)
-		isBlackListed, _ := base.IsCollectorBlackListed(c)
-
-		// If the handler's whitelist is not nil and not empty, only the whitelisted collectors should be considered
-		if base.CollectorWhiteList() != nil && len(base.CollectorWhiteList()) > 0 {
-			if !isWhiteListed || isBlackListed {
-				continue
-			}
-		} else {
-			// If the handler's whitelist is nil, all collector except the ones in the blacklist are enabled
-			if isBlackListed {
-				continue
-			}
-		}
-		collectorChannels[c] = make(chan metric.Metric, 1)
+	for _, c := range base.listenedCollectors(globalConfig) {
+		collectorChannels[c] = make(chan metric.Metric, base.QueueCapacity())
 	}
 	base.SetCollectorChannels(collectorChannels)
 }
 
+// UpdateListeners gives new collectors a queue and retires the queues of
+// the collectors that are gone. Once the handler runs, every new queue gets
+// a listener of its own, and the listener of a retired queue emits what is
+// left in it and returns. The collectors of retired queues must be done
+// enqueueing, what comes late is counted as dropped.
+func (base *BaseHandler) UpdateListeners(globalConfig config.Config) {
+	listened := base.listenedCollectors(globalConfig)
+
+	base.lock.Lock()
+	defer base.lock.Unlock()
+	running := base.running
+	if running != nil && running.stopped {
+		return
+	}
+
+	collectorChannels := make(map[string]chan metric.Metric)
+	for _, name := range listened {
+		if c, exists := base.collectorChannels[name]; exists {
+			collectorChannels[name] = c
+			continue
+		}
+
+		c := make(chan metric.Metric, base.QueueCapacity())
+		collectorChannels[name] = c
+		if running != nil {
+			base.startListener(running, name, c)
+		}
+	}
+
+	for name := range base.collectorChannels {
+		if _, exists := collectorChannels[name]; exists || running == nil {
+			continue
+		}
+		close(running.controls[name].quit)
+		delete(running.controls, name)
+	}
+	base.collectorChannels = collectorChannels
+}
+
+// listenedCollectors returns the collectors of the config the handler takes
+// the metrics of, along with fullerite's own metrics
+func (base *BaseHandler) listenedCollectors(globalConfig config.Config) []string {
+	names := append([]string{InternalCollector}, globalConfig.Collectors...)
+	names = append(names, globalConfig.DiamondCollectors...)
+
+	listened := make([]string, 0, len(names))
+	for _, name := range names {
+		if base.listensTo(name) {
+			listened = append(listened, name)
+		}
+	}
+	return listened
+}
+
+// listensTo tells whether the handler takes the metrics of a collector
+func (base *BaseHandler) listensTo(collectorName string) bool {
+	// If the handler's whitelist is set, then only metrics from collectors in it will be emitted. If the same
+	// collector is also in the blacklist, it will be skipped.
+	// If the handler's whitelist is not set and its blacklist is not empty, only metrics from collectors not in
+	// the blacklist will be emitted.
+	isWhiteListed, _ := base.IsCollectorWhiteListed(collectorName)
+	isBlackListed, _ := base.IsCollectorBlackListed(collectorName)
+
+	// If the handler's whitelist is not nil and not empty, only the whitelisted collectors should be considered
+	if base.CollectorWhiteList() != nil && len(base.CollectorWhiteList()) > 0 {
+		return isWhiteListed && !isBlackListed
+	}
+	// If the handler's whitelist is nil, all collector except the ones in the blacklist are enabled
+	return !isBlackListed
+}
+
 // KeepAliveInterval - return keep alive interval
-func (base BaseHandler) KeepAliveInterval() int {
+func (base *BaseHandler) KeepAliveInterval() int {
 	return base.keepAliveInterval
 }
 
 // String returns the handler name in a printable format.
-func (base BaseHandler) String() string {
+func (base *BaseHandler) String() string {
 	return base.name + "Handler"
 }
 
 // InternalMetrics : Returns the internal metrics that are being collected by this handler
-func (base BaseHandler) InternalMetrics() metric.InternalMetrics {
+func (base *BaseHandler) InternalMetrics() metric.InternalMetrics {
+	base.lock.Lock()
+	guard := base.guard
+	base.lock.Unlock()
+
 	counters := map[string]float64{
-		"totalEmissions": float64(base.totalEmissions),
-		"metricsDropped": float64(base.metricsDropped),
-		"metricsSent":    float64(base.metricsSent),
+		"totalEmissions": float64(atomic.LoadUint64(&base.totalEmissions)),
+		"metricsDropped": float64(atomic.LoadUint64(&base.metricsDropped)),
+		"metricsSent":    float64(atomic.LoadUint64(&base.metricsSent)),
+
+		"metricsOverflowed": float64(atomic.LoadUint64(&base.metricsOverflowed)),
 	}
 	gauges := map[string]float64{
-		"intervalLength":    float64(base.interval),
-		"emissionsInWindow": float64(base.emissionTimes.Len()),
+		"intervalLength": float64(base.interval),
+	}
+
+	if guard != nil {
+		counters["emissionRetries"] = float64(atomic.LoadUint64(&guard.retries))
+		counters["emissionsRejected"] = float64(atomic.LoadUint64(&guard.rejected))
+		counters["breakerTrips"] = float64(guard.breaker.tripCount())
+		gauges["breakerState"] = float64(guard.breaker.currentState())
+		gauges["inFlightEmissions"] = float64(len(guard.inFlight))
+	}
+
+	if base.processors.Len() > 0 {
+		counters["metricsFiltered"] = float64(base.processors.Filtered())
+	}
+
+	if base.aggregation != nil {
+		counters["metricsAggregated"] = float64(atomic.LoadUint64(&base.metricsAggregated))
+	}
+
+	if base.spool != nil {
+		counters["metricsSpooled"] = float64(atomic.LoadUint64(&base.metricsSpooled))
+		counters["metricsReplayed"] = float64(atomic.LoadUint64(&base.metricsReplayed))
+
+		stats := base.spool.stats()
+		gauges["spoolDepth"] = float64(stats.depth)
+		gauges["spoolBytes"] = float64(stats.bytes)
+		gauges["spoolOldestAge"] = stats.oldestAge.Seconds()
+		counters["spoolBytesCorrupt"] = float64(stats.corruptBytes)
 	}
 
 	// now we calculate the average emission seconds for
-	if base.emissionTimes.Len() > 0 {
+	window := &base.emissionTimes
+	window.Lock()
+	defer window.Unlock()
+	gauges["emissionsInWindow"] = float64(window.timings.Len())
+	if window.timings.Len() > 0 {
 		avg := 0.0
 		max := 0.0
 
 		var totalTime float64
-		for e := base.emissionTimes.Front(); e != nil; e = e.Next() {
+		for e := window.timings.Front(); e != nil; e = e.Next() {
 			dur := e.Value.(emissionTiming).duration.Seconds()
 			totalTime += dur
 			if dur > max {
 				max = dur
 			}
 		}
-		avg = totalTime / float64(base.emissionTimes.Len())
+		avg = totalTime / float64(window.timings.Len())
 		gauges["averageEmissionTiming"] = avg
 		gauges["maxEmissionTiming"] = max
 	}
@@ -334,6 +536,80 @@ func (base BaseHandler) InternalMetrics() metric.InternalMetrics {
 	}
 }
 
+// Health : Returns when the handler last emitted, the state of its breaker
+// and how many metrics it sent and dropped since it started
+func (base *BaseHandler) Health() Health {
+	health := Health{
+		MetricsSent: atomic.LoadUint64(&base.metricsSent),
+		MetricsDropped: atomic.LoadUint64(&base.metricsDropped) +
+			atomic.LoadUint64(&base.metricsOverflowed),
+	}
+	if lastEmission := atomic.LoadInt64(&base.lastEmission); lastEmission != 0 {
+		health.LastEmission = time.Unix(0, lastEmission)
+	}
+	base.lock.Lock()
+	guard := base.guard
+	base.lock.Unlock()
+	if guard != nil {
+		health.BreakerState = breakerStateNames[guard.breaker.currentState()]
+	}
+	return health
+}
+
+// ConfigKeys : the keys every handler config may have
+func (base *BaseHandler) ConfigKeys() []config.Key {
+	keys := []config.Key{
+		{Name: "timeout", Type: config.TypeFloat, Default: DefaultTimeoutSec, Description: "seconds an emission may take"},
+		{Name: "max_buffer_size", Type: config.TypeInt, Default: DefaultBufferSize, Description: "metrics to buffer before emitting"},
+		{Name: "interval", Type: config.TypeInt, Default: DefaultInterval, Description: "seconds between emissions of the buffer"},
+		{Name: "defaultDimensions", Type: config.TypeMap, Description: "dimensions added to every metric, over the global ones"},
+		{Name: "keepAliveInterval", Type: config.TypeInt, Default: DefaultKeepAliveInterval, Description: "seconds between TCP keep-alives"},
+		{Name: "maxIdleConnectionsPerHost", Type: config.TypeInt, Default: DefaultMaxIdleConnectionsPerHost,
+			Description: "idle HTTP connections to keep open"},
+		{Name: "collectorBlackList", Type: config.TypeList, Description: "collectors whose metrics are not emitted"},
+		{Name: "collectorWhiteList", Type: config.TypeList, Description: "collectors whose metrics are the only ones emitted"},
+		{Name: "queueCapacity", Type: config.TypeInt, Default: DefaultQueueCapacity, Description: "metrics queued per collector"},
+		{Name: "overflowPolicy", Type: config.TypeString, Default: DefaultOverflowPolicy,
+			Description: "what to do with a metric when its queue is full: block, drop-newest or drop-oldest"},
+		{Name: "spoolDir", Type: config.TypeString, Description: "directory to keep failed emissions in until they can be retried"},
+		{Name: "spoolMaxSize", Type: config.TypeInt, Default: DefaultSpoolMaxSize, Description: "bytes the spool may take"},
+		{Name: "spoolMaxAge", Type: config.TypeInt, Default: DefaultSpoolMaxAge, Description: "seconds after which spooled emissions are dropped"},
+		{Name: "processors", Type: config.TypeAny, Description: "processors applied to the metrics of this handler"},
+		{Name: "aggregation", Type: config.TypeObject, Description: "rollups to emit instead of the raw metrics"},
+	}
+	return append(keys, emitGuardKeys...)
+}
+
+// Description : handlers override it
+func (base *BaseHandler) Description() string {
+	return ""
+}
+
+// CheckConfig reports what is wrong with the config of a handler: unknown
+// keys, values of the wrong type and settings the handler would ignore
+func CheckConfig(h Handler, configMap map[string]interface{}) []error {
+	errs := config.CheckKeys(configMap, h.ConfigKeys())
+
+	if asInterface, exists := configMap["overflowPolicy"]; exists {
+		if policy, ok := asInterface.(string); ok && !isValidOverflowPolicy(policy) {
+			errs = append(errs, fmt.Errorf("unknown overflowPolicy %q", policy))
+		}
+	}
+
+	if asInterface, exists := configMap["processors"]; exists {
+		if _, err := processor.ChainFromConfig(asInterface); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if asInterface, exists := configMap["aggregation"]; exists {
+		if _, err := newAggregationConfig(asInterface); err != nil {
+			errs = append(errs, fmt.Errorf("invalid aggregation: %s", err))
+		}
+	}
+	return errs
+}
+
 // configureCommonParams will extract the common parameters that are used and set them in the handler
 func (base *BaseHandler) configureCommonParams(configMap map[string]interface{}) {
 	if asInterface, exists := configMap["timeout"]; exists {
@@ -375,25 +651,202 @@ func (base *BaseHandler) configureCommonParams(configMap map[string]interface{})
 		whiteList := config.GetAsSlice(asInterface)
 		base.SetCollectorWhiteList(whiteList)
 	}
+
+	if asInterface, exists := configMap["queueCapacity"]; exists {
+		base.queueCapacity = config.GetAsInt(asInterface, DefaultQueueCapacity)
+	}
+
+	if asInterface, exists := configMap["overflowPolicy"]; exists {
+		if policy, ok := asInterface.(string); ok && isValidOverflowPolicy(policy) {
+			base.overflowPolicy = policy
+		} else {
+			base.log.Error("Unknown overflowPolicy ", asInterface, ", falling back to ", DefaultOverflowPolicy)
+		}
+	}
+
+	if asInterface, exists := configMap["spoolDir"]; exists {
+		base.configureSpool(asInterface, configMap)
+	}
+
+	if asInterface, exists := configMap["processors"]; exists {
+		chain, err := processor.ChainFromConfig(asInterface)
+		if err != nil {
+			base.log.Error("Ignoring processors of ", base.name, ": ", err)
+		} else {
+			base.processors = chain
+		}
+	}
+
+	if asInterface, exists := configMap["aggregation"]; exists {
+		aggregation, err := newAggregationConfig(asInterface)
+		if err != nil {
+			base.log.Error("Not aggregating metrics of ", base.name, ": ", err)
+		}
+		base.aggregation = aggregation
+	}
+
+	guard := newEmitGuard(configMap)
+	base.lock.Lock()
+	base.guard = guard
+	base.lock.Unlock()
+}
+
+// configureSpool sets up the on-disk spool. The spool directory should not be
+// shared between handlers.
+func (base *BaseHandler) configureSpool(dir interface{}, configMap map[string]interface{}) {
+	maxSize := DefaultSpoolMaxSize
+	if asInterface, exists := configMap["spoolMaxSize"]; exists {
+		maxSize = config.GetAsInt(asInterface, DefaultSpoolMaxSize)
+	}
+
+	maxAge := DefaultSpoolMaxAge
+	if asInterface, exists := configMap["spoolMaxAge"]; exists {
+		maxAge = config.GetAsInt(asInterface, DefaultSpoolMaxAge)
+	}
+
+	spoolDir, ok := dir.(string)
+	if !ok || spoolDir == "" {
+		base.log.Error("Invalid spoolDir ", dir, ", failed emissions will be dropped")
+		return
+	}
+
+	s, err := newSpool(spoolDir, int64(maxSize), time.Duration(maxAge)*time.Second)
+	if err != nil {
+		base.log.Error("Failed to create spool at ", spoolDir, ": ", err)
+		return
+	}
+	base.spool = s
 }
 
 func (base *BaseHandler) run(emitFunc func([]metric.Metric) bool) {
 	emissionResults := make(chan emissionTiming)
 	go base.recordEmissions(emissionResults)
 
-	go base.listenForMetrics(emitFunc, base.Channel(), emissionResults)
-	for k := range base.CollectorChannels() {
-		go base.listenForMetrics(emitFunc, base.CollectorChannels()[k], emissionResults)
+	base.lock.Lock()
+	defer base.lock.Unlock()
+	if base.guard == nil {
+		// handlers that were never configured still get the defaults
+		base.guard = newEmitGuard(map[string]interface{}{})
+	}
+
+	// everything is set up before the first listener starts emitting
+	running := &handlerRun{
+		emitFunc:        emitFunc,
+		emissionResults: emissionResults,
+		controls:        make(map[string]listenerControl),
+	}
+	base.running = running
+
+	// the metrics written straight to the handler go by the empty name
+	base.startListener(running, "", base.channel)
+	for name, c := range base.collectorChannels {
+		base.startListener(running, name, c)
+	}
+}
+
+// startListener reads the queue in the background until it is asked to
+// quit. The caller must hold the lock.
+func (base *BaseHandler) startListener(running *handlerRun, name string, c chan metric.Metric) {
+	control := listenerControl{
+		flush: make(chan chan struct{}),
+		quit:  make(chan struct{}),
+	}
+	running.controls[name] = control
+	running.listeners.Add(1)
+	go func() {
+		defer running.listeners.Done()
+		base.listenForMetrics(running.emitFunc, c, control, running.emissionResults)
+	}()
+}
+
+// currentRun returns what the running handler keeps track of, and nil
+// before it runs
+func (base *BaseHandler) currentRun() *handlerRun {
+	base.lock.Lock()
+	defer base.lock.Unlock()
+	return base.running
+}
+
+// Flush makes every listener emit what it has buffered right away, without
+// waiting for the interval. It returns once the emissions have started.
+func (base *BaseHandler) Flush() {
+	base.lock.Lock()
+	controls := []listenerControl{}
+	if base.running != nil {
+		for _, control := range base.running.controls {
+			controls = append(controls, control)
+		}
+	}
+	base.lock.Unlock()
+	if len(controls) == 0 {
+		return
+	}
+
+	for _, control := range controls {
+		done := make(chan struct{})
+		select {
+		case control.flush <- done:
+			<-done
+		case <-control.quit:
+			// its queue was retired or the handler stopped meanwhile
+		}
+	}
+	base.log.Info("Flushed the buffered metrics of ", base.name)
+}
+
+// Stop asks every listener to flush what it has buffered and waits until
+// those emissions are done. Stopping again only waits for the first Stop.
+func (base *BaseHandler) Stop() {
+	running := base.currentRun()
+	if running == nil {
+		return
 	}
+
+	running.stop.Do(func() {
+		base.lock.Lock()
+		running.stopped = true
+		for _, control := range running.controls {
+			close(control.quit)
+		}
+		base.lock.Unlock()
+
+		running.listeners.Wait()
+		running.emissions.Wait()
+		base.log.Info("Flushed all metrics of ", base.name)
+	})
 }
 
 func (base *BaseHandler) listenForMetrics(
 	emitFunc func([]metric.Metric) bool,
 	c <-chan metric.Metric,
+	control listenerControl,
 	emissionResults chan<- emissionTiming) {
-
 	metrics := make([]metric.Metric, 0, base.MaxBufferSize())
-	currentBufferSize := 0
+	aggregator := base.aggregation.newAggregator()
+
+	buffer := func(incomingMetric metric.Metric) {
+		// metrics written straight to the handler (rather than read
+		// from a collector) may not carry a timestamp yet
+		incomingMetric.SetTimestampIfUnset(time.Now())
+
+		var keep bool
+		if incomingMetric, keep = base.processors.ProcessMetric(incomingMetric); !keep {
+			return
+		}
+		if aggregator.add(incomingMetric) {
+			atomic.AddUint64(&base.metricsAggregated, 1)
+			return
+		}
+		base.log.Debug(base.Name(), " metric: ", incomingMetric)
+		metrics = append(metrics, incomingMetric)
+
+		if len(metrics) >= base.MaxBufferSize() {
+			base.startEmission(metrics, emitFunc, emissionResults)
+
+			// will get copied into this call, meaning it's ok to clear it
+			metrics = make([]metric.Metric, 0, base.MaxBufferSize())
+		}
+	}
 
 	ticker := time.NewTicker(time.Duration(base.Interval()) * time.Second)
 	flusher := ticker.C
@@ -407,54 +860,118 @@ stopReading:
 				// we have been asked to stop reading.
 				break stopReading
 			}
-			base.log.Debug(base.Name(), " metric: ", incomingMetric)
-			metrics = append(metrics, incomingMetric)
-			currentBufferSize++
+			buffer(incomingMetric)
+		case <-flusher:
+			metrics = append(metrics, aggregator.flush(time.Now())...)
+			base.emitInBatches(metrics, emitFunc, emissionResults)
+			metrics = make([]metric.Metric, 0, base.MaxBufferSize())
+		case done := <-control.flush:
+			metrics = append(metrics, aggregator.flush(time.Now())...)
+			base.emitInBatches(metrics, emitFunc, emissionResults)
+			metrics = make([]metric.Metric, 0, base.MaxBufferSize())
+			close(done)
+		case <-control.quit:
+			drainQueue(c, buffer)
+			break stopReading
+		}
+	}
+	ticker.Stop()
 
-			if int(currentBufferSize) >= base.MaxBufferSize() {
-				go base.emitAndTime(metrics, emitFunc, emissionResults)
+	// don't lose what is left in the buffer
+	metrics = append(metrics, aggregator.flush(time.Now())...)
+	base.emitInBatches(metrics, emitFunc, emissionResults)
+}
 
-				// will get copied into this call, meaning it's ok to clear it
-				metrics = make([]metric.Metric, 0, base.MaxBufferSize())
-				currentBufferSize = 0
-			}
-		case <-flusher:
-			if currentBufferSize > 0 {
-				go base.emitAndTime(metrics, emitFunc, emissionResults)
-				metrics = make([]metric.Metric, 0, base.MaxBufferSize())
-				currentBufferSize = 0
+// drainQueue buffers what is still in the queue, without waiting for more
+func drainQueue(c <-chan metric.Metric, buffer func(metric.Metric)) {
+	for {
+		select {
+		case incomingMetric := <-c:
+			if incomingMetric.ZeroValue() {
+				return
 			}
+			buffer(incomingMetric)
+		default:
+			return
 		}
 	}
-	ticker.Stop()
+}
 
+// emitInBatches emits the metrics in batches of at most the buffer size
+func (base *BaseHandler) emitInBatches(
+	metrics []metric.Metric,
+	emitFunc func([]metric.Metric) bool,
+	emissionResults chan<- emissionTiming) {
+	for len(metrics) > 0 {
+		size := base.MaxBufferSize()
+		if size <= 0 || size > len(metrics) {
+			size = len(metrics)
+		}
+		base.startEmission(metrics[:size], emitFunc, emissionResults)
+		metrics = metrics[size:]
+	}
 }
 
 // manages the rolling window of emissions
 // the emissions are a timesorted list, and we purge things older than
 // the base handler's interval
 func (base *BaseHandler) recordEmissions(timingsChannel <-chan emissionTiming) {
+	window := &base.emissionTimes
 	for timing := range timingsChannel {
 		atomic.AddUint64(&base.totalEmissions, 1)
 		now := time.Now()
 
-		base.emissionTimes.PushBack(timing)
+		window.Lock()
+		window.timings.PushBack(timing)
 
 		// now kill the list of old times, iterate through the list until we find
 		// a timestamp that is within the interval
 		minTime := now.Add(time.Duration(-1*base.interval) * time.Second)
 		toRemove := []*list.Element{}
-		for e := base.emissionTimes.Front(); e != nil && minTime.After(e.Value.(emissionTiming).timestamp); e = e.Next() {
+		for e := window.timings.Front(); e != nil && minTime.After(e.Value.(emissionTiming).timestamp); e = e.Next() {
 			toRemove = append(toRemove, e)
 		}
 
 		for i := range toRemove {
-			base.emissionTimes.Remove(toRemove[i])
+			window.timings.Remove(toRemove[i])
 		}
-		base.log.Debug("We removed ", len(toRemove), " entries and now have ", base.emissionTimes.Len())
+		remaining := window.timings.Len()
+		window.Unlock()
+		base.log.Debug("We removed ", len(toRemove), " entries and now have ", remaining)
 	}
 }
 
+// startEmission emits the metrics in the background. It blocks while the
+// handler already has the maximum number of emissions in flight.
+func (base *BaseHandler) startEmission(
+	metrics []metric.Metric,
+	emitFunc func([]metric.Metric) bool,
+	callbackChannel chan<- emissionTiming,
+) {
+	if tapping() {
+		tapMetrics(base.name, metrics)
+	}
+	base.lock.Lock()
+	running, guard := base.running, base.guard
+	base.lock.Unlock()
+	if running != nil {
+		running.emissions.Add(1)
+	}
+
+	if guard != nil {
+		guard.acquire()
+	}
+	go func() {
+		if running != nil {
+			defer running.emissions.Done()
+		}
+		if guard != nil {
+			defer guard.release()
+		}
+		base.emitAndTime(metrics, emitFunc, callbackChannel)
+	}()
+}
+
 func (base *BaseHandler) emitAndTime(
 	metrics []metric.Metric,
 	emitFunc func([]metric.Metric) bool,
@@ -462,7 +979,7 @@ func (base *BaseHandler) emitAndTime(
 ) {
 	numMetrics := len(metrics)
 	beforeEmission := time.Now()
-	result := emitFunc(metrics)
+	result := base.guardedEmit(metrics, emitFunc)
 	afterEmission := time.Now()
 
 	emissionDuration := afterEmission.Sub(beforeEmission)
@@ -482,7 +999,78 @@ func (base *BaseHandler) emitAndTime(
 
 	if result {
 		atomic.AddUint64(&base.metricsSent, uint64(numMetrics))
+		atomic.StoreInt64(&base.lastEmission, afterEmission.UnixNano())
+		if base.spool != nil {
+			base.replaySpool(emitFunc)
+		}
+	} else if base.spool != nil {
+		base.spoolMetrics(metrics)
 	} else {
 		atomic.AddUint64(&base.metricsDropped, uint64(numMetrics))
 	}
 }
+
+// spoolMetrics writes a failed batch to the spool, metrics are only dropped
+// if they cannot be written or the spool overflows.
+func (base *BaseHandler) spoolMetrics(metrics []metric.Metric) {
+	evicted, err := base.spool.write(metrics)
+	if err != nil {
+		base.log.Error("Failed to spool ", len(metrics), " metrics: ", err)
+		atomic.AddUint64(&base.metricsDropped, uint64(len(metrics)))
+		return
+	}
+	atomic.AddUint64(&base.metricsSpooled, uint64(len(metrics)))
+	if evicted > 0 {
+		base.log.Warn("Spool is full, dropped ", evicted, " of the oldest metrics")
+		atomic.AddUint64(&base.metricsDropped, uint64(evicted))
+	}
+}
+
+// guardedEmit emits with the retries and circuit breaker of the handler
+func (base *BaseHandler) guardedEmit(metrics []metric.Metric, emitFunc func([]metric.Metric) bool) bool {
+	base.lock.Lock()
+	guard := base.guard
+	base.lock.Unlock()
+	if guard == nil {
+		return emitFunc(metrics)
+	}
+	return guard.emit(metrics, emitFunc)
+}
+
+// replaySpool emits the spooled batches in the order they were spooled. It
+// stops at the first batch that fails so the order is kept for the next try.
+// Only a few batches are replayed at a time, the replay holds the emission
+// slot of the emission that succeeded and a long backlog would keep new
+// emissions and Stop waiting.
+func (base *BaseHandler) replaySpool(emitFunc func([]metric.Metric) bool) {
+	if !base.spool.startReplay() {
+		return
+	}
+	defer base.spool.endReplay()
+
+	if expired := base.spool.expire(); expired > 0 {
+		base.log.Warn("Dropped ", expired, " spooled metrics that were too old")
+		atomic.AddUint64(&base.metricsDropped, uint64(expired))
+	}
+
+	for i := 0; i < spoolReplayBatches; i++ {
+		entry, metrics, err := base.spool.next()
+		if err != nil {
+			base.log.Error("Failed to read from spool: ", err)
+			return
+		}
+		if entry == nil {
+			return
+		}
+		// a breaker that is open rejects the replay right away, the
+		// spool is replayed again after the next successful emission
+		if !base.guardedEmit(metrics, emitFunc) {
+			return
+		}
+		base.spool.remove(entry)
+		base.log.Info("Replayed ", len(metrics), " spooled metrics to ", base.name)
+		atomic.AddUint64(&base.metricsReplayed, uint64(len(metrics)))
+		atomic.AddUint64(&base.metricsSent, uint64(len(metrics)))
+		atomic.StoreInt64(&base.lastEmission, time.Now().UnixNano())
+	}
+}
@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
 
 	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func assertEmpty(t *testing.T, channel chan metric.Metric) {
@@ -19,7 +24,7 @@ func assertEmpty(t *testing.T, channel chan metric.Metric) {
 }
 
 func TestNewHandler(t *testing.T) {
-	names := []string{"Graphite", "Kairos", "SignalFx", "Datadog", "Log"}
+	names := []string{"Graphite", "Kairos", "SignalFx", "Datadog", "Log", "Influx"}
 	for _, name := range names {
 		h := New(name)
 		assert.NotNil(t, h, "should create a Handler for "+name)
@@ -45,6 +50,17 @@ func TestNewHandler(t *testing.T) {
 }
 
 // If configured, per handler dimensions should over write default dimensions
+func TestHandlersDescribeThemselves(t *testing.T) {
+	for _, name := range Names() {
+		h := New(name)
+		assert.NotEmpty(t, h.Description(), "%s should have a description", name)
+		assert.Empty(t, config.CheckDefaults(h.ConfigKeys()), "%s should document defaults of the right type", name)
+		for _, key := range h.ConfigKeys() {
+			assert.NotEmpty(t, key.Description, "%s should describe %s", name, key.Name)
+		}
+	}
+}
+
 func TestPerHandlerDimensions(t *testing.T) {
 	b := new(BaseHandler)
 	dims := map[string]string{"test": "test value", "host": "test host"}
@@ -119,6 +135,124 @@ func TestEmissionAndRecord(t *testing.T) {
 	callbackChannel = nil
 }
 
+func TestEmissionFailureIsSpooledAndReplayed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fullerite_spool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_spool")
+	base.configureCommonParams(map[string]interface{}{
+		"spoolDir":   dir,
+		"maxRetries": 0,
+	})
+	assert.NotNil(t, base.spool)
+
+	callbackChannel := make(chan emissionTiming, 2)
+	emitted := [][]metric.Metric{}
+	failing := func([]metric.Metric) bool { return false }
+	working := func(metrics []metric.Metric) bool {
+		emitted = append(emitted, metrics)
+		return true
+	}
+
+	base.emitAndTime([]metric.Metric{metric.New("spooled")}, failing, callbackChannel)
+	assert.Equal(t, uint64(0), base.metricsDropped)
+	assert.Equal(t, uint64(1), base.metricsSpooled)
+	assert.Equal(t, 1, base.spool.stats().depth)
+
+	base.emitAndTime([]metric.Metric{metric.New("live")}, working, callbackChannel)
+	assert.Equal(t, 2, len(emitted))
+	assert.Equal(t, "live", emitted[0][0].Name)
+	assert.Equal(t, "spooled", emitted[1][0].Name)
+	assert.Equal(t, uint64(1), base.metricsReplayed)
+	assert.Equal(t, uint64(2), base.metricsSent)
+	assert.Equal(t, 0, base.spool.stats().depth)
+
+	im := base.InternalMetrics()
+	assert.Equal(t, 1.0, im.Counters["metricsSpooled"])
+	assert.Equal(t, 1.0, im.Counters["metricsReplayed"])
+	assert.Equal(t, 0.0, im.Gauges["spoolDepth"])
+	assert.Equal(t, 0.0, im.Gauges["spoolBytes"])
+}
+
+func TestSpoolReplayIsBounded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fullerite_spool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_spool")
+	base.configureCommonParams(map[string]interface{}{
+		"spoolDir":   dir,
+		"maxRetries": 0,
+	})
+	assert.NotNil(t, base.spool)
+
+	callbackChannel := make(chan emissionTiming, spoolReplayBatches+3)
+	failing := func([]metric.Metric) bool { return false }
+	working := func([]metric.Metric) bool { return true }
+	for i := 0; i < spoolReplayBatches+1; i++ {
+		base.emitAndTime([]metric.Metric{metric.New("spooled")}, failing, callbackChannel)
+	}
+
+	base.emitAndTime([]metric.Metric{metric.New("live")}, working, callbackChannel)
+	assert.Equal(t, 1, base.spool.stats().depth, "should leave the rest for the next emission")
+	assert.Equal(t, uint64(spoolReplayBatches), base.metricsReplayed)
+
+	base.emitAndTime([]metric.Metric{metric.New("live")}, working, callbackChannel)
+	assert.Equal(t, 0, base.spool.stats().depth)
+}
+
+func TestBatchBiggerThanTheSpoolIsOnlyDropped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fullerite_spool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_spool")
+	base.configureCommonParams(map[string]interface{}{
+		"spoolDir":   dir,
+		"maxRetries": 0,
+	})
+	assert.NotNil(t, base.spool)
+	base.spool.maxSize = 1
+
+	failing := func([]metric.Metric) bool { return false }
+	base.emitAndTime([]metric.Metric{metric.New("big")}, failing, make(chan emissionTiming, 1))
+	assert.Equal(t, uint64(1), base.metricsDropped)
+	assert.Equal(t, uint64(0), base.metricsSpooled, "should not count the batch as spooled")
+	assert.Equal(t, 0, base.spool.stats().depth)
+}
+
+func TestSpoolIsNotReplayedWhileTheBreakerIsOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fullerite_spool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_spool")
+	base.configureCommonParams(map[string]interface{}{
+		"spoolDir":         dir,
+		"maxRetries":       0,
+		"breakerThreshold": 1,
+		"breakerCooldown":  60,
+	})
+
+	callbackChannel := make(chan emissionTiming, 1)
+	base.emitAndTime([]metric.Metric{metric.New("spooled")}, func([]metric.Metric) bool { return false }, callbackChannel)
+	assert.Equal(t, 1, base.spool.stats().depth)
+
+	replayed := 0
+	base.replaySpool(func(metrics []metric.Metric) bool {
+		replayed += len(metrics)
+		return true
+	})
+	assert.Equal(t, 0, replayed, "the open breaker should hold the replay back")
+	assert.Equal(t, 1, base.spool.stats().depth)
+	assert.Equal(t, uint64(0), base.metricsReplayed)
+}
+
 func TestRecordTimings(t *testing.T) {
 	base := BaseHandler{}
 	base.log = l.WithField("testing", "basehandler_record")
@@ -131,8 +265,8 @@ func TestRecordTimings(t *testing.T) {
 
 	// create a list of emissions in order with some older than 1 second
 	timingsChannel := make(chan emissionTiming)
-	base.emissionTimes.PushBack(emissionTiming{now.Add(minusSixSec), someDur, 0})
-	base.emissionTimes.PushBack(emissionTiming{now.Add(minusFiveSec), someDur, 0})
+	base.emissionTimes.timings.PushBack(emissionTiming{now.Add(minusSixSec), someDur, 0})
+	base.emissionTimes.timings.PushBack(emissionTiming{now.Add(minusFiveSec), someDur, 0})
 
 	go func() {
 		timingsChannel <- emissionTiming{now, someDur, 0}
@@ -140,7 +274,7 @@ func TestRecordTimings(t *testing.T) {
 	}()
 
 	base.recordEmissions(timingsChannel)
-	assert.Equal(t, 1, base.emissionTimes.Len())
+	assert.Equal(t, 1, base.emissionTimes.timings.Len())
 	timingsChannel = nil
 }
 
@@ -151,17 +285,12 @@ func TestHandlerRunFlushInterval(t *testing.T) {
 	base.maxBufferSize = 2
 	base.channel = make(chan metric.Metric)
 
-	emitCalledOnce := false
-	emitCalledTwice := false
-	emitCalledThrice := false
+	var lock sync.Mutex
+	emissionSizes := []int{}
 	emitFunc := func(metrics []metric.Metric) bool {
-		if emitCalledOnce && !emitCalledTwice {
-			assert.Equal(t, 1, len(metrics))
-			emitCalledTwice = true
-		} else {
-			assert.Equal(t, 2, len(metrics))
-			emitCalledOnce = true
-		}
+		lock.Lock()
+		defer lock.Unlock()
+		emissionSizes = append(emissionSizes, len(metrics))
 		return true
 	}
 
@@ -172,13 +301,15 @@ func TestHandlerRunFlushInterval(t *testing.T) {
 	base.channel <- metric.New("testMetric1")
 	base.channel <- metric.New("testMetric2")
 	time.Sleep(2 * time.Second)
-	assert.True(t, emitCalledOnce)
-	assert.True(t, emitCalledTwice)
-	assert.False(t, emitCalledThrice)
-	assert.Equal(t, 1, base.emissionTimes.Len())
-	assert.Equal(t, uint64(3), base.metricsSent)
-	assert.Equal(t, uint64(0), base.metricsDropped)
-	assert.Equal(t, uint64(2), base.totalEmissions)
+	lock.Lock()
+	// the full buffer is emitted right away, the rest on the interval
+	assert.Equal(t, []int{2, 1}, emissionSizes)
+	lock.Unlock()
+	internalMetrics := base.InternalMetrics()
+	assert.Equal(t, 1.0, internalMetrics.Gauges["emissionsInWindow"])
+	assert.Equal(t, 3.0, internalMetrics.Counters["metricsSent"])
+	assert.Equal(t, 0.0, internalMetrics.Counters["metricsDropped"])
+	assert.Equal(t, 2.0, internalMetrics.Counters["totalEmissions"])
 	base.channel <- metric.Metric{}
 }
 
@@ -189,10 +320,9 @@ func TestHandlerRun(t *testing.T) {
 	base.maxBufferSize = 1
 	base.channel = make(chan metric.Metric)
 
-	emitCalled := false
+	emitted := make(chan int, 1)
 	emitFunc := func(metrics []metric.Metric) bool {
-		assert.Equal(t, 1, len(metrics))
-		emitCalled = true
+		emitted <- len(metrics)
 		return true
 	}
 
@@ -201,14 +331,161 @@ func TestHandlerRun(t *testing.T) {
 
 	base.channel <- metric.New("testMetric")
 	time.Sleep(1 * time.Second)
-	assert.True(t, emitCalled)
-	assert.Equal(t, 1, base.emissionTimes.Len())
-	assert.Equal(t, uint64(1), base.metricsSent)
-	assert.Equal(t, uint64(0), base.metricsDropped)
-	assert.Equal(t, uint64(1), base.totalEmissions)
+	select {
+	case size := <-emitted:
+		assert.Equal(t, 1, size)
+	default:
+		assert.Fail(t, "the metric was not emitted")
+	}
+	internalMetrics := base.InternalMetrics()
+	assert.Equal(t, 1.0, internalMetrics.Gauges["emissionsInWindow"])
+	assert.Equal(t, 1.0, internalMetrics.Counters["metricsSent"])
+	assert.Equal(t, 0.0, internalMetrics.Counters["metricsDropped"])
+	assert.Equal(t, 1.0, internalMetrics.Counters["totalEmissions"])
 	base.channel <- metric.Metric{}
 }
 
+func TestHandlerStopFlushesBuffer(t *testing.T) {
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_stop")
+	base.interval = 60
+	base.maxBufferSize = 10
+	base.channel = make(chan metric.Metric)
+	base.collectorChannels = map[string]chan metric.Metric{
+		"Test": make(chan metric.Metric),
+	}
+
+	emitted := make(chan int, 2)
+	emitFunc := func(metrics []metric.Metric) bool {
+		emitted <- len(metrics)
+		return true
+	}
+
+	base.run(emitFunc)
+	base.channel <- metric.New("testMetric")
+	base.collectorChannels["Test"] <- metric.New("testMetric1")
+	base.collectorChannels["Test"] <- metric.New("testMetric2")
+
+	// neither the buffer size nor the interval was reached yet
+	assert.Equal(t, 0, len(emitted))
+
+	base.Stop()
+	close(emitted)
+	total := 0
+	for count := range emitted {
+		total += count
+	}
+	assert.Equal(t, 3, total, "should have flushed all buffered metrics")
+	assert.NotPanics(t, base.Stop, "stopping twice should be harmless")
+}
+
+func TestHandlerFlush(t *testing.T) {
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_flush")
+	base.interval = 60
+	base.maxBufferSize = 10
+	base.channel = make(chan metric.Metric)
+	base.collectorChannels = map[string]chan metric.Metric{
+		"Test": make(chan metric.Metric),
+	}
+
+	emitted := make(chan int, 2)
+	base.run(func(metrics []metric.Metric) bool {
+		emitted <- len(metrics)
+		return true
+	})
+	base.channel <- metric.New("testMetric")
+	base.collectorChannels["Test"] <- metric.New("testMetric1")
+	base.collectorChannels["Test"] <- metric.New("testMetric2")
+
+	base.Flush()
+	base.running.emissions.Wait()
+	assert.Equal(t, 2, len(emitted), "should have emitted both buffers")
+	assert.Equal(t, 3, <-emitted+<-emitted)
+
+	base.Stop()
+	base.Flush()
+	assert.Equal(t, 0, len(emitted), "should not flush once stopped")
+}
+
+func TestHandlerUpdateListeners(t *testing.T) {
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_update_listeners")
+	base.interval = 60
+	base.maxBufferSize = 10
+	base.channel = make(chan metric.Metric)
+	base.InitListeners(config.Config{Collectors: []string{"Test"}})
+
+	emitted := make(chan int, 3)
+	base.run(func(metrics []metric.Metric) bool {
+		emitted <- len(metrics)
+		return true
+	})
+	base.collectorChannels["Test"] <- metric.New("testMetric1")
+
+	base.UpdateListeners(config.Config{Collectors: []string{"Other"}})
+	assert.NotContains(t, base.CollectorChannels(), "Test")
+	assert.Contains(t, base.CollectorChannels(), InternalCollector)
+	require.Contains(t, base.CollectorChannels(), "Other")
+
+	select {
+	case count := <-emitted:
+		assert.Equal(t, 1, count, "the retired queue should have been flushed")
+	case <-time.After(time.Second):
+		t.Fatal("the listener of the retired queue did not emit")
+	}
+
+	base.collectorChannels["Other"] <- metric.New("testMetric2")
+	base.Flush()
+	base.Stop()
+	assert.Equal(t, 1, <-emitted, "the new queue should have a listener")
+}
+
+func TestHandlerProcessors(t *testing.T) {
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_processors")
+	base.interval = 60
+	base.maxBufferSize = 10
+	base.channel = make(chan metric.Metric)
+	base.configureCommonParams(map[string]interface{}{
+		"processors": []interface{}{
+			map[string]interface{}{"type": "scale", "factor": 2.0},
+			map[string]interface{}{
+				"type":  "filter",
+				"match": map[string]interface{}{"name": "^noisy"},
+			},
+		},
+	})
+
+	emitted := make(chan []metric.Metric, 1)
+	base.run(func(metrics []metric.Metric) bool {
+		emitted <- metrics
+		return true
+	})
+
+	base.channel <- metric.WithValue("noisy.metric", 1)
+	base.channel <- metric.WithValue("useful.metric", 21)
+	base.Stop()
+
+	metrics := <-emitted
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, "useful.metric", metrics[0].Name)
+	assert.Equal(t, 42.0, metrics[0].Value)
+	assert.Equal(t, 1.0, base.InternalMetrics().Counters["metricsFiltered"])
+}
+
+func TestHandlerInvalidProcessors(t *testing.T) {
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_processors")
+	base.configureCommonParams(map[string]interface{}{
+		"processors": []interface{}{
+			map[string]interface{}{"type": "scale", "factor": 2.0},
+			map[string]interface{}{"type": "filter"},
+		},
+	})
+	assert.Equal(t, 0, base.processors.Len(), "should not run the valid half of the processors")
+}
+
 func TestInternalMetrics(t *testing.T) {
 	base := BaseHandler{}
 	base.totalEmissions = 10
@@ -217,18 +494,19 @@ func TestInternalMetrics(t *testing.T) {
 	base.interval = 4
 
 	timing := emissionTiming{time.Now(), 5 * time.Second, 0}
-	base.emissionTimes.PushBack(timing)
+	base.emissionTimes.timings.PushBack(timing)
 	timing = emissionTiming{time.Now(), 10 * time.Second, 0}
-	base.emissionTimes.PushBack(timing)
+	base.emissionTimes.timings.PushBack(timing)
 	timing = emissionTiming{time.Now(), 6 * time.Second, 0}
-	base.emissionTimes.PushBack(timing)
+	base.emissionTimes.timings.PushBack(timing)
 
 	results := base.InternalMetrics()
 	expected := metric.InternalMetrics{
 		Counters: map[string]float64{
-			"metricsDropped": 100,
-			"metricsSent":    2,
-			"totalEmissions": 10,
+			"metricsDropped":    100,
+			"metricsOverflowed": 0,
+			"metricsSent":       2,
+			"totalEmissions":    10,
 		},
 		Gauges: map[string]float64{
 			"averageEmissionTiming": 7,
@@ -245,9 +523,10 @@ func TestInternalMetricsWithNan(t *testing.T) {
 
 	expected := metric.InternalMetrics{
 		Counters: map[string]float64{
-			"metricsDropped": 0,
-			"metricsSent":    0,
-			"totalEmissions": 0,
+			"metricsDropped":    0,
+			"metricsOverflowed": 0,
+			"metricsSent":       0,
+			"totalEmissions":    0,
 		},
 		// specifically missing the averageEmissionTiming
 		// because we have no emissions yet
@@ -260,6 +539,30 @@ func TestInternalMetricsWithNan(t *testing.T) {
 	assert.Equal(t, expected, im)
 }
 
+func TestHealth(t *testing.T) {
+	base := BaseHandler{}
+	base.log = l.WithField("testing", "basehandler_health")
+	base.metricsOverflowed = 3
+
+	health := base.Health()
+	assert.True(t, health.LastEmission.IsZero())
+	assert.Equal(t, "", health.BreakerState)
+	assert.Equal(t, uint64(3), health.MetricsDropped)
+
+	base.guard = newEmitGuard(map[string]interface{}{"maxRetries": 0, "breakerThreshold": 1})
+	callbackChannel := make(chan emissionTiming, 2)
+	metrics := []metric.Metric{metric.New("example")}
+	before := time.Now()
+	base.emitAndTime(metrics, func([]metric.Metric) bool { return true }, callbackChannel)
+	base.emitAndTime(metrics, func([]metric.Metric) bool { return false }, callbackChannel)
+
+	health = base.Health()
+	assert.False(t, health.LastEmission.Before(before))
+	assert.Equal(t, "open", health.BreakerState)
+	assert.Equal(t, uint64(1), health.MetricsSent)
+	assert.Equal(t, uint64(4), health.MetricsDropped)
+}
+
 func TestKeepAliveConfig(t *testing.T) {
 	base := BaseHandler{}
 
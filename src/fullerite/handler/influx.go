@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultInfluxAPIVersion    = 1
+	defaultInfluxPrecision     = "s"
+	defaultInfluxMaxPacketSize = 1400 // stays under the MTU of most networks
+)
+
+// influxPrecisions are the units the timestamps can be written in, with the
+// names the v1 API knows them by
+var influxPrecisions = map[string]struct {
+	unit time.Duration
+	v1   string
+}{
+	"ns": {time.Nanosecond, "n"},
+	"us": {time.Microsecond, "u"},
+	"ms": {time.Millisecond, "ms"},
+	"s":  {time.Second, "s"},
+}
+
+func init() {
+	RegisterHandler("Influx", newInflux)
+}
+
+// Influx handler
+type Influx struct {
+	BaseHandler
+	endpoint      string
+	apiVersion    int
+	precision     string
+	maxPacketSize int
+
+	// the v1 API
+	database        string
+	retentionPolicy string
+	username        string
+	password        string
+
+	// the v2 API
+	org    string
+	bucket string
+	token  string
+
+	client *http.Client
+}
+
+// newInflux returns a new Influx handler
+func newInflux(
+	channel chan metric.Metric,
+	initialInterval int,
+	initialBufferSize int,
+	initialTimeout time.Duration,
+	log *l.Entry) Handler {
+
+	inst := new(Influx)
+	inst.name = "Influx"
+
+	inst.interval = initialInterval
+	inst.maxBufferSize = initialBufferSize
+	inst.timeout = initialTimeout
+	inst.log = log
+	inst.channel = channel
+
+	inst.apiVersion = defaultInfluxAPIVersion
+	inst.precision = defaultInfluxPrecision
+	inst.maxPacketSize = defaultInfluxMaxPacketSize
+	return inst
+}
+
+// ConfigKeys describes the keys of the Influx config
+func (i *Influx) ConfigKeys() []config.Key {
+	return append(i.BaseHandler.ConfigKeys(),
+		config.Key{Name: "endpoint", Type: config.TypeString, Required: true,
+			Description: "URL of the InfluxDB server, http://host:8086, or udp://host:8089 for its UDP listener"},
+		config.Key{Name: "apiVersion", Type: config.TypeInt, Default: defaultInfluxAPIVersion,
+			Description: "1 to write to /write with a database, 2 to write to /api/v2/write with an org and bucket"},
+		config.Key{Name: "precision", Type: config.TypeString, Default: defaultInfluxPrecision,
+			Description: "unit of the timestamps: ns, us, ms or s, over UDP it has to match the precision of the listener"},
+		config.Key{Name: "database", Type: config.TypeString, Description: "database to write to with the v1 API"},
+		config.Key{Name: "retentionPolicy", Type: config.TypeString,
+			Description: "retention policy to write to with the v1 API, the default one of the database if empty"},
+		config.Key{Name: "username", Type: config.TypeString, Description: "user to write as with the v1 API"},
+		config.Key{Name: "password", Type: config.TypeString, Description: "password of the user"},
+		config.Key{Name: "org", Type: config.TypeString, Description: "organization to write to with the v2 API"},
+		config.Key{Name: "bucket", Type: config.TypeString, Description: "bucket to write to with the v2 API"},
+		config.Key{Name: "token", Type: config.TypeString, Description: "API token of the v2 API"},
+		config.Key{Name: "maxPacketSize", Type: config.TypeInt, Default: defaultInfluxMaxPacketSize,
+			Description: "bytes of line protocol sent in one UDP packet at most"},
+	)
+}
+
+// Description of the Influx handler
+func (i *Influx) Description() string {
+	return "Writes the metrics to InfluxDB in the line protocol over HTTP or UDP, the dimensions are tags"
+}
+
+// Configure the Influx handler
+func (i *Influx) Configure(configMap map[string]interface{}) {
+	if endpoint, exists := configMap["endpoint"]; !exists {
+		i.log.Error("There was no endpoint specified for the Influx handler, there won't be any emissions")
+	} else if endpointURL, ok := endpoint.(string); ok {
+		i.endpoint = strings.TrimSuffix(endpointURL, "/")
+	} else {
+		i.log.Error("Invalid endpoint ", endpoint, " for the Influx handler, there won't be any emissions")
+	}
+
+	if apiVersion, exists := configMap["apiVersion"]; exists {
+		i.apiVersion = config.GetAsInt(apiVersion, defaultInfluxAPIVersion)
+	}
+
+	if precision, exists := configMap["precision"]; exists {
+		name, _ := precision.(string)
+		if _, known := influxPrecisions[name]; known {
+			i.precision = name
+		} else {
+			i.log.Error("Unknown precision ", precision, " for the Influx handler, using ", defaultInfluxPrecision)
+		}
+	}
+
+	if maxPacketSize, exists := configMap["maxPacketSize"]; exists {
+		i.maxPacketSize = config.GetAsInt(maxPacketSize, defaultInfluxMaxPacketSize)
+	}
+
+	i.database, _ = configMap["database"].(string)
+	i.retentionPolicy, _ = configMap["retentionPolicy"].(string)
+	i.username, _ = configMap["username"].(string)
+	i.password, _ = configMap["password"].(string)
+	i.org, _ = configMap["org"].(string)
+	i.bucket, _ = configMap["bucket"].(string)
+	i.token, _ = configMap["token"].(string)
+
+	if !i.udp() {
+		switch i.apiVersion {
+		case 1:
+			if i.database == "" {
+				i.log.Error("There was no database specified for the Influx handler, there won't be any emissions")
+			}
+		case 2:
+			if i.org == "" || i.bucket == "" {
+				i.log.Error("There was no org or bucket specified for the Influx handler, there won't be any emissions")
+			}
+		default:
+			i.log.Error("Unknown apiVersion ", i.apiVersion, " for the Influx handler, there won't be any emissions")
+		}
+	}
+
+	i.configureCommonParams(configMap)
+}
+
+// Endpoint returns the URL of the InfluxDB server
+func (i *Influx) Endpoint() string {
+	return i.endpoint
+}
+
+// Precision returns the unit of the timestamps
+func (i *Influx) Precision() string {
+	return i.precision
+}
+
+// Run runs the handler main loop
+func (i *Influx) Run() {
+	i.client = &http.Client{
+		Timeout: i.timeout,
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				Timeout:   i.timeout,
+				KeepAlive: time.Duration(i.KeepAliveInterval()) * time.Second,
+			}).Dial,
+			MaxIdleConnsPerHost: i.MaxIdleConnectionsPerHost(),
+		},
+	}
+	i.run(i.emitMetrics)
+}
+
+func (i *Influx) udp() bool {
+	return strings.HasPrefix(i.endpoint, "udp://")
+}
+
+// writeURL is where the HTTP API takes the line protocol
+func (i *Influx) writeURL() string {
+	query := url.Values{}
+	if i.apiVersion == 2 {
+		query.Set("org", i.org)
+		query.Set("bucket", i.bucket)
+		query.Set("precision", i.precision)
+		return i.endpoint + "/api/v2/write?" + query.Encode()
+	}
+
+	query.Set("db", i.database)
+	if i.retentionPolicy != "" {
+		query.Set("rp", i.retentionPolicy)
+	}
+	query.Set("precision", influxPrecisions[i.precision].v1)
+	return i.endpoint + "/write?" + query.Encode()
+}
+
+// convertToInflux formats a metric as a line of the line protocol:
+//
+//	name,tag1=value1,tag2=value2 value=1.5 1450000000
+//
+// The tags are sorted the way InfluxDB prefers them. Metrics without a
+// timestamp get the time InfluxDB receives them.
+func (i *Influx) convertToInflux(incomingMetric metric.Metric) (string, bool) {
+	if math.IsNaN(incomingMetric.Value) || math.IsInf(incomingMetric.Value, 0) {
+		return "", false
+	}
+
+	dimensions := incomingMetric.GetDimensions(i.DefaultDimensions())
+	keys := make([]string, 0, len(dimensions))
+	for key, value := range dimensions {
+		// InfluxDB rejects tags without a value
+		if key != "" && value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var line bytes.Buffer
+	line.WriteString(influxMeasurementEscaper.Replace(i.Prefix() + incomingMetric.Name))
+	for _, key := range keys {
+		line.WriteByte(',')
+		line.WriteString(influxTagEscaper.Replace(key))
+		line.WriteByte('=')
+		line.WriteString(influxTagEscaper.Replace(dimensions[key]))
+	}
+	line.WriteString(" value=")
+	line.WriteString(strconv.FormatFloat(incomingMetric.Value, 'g', -1, 64))
+	if !incomingMetric.Timestamp.IsZero() {
+		line.WriteByte(' ')
+		line.WriteString(strconv.FormatInt(incomingMetric.Timestamp.UnixNano()/int64(influxPrecisions[i.precision].unit), 10))
+	}
+	line.WriteByte('\n')
+	return line.String(), true
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+func (i *Influx) emitMetrics(metrics []metric.Metric) bool {
+	i.log.Info("Starting to emit ", len(metrics), " metrics")
+
+	if len(metrics) == 0 {
+		i.log.Warn("Skipping send because of an empty payload")
+		return false
+	}
+
+	lines := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		if line, ok := i.convertToInflux(m); ok {
+			lines = append(lines, line)
+		} else {
+			i.log.Debug("Skipping ", m.Name, ", InfluxDB does not take a value of ", m.Value)
+		}
+	}
+	if len(lines) == 0 {
+		return true
+	}
+
+	if i.udp() {
+		return i.emitUDP(lines)
+	}
+	return i.emitHTTP(lines)
+}
+
+func (i *Influx) emitHTTP(lines []string) bool {
+	apiURL := i.writeURL()
+	req, err := http.NewRequest("POST", apiURL, strings.NewReader(strings.Join(lines, "")))
+	if err != nil {
+		i.log.Error("Failed to create a request to endpoint ", i.endpoint)
+		return false
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.apiVersion == 2 {
+		req.Header.Set("Authorization", "Token "+i.token)
+	} else if i.username != "" {
+		req.SetBasicAuth(i.username, i.password)
+	}
+
+	client := i.client
+	if client == nil {
+		client = &http.Client{Timeout: i.timeout}
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		i.log.Error("Failed to complete POST ", err)
+		return false
+	}
+
+	defer rsp.Body.Close()
+	body, _ := ioutil.ReadAll(rsp.Body)
+	if rsp.StatusCode == http.StatusNoContent || rsp.StatusCode == http.StatusOK {
+		i.log.Info("Successfully sent ", len(lines), " datapoints to Influx")
+		return true
+	}
+
+	i.log.Error("Failed to post to Influx @", i.endpoint,
+		" status was ", rsp.Status,
+		" rsp body was ", string(body))
+	return false
+}
+
+// emitUDP packs as many lines into a packet as fit in maxPacketSize, a
+// line that is longer goes in a packet of its own
+func (i *Influx) emitUDP(lines []string) bool {
+	addr := strings.TrimPrefix(i.endpoint, "udp://")
+	conn, err := net.DialTimeout("udp", addr, i.timeout)
+	if err != nil {
+		i.log.Error("Failed to connect ", addr)
+		return false
+	}
+	defer conn.Close()
+
+	var packet bytes.Buffer
+	send := func() bool {
+		if packet.Len() == 0 {
+			return true
+		}
+		_, err := conn.Write(packet.Bytes())
+		packet.Reset()
+		if err != nil {
+			i.log.Error("Failed to send to Influx @", addr, ": ", err)
+			return false
+		}
+		return true
+	}
+
+	for _, line := range lines {
+		if packet.Len()+len(line) > i.maxPacketSize && !send() {
+			return false
+		}
+		packet.WriteString(line)
+	}
+	if !send() {
+		return false
+	}
+
+	i.log.Info("Successfully sent ", len(lines), " datapoints to Influx")
+	return true
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestInfluxHandler(interval, buffsize, timeoutsec int) *Influx {
+	testChannel := make(chan metric.Metric)
+	testLog := l.WithField("testing", "influx_handler")
+	timeout := time.Duration(timeoutsec) * time.Second
+
+	return newInflux(testChannel, interval, buffsize, timeout, testLog).(*Influx)
+}
+
+func TestInfluxConfigureEmptyConfig(t *testing.T) {
+	i := getTestInfluxHandler(12, 13, 14)
+	i.Configure(map[string]interface{}{})
+
+	assert.Equal(t, 12, i.Interval())
+	assert.Equal(t, "s", i.Precision())
+	assert.Equal(t, 1, i.apiVersion)
+}
+
+func TestInfluxConfigure(t *testing.T) {
+	config := map[string]interface{}{
+		"interval":        "10",
+		"max_buffer_size": "100",
+		"endpoint":        "http://influx.server:8086/",
+		"apiVersion":      "2",
+		"precision":       "ms",
+		"org":             "ops",
+		"bucket":          "fullerite",
+		"token":           "t0ken",
+	}
+
+	i := getTestInfluxHandler(12, 13, 14)
+	i.Configure(config)
+
+	assert.Equal(t, 10, i.Interval())
+	assert.Equal(t, 100, i.MaxBufferSize())
+	assert.Equal(t, "http://influx.server:8086", i.Endpoint())
+	assert.Equal(t, 2, i.apiVersion)
+	assert.Equal(t, "ms", i.Precision())
+
+	i.Configure(map[string]interface{}{"precision": "minutes"})
+	assert.Equal(t, "ms", i.Precision(), "should keep the precision it had")
+}
+
+func TestInfluxConfigureWrongTypes(t *testing.T) {
+	i := getTestInfluxHandler(12, 13, 14)
+	assert.NotPanics(t, func() {
+		i.Configure(map[string]interface{}{"endpoint": 8086, "precision": 1})
+	})
+	assert.Equal(t, "", i.Endpoint())
+	assert.Equal(t, "s", i.Precision())
+}
+
+func TestInfluxWriteURL(t *testing.T) {
+	i := getTestInfluxHandler(12, 13, 14)
+	i.Configure(map[string]interface{}{
+		"endpoint":        "http://influx:8086",
+		"database":        "metrics",
+		"retentionPolicy": "week",
+		"precision":       "ns",
+	})
+	assert.Equal(t, "http://influx:8086/write?db=metrics&precision=n&rp=week", i.writeURL())
+
+	i.Configure(map[string]interface{}{
+		"apiVersion": 2,
+		"org":        "ops",
+		"bucket":     "fullerite",
+		"precision":  "us",
+	})
+	assert.Equal(t, "http://influx:8086/api/v2/write?bucket=fullerite&org=ops&precision=us", i.writeURL())
+}
+
+func TestConvertToInflux(t *testing.T) {
+	i := getTestInfluxHandler(12, 13, 14)
+	i.SetDefaultDimensions(map[string]string{"host": "web1"})
+
+	m := metric.WithValue("cpu user", 1.5)
+	m.AddDimension("collector", "CPU")
+	m.AddDimension("core", "0,1")
+	m.AddDimension("mode", "a=b")
+	m.AddDimension("empty", "")
+	m.Timestamp = time.Unix(1450000000, 250000000)
+
+	line, ok := i.convertToInflux(m)
+	assert.True(t, ok)
+	assert.Equal(t, `cpu\ user,collector=CPU,core=0\,1,host=web1,mode=a\=b value=1.5 1450000000`+"\n", line)
+
+	i.precision = "ms"
+	line, _ = i.convertToInflux(m)
+	assert.True(t, strings.HasSuffix(line, " 1450000000250\n"))
+
+	m.Timestamp = time.Time{}
+	line, _ = i.convertToInflux(m)
+	assert.True(t, strings.HasSuffix(line, " value=1.5\n"), "should leave the timestamp to InfluxDB")
+
+	_, ok = i.convertToInflux(metric.WithValue("nan", math.NaN()))
+	assert.False(t, ok, "InfluxDB does not take NaN")
+}
+
+func TestInfluxRunV1(t *testing.T) {
+	wait := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+		wait <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	i := getTestInfluxHandler(12, 13, 14)
+	i.Configure(map[string]interface{}{
+		"interval":        "1",
+		"timeout":         "1",
+		"max_buffer_size": "1",
+		"endpoint":        ts.URL,
+		"database":        "metrics",
+		"username":        "fullerite",
+		"password":        "s3cr3t",
+	})
+	go i.Run()
+
+	m := metric.WithValue("Test", 2)
+	m.Timestamp = time.Unix(1450000000, 0)
+	i.Channel() <- m
+
+	select {
+	case r := <-wait:
+		assert.Equal(t, "/write", r.URL.Path)
+		assert.Equal(t, "metrics", r.URL.Query().Get("db"))
+		assert.Equal(t, "s", r.URL.Query().Get("precision"))
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "fullerite", user)
+		assert.Equal(t, "s3cr3t", password)
+		assert.Equal(t, "Test value=2 1450000000\n", <-bodies)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Failed to post and handle after 2 seconds")
+	}
+}
+
+func TestInfluxEmitV2(t *testing.T) {
+	status := http.StatusNoContent
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	i := getTestInfluxHandler(12, 13, 14)
+	i.Configure(map[string]interface{}{
+		"endpoint":   ts.URL,
+		"apiVersion": 2,
+		"org":        "ops",
+		"bucket":     "fullerite",
+		"token":      "t0ken",
+	})
+
+	assert.True(t, i.emitMetrics([]metric.Metric{metric.New("Test")}))
+	assert.Equal(t, "/api/v2/write", req.URL.Path)
+	assert.Equal(t, "fullerite", req.URL.Query().Get("bucket"))
+	assert.Equal(t, "Token t0ken", req.Header.Get("Authorization"))
+
+	status = http.StatusBadRequest
+	assert.False(t, i.emitMetrics([]metric.Metric{metric.New("Test")}))
+}
+
+func TestInfluxEmitUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	i := getTestInfluxHandler(12, 13, 14)
+	i.Configure(map[string]interface{}{
+		"endpoint":      "udp://" + conn.LocalAddr().String(),
+		"maxPacketSize": 60,
+	})
+
+	metrics := []metric.Metric{}
+	for _, name := range []string{"first", "second", "third"} {
+		m := metric.New(name)
+		m.Timestamp = time.Unix(1450000000, 0)
+		metrics = append(metrics, m)
+	}
+	assert.True(t, i.emitMetrics(metrics))
+
+	// the lines are about 25 bytes, two fit in a packet
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1500)
+	var packets []string
+	for len(packets) < 2 {
+		n, _, err := conn.ReadFrom(buf)
+		require.Nil(t, err)
+		packets = append(packets, string(buf[:n]))
+	}
+	assert.Equal(t, "first value=0 1450000000\nsecond value=0 1450000000\n", packets[0])
+	assert.Equal(t, "third value=0 1450000000\n", packets[1])
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
 	"fullerite/util"
 
@@ -59,6 +60,19 @@ func newKairos(
 	return inst
 }
 
+// ConfigKeys describes the keys of the Kairos config
+func (k *Kairos) ConfigKeys() []config.Key {
+	return append(k.BaseHandler.ConfigKeys(),
+		config.Key{Name: "server", Type: config.TypeString, Required: true, Description: "host of the KairosDB server"},
+		config.Key{Name: "port", Type: config.TypeInt, Required: true, Description: "HTTP port of the KairosDB server"},
+	)
+}
+
+// Description of the Kairos handler
+func (k *Kairos) Description() string {
+	return "Posts the metrics to the KairosDB REST API, the dimensions are tags"
+}
+
 // Configure the Kairos handler
 func (k *Kairos) Configure(configMap map[string]interface{}) {
 	if server, exists := configMap["server"]; exists {
@@ -76,12 +90,12 @@ func (k *Kairos) Configure(configMap map[string]interface{}) {
 }
 
 // Server returns the Kairos server's hostname or IP address
-func (k Kairos) Server() string {
+func (k *Kairos) Server() string {
 	return k.server
 }
 
 // Port returns the Kairos server's port number
-func (k Kairos) Port() string {
+func (k *Kairos) Port() string {
 	return k.port
 }
 
@@ -90,12 +104,12 @@ func (k *Kairos) Run() {
 	k.run(k.emitMetrics)
 }
 
-func (k Kairos) convertToKairos(incomingMetric metric.Metric) (datapoint KairosMetric) {
+func (k *Kairos) convertToKairos(incomingMetric metric.Metric) (datapoint KairosMetric) {
 	km := new(KairosMetric)
 	km.Name = k.Prefix() + kairosSanitize(incomingMetric.Name)
 	km.Value = incomingMetric.Value
 	km.MetricType = "double"
-	km.Timestamp = time.Now().Unix() * 1000 // Kairos require timestamps to be milliseconds
+	km.Timestamp = incomingMetric.Timestamp.UnixNano() / int64(time.Millisecond) // Kairos require timestamps to be milliseconds
 	km.Tags = make(map[string]string)
 	for key, value := range incomingMetric.GetDimensions(k.DefaultDimensions()) {
 		km.Tags[kairosSanitize(key)] = kairosSanitize(value)
@@ -164,11 +178,11 @@ func (k *Kairos) emitMetrics(metrics []metric.Metric) bool {
 	return false
 }
 
-func (k Kairos) dialTimeout(network, addr string) (net.Conn, error) {
+func (k *Kairos) dialTimeout(network, addr string) (net.Conn, error) {
 	return net.DialTimeout(network, addr, k.timeout)
 }
 
-func (k Kairos) parseServerError(errMsg string, metrics []KairosMetric) string {
+func (k *Kairos) parseServerError(errMsg string, metrics []KairosMetric) string {
 	re, err := regexp.Compile(`metric\[([0-9]+)\]`)
 	if err != nil {
 		return ""
@@ -219,3 +219,13 @@ func TestKairosDimensionsOverwriting(t *testing.T) {
 
 	assert.Equal(t, len(datapoint.Tags), 1, "the two metrics should be the same")
 }
+
+func TestKairosUsesMetricTimestamp(t *testing.T) {
+	k := getTestKairosHandler(12, 13, 14)
+
+	m := metric.New("Test")
+	m.Timestamp = time.Unix(1450000000, 250000000)
+	km := k.convertToKairos(m)
+
+	assert.Equal(t, int64(1450000000250), km.Timestamp)
+}
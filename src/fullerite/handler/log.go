@@ -38,6 +38,11 @@ func newLog(
 	return inst
 }
 
+// Description of the Log handler
+func (h *Log) Description() string {
+	return "Logs the metrics as JSON, for debugging"
+}
+
 // Configure accepts the different configuration options for the Log handler
 func (h *Log) Configure(configMap map[string]interface{}) {
 	h.configureCommonParams(configMap)
@@ -48,7 +53,7 @@ func (h *Log) Run() {
 	h.run(h.emitMetrics)
 }
 
-func (h Log) convertToLog(incomingMetric metric.Metric) (string, error) {
+func (h *Log) convertToLog(incomingMetric metric.Metric) (string, error) {
 	jsonOut, err := json.Marshal(incomingMetric)
 	return string(jsonOut), err
 }
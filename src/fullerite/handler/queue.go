@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"sync/atomic"
+)
+
+// The overflow policies decide what happens to a metric when the queue
+// between a collector and a handler is full.
+const (
+	// OverflowBlock waits until the handler has room again
+	OverflowBlock = "block"
+	// OverflowDropNewest discards the metric that could not be queued
+	OverflowDropNewest = "drop-newest"
+	// OverflowDropOldest discards the oldest queued metric to make room
+	OverflowDropOldest = "drop-oldest"
+
+	// DefaultQueueCapacity is the size of each collector queue of a handler
+	DefaultQueueCapacity = 1
+	// DefaultOverflowPolicy keeps the historic blocking behaviour
+	DefaultOverflowPolicy = OverflowBlock
+
+	// InternalCollector is the queue of the metrics fullerite reports
+	// about itself
+	InternalCollector = "fullerite"
+)
+
+func isValidOverflowPolicy(policy string) bool {
+	switch policy {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+		return true
+	}
+	return false
+}
+
+// QueueCapacity : the capacity of each collector queue
+func (base *BaseHandler) QueueCapacity() int {
+	if base.queueCapacity <= 0 {
+		return DefaultQueueCapacity
+	}
+	return base.queueCapacity
+}
+
+// OverflowPolicy : what to do with metrics when a collector queue is full
+func (base *BaseHandler) OverflowPolicy() string {
+	if base.overflowPolicy == "" {
+		return DefaultOverflowPolicy
+	}
+	return base.overflowPolicy
+}
+
+// Enqueue hands a metric from the named collector to the handler, honouring
+// the overflow policy. It returns false if a metric had to be dropped, which
+// for drop-oldest is the metric that was queued longest. Metrics of a
+// listened collector without a queue, e.g. one that was just retired, are
+// counted as dropped.
+func (base *BaseHandler) Enqueue(collectorName string, m metric.Metric) bool {
+	base.lock.Lock()
+	channel, exists := base.collectorChannels[collectorName]
+	var quit chan struct{}
+	if base.running != nil {
+		quit = base.running.controls[collectorName].quit
+	}
+	base.lock.Unlock()
+	if !exists {
+		if !base.listensTo(collectorName) {
+			return true
+		}
+		atomic.AddUint64(&base.metricsDropped, 1)
+		return false
+	}
+	select {
+	case <-quit:
+		// nothing reads the queue anymore
+		atomic.AddUint64(&base.metricsDropped, 1)
+		return false
+	default:
+	}
+
+	switch base.OverflowPolicy() {
+	case OverflowDropOldest:
+		dropped := false
+		// an unbuffered queue has nothing to drop, it behaves like drop-newest
+		for cap(channel) > 0 {
+			select {
+			case channel <- m:
+				return !dropped
+			default:
+			}
+			select {
+			case <-channel:
+				atomic.AddUint64(&base.metricsOverflowed, 1)
+				dropped = true
+			default:
+			}
+		}
+		fallthrough
+	case OverflowDropNewest:
+		select {
+		case channel <- m:
+			return true
+		default:
+			atomic.AddUint64(&base.metricsOverflowed, 1)
+			return false
+		}
+	}
+
+	// a nil quit, before the handler runs, never fires
+	select {
+	case channel <- m:
+		return true
+	case <-quit:
+		atomic.AddUint64(&base.metricsDropped, 1)
+		return false
+	}
+}
+
+// Write hands a metric straight to the handler, bypassing the collector
+// queues. Once the handler is stopped the metric is counted as dropped.
+func (base *BaseHandler) Write(m metric.Metric) bool {
+	base.lock.Lock()
+	var quit chan struct{}
+	if base.running != nil {
+		quit = base.running.controls[""].quit
+	}
+	base.lock.Unlock()
+
+	select {
+	case base.channel <- m:
+		return true
+	case <-quit:
+		atomic.AddUint64(&base.metricsDropped, 1)
+		return false
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"testing"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestQueueHandler(configMap map[string]interface{}) *BaseHandler {
+	base := new(BaseHandler)
+	base.log = l.WithField("testing", "basehandler_queue")
+	base.configureCommonParams(configMap)
+	base.InitListeners(config.Config{Collectors: []string{"coll"}})
+	return base
+}
+
+func TestQueueDefaults(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{})
+
+	assert.Equal(t, DefaultQueueCapacity, base.QueueCapacity())
+	assert.Equal(t, DefaultOverflowPolicy, base.OverflowPolicy())
+	assert.Equal(t, DefaultQueueCapacity, cap(base.CollectorChannels()["coll"]))
+}
+
+func TestQueueConfigure(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{
+		"queueCapacity":  "5",
+		"overflowPolicy": OverflowDropNewest,
+	})
+
+	assert.Equal(t, 5, base.QueueCapacity())
+	assert.Equal(t, OverflowDropNewest, base.OverflowPolicy())
+	assert.Equal(t, 5, cap(base.CollectorChannels()["coll"]))
+}
+
+func TestQueueConfigureUnknownPolicy(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{
+		"overflowPolicy": "drop-everything",
+	})
+
+	assert.Equal(t, DefaultOverflowPolicy, base.OverflowPolicy())
+}
+
+func TestEnqueueUnknownCollector(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{})
+
+	assert.False(t, base.Enqueue("other", metric.New("test")))
+	assert.Equal(t, 1.0, base.InternalMetrics().Counters["metricsDropped"])
+}
+
+func TestEnqueueBlacklistedCollector(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{
+		"collectorBlackList": []interface{}{"other"},
+	})
+
+	assert.True(t, base.Enqueue("other", metric.New("test")))
+	assert.Equal(t, 0.0, base.InternalMetrics().Counters["metricsDropped"])
+}
+
+func TestEnqueueAfterStop(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{})
+	base.interval = 10
+	base.run(func([]metric.Metric) bool { return true })
+	base.Stop()
+
+	assert.False(t, base.Enqueue("coll", metric.New("late")))
+	assert.Equal(t, 1.0, base.InternalMetrics().Counters["metricsDropped"])
+}
+
+func TestStopWhileDroppingOldest(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{
+		"queueCapacity":  1,
+		"overflowPolicy": OverflowDropOldest,
+	})
+	base.interval = 10
+	base.run(func([]metric.Metric) bool { return true })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			base.Enqueue("coll", metric.New("test"))
+		}
+	}()
+	base.Stop()
+	<-done
+}
+
+func TestEnqueueDropNewest(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{
+		"queueCapacity":  2,
+		"overflowPolicy": OverflowDropNewest,
+	})
+
+	assert.True(t, base.Enqueue("coll", metric.New("first")))
+	assert.True(t, base.Enqueue("coll", metric.New("second")))
+	assert.False(t, base.Enqueue("coll", metric.New("third")))
+
+	channel := base.CollectorChannels()["coll"]
+	assert.Equal(t, "first", (<-channel).Name)
+	assert.Equal(t, "second", (<-channel).Name)
+	assert.Equal(t, 1.0, base.InternalMetrics().Counters["metricsOverflowed"])
+}
+
+func TestEnqueueDropOldest(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{
+		"queueCapacity":  2,
+		"overflowPolicy": OverflowDropOldest,
+	})
+
+	assert.True(t, base.Enqueue("coll", metric.New("first")))
+	assert.True(t, base.Enqueue("coll", metric.New("second")))
+	assert.False(t, base.Enqueue("coll", metric.New("third")))
+
+	channel := base.CollectorChannels()["coll"]
+	assert.Equal(t, "second", (<-channel).Name)
+	assert.Equal(t, "third", (<-channel).Name)
+	assert.Equal(t, 1.0, base.InternalMetrics().Counters["metricsOverflowed"])
+}
+
+func TestEnqueueDropOldestUnbuffered(t *testing.T) {
+	base := getTestQueueHandler(map[string]interface{}{
+		"overflowPolicy": OverflowDropOldest,
+	})
+	base.SetCollectorChannels(map[string]chan metric.Metric{"coll": make(chan metric.Metric)})
+
+	assert.False(t, base.Enqueue("coll", metric.New("first")))
+}
@@ -69,6 +69,20 @@ func newScribe(
 	return inst
 }
 
+// ConfigKeys describes the keys of the Scribe config
+func (s *Scribe) ConfigKeys() []config.Key {
+	return append(s.BaseHandler.ConfigKeys(),
+		config.Key{Name: "endpoint", Type: config.TypeString, Default: defaultScribeEndpoint, Description: "host of the Scribe server"},
+		config.Key{Name: "port", Type: config.TypeInt, Default: defaultScribePort, Description: "port of the Scribe server"},
+		config.Key{Name: "streamName", Type: config.TypeString, Default: defaultScribeStreamName, Description: "category to log the metrics to"},
+	)
+}
+
+// Description of the Scribe handler
+func (s *Scribe) Description() string {
+	return "Logs the metrics as JSON to a Scribe category"
+}
+
 // Configure accepts the different configuration options for the Scribe handler
 func (s *Scribe) Configure(configMap map[string]interface{}) {
 	if endpoint, exists := configMap["endpoint"]; exists {
@@ -139,12 +153,12 @@ func (s *Scribe) emitMetrics(metrics []metric.Metric) bool {
 	return true
 }
 
-func (s Scribe) createScribeMetric(m metric.Metric) scribeMetric {
+func (s *Scribe) createScribeMetric(m metric.Metric) scribeMetric {
 	return scribeMetric{
 		Name:       m.Name,
 		Value:      m.Value,
 		MetricType: m.MetricType,
-		Timestamp:  time.Now().Unix(),
+		Timestamp:  m.Timestamp.Unix(),
 		Dimensions: m.GetDimensions(s.DefaultDimensions()),
 	}
 
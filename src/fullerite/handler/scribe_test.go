@@ -92,12 +92,14 @@ func TestScribeEmitMetrics(t *testing.T) {
 			MetricType: metric.Gauge,
 			Value:      1,
 			Dimensions: map[string]string{"dim1": "val1"},
+			Timestamp:  time.Now(),
 		},
 		metric.Metric{
 			Name:       "test2",
 			Value:      2,
 			MetricType: metric.Counter,
 			Dimensions: map[string]string{"dim2": "val2"},
+			Timestamp:  time.Now(),
 		},
 	}
 
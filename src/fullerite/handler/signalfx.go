@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
 	"fullerite/util"
 
@@ -48,6 +49,19 @@ func newSignalFx(
 	return inst
 }
 
+// ConfigKeys describes the keys of the SignalFx config
+func (s *SignalFx) ConfigKeys() []config.Key {
+	return append(s.BaseHandler.ConfigKeys(),
+		config.Key{Name: "authToken", Type: config.TypeString, Required: true, Description: "SignalFx access token"},
+		config.Key{Name: "endpoint", Type: config.TypeString, Required: true, Description: "URL of the SignalFx datapoint API"},
+	)
+}
+
+// Description of the SignalFx handler
+func (s *SignalFx) Description() string {
+	return "Posts the metrics to SignalFx as protobuf"
+}
+
 // Configure accepts the different configuration options for the signalfx handler
 func (s *SignalFx) Configure(configMap map[string]interface{}) {
 	if authToken, exists := configMap["authToken"]; exists {
@@ -65,7 +79,7 @@ func (s *SignalFx) Configure(configMap map[string]interface{}) {
 }
 
 // Endpoint returns SignalFx' API endpoint
-func (s SignalFx) Endpoint() string {
+func (s *SignalFx) Endpoint() string {
 	return s.endpoint
 }
 
@@ -80,14 +94,14 @@ func (s *SignalFx) Run() {
 	s.run(s.emitMetrics)
 }
 
-func (s SignalFx) convertToProto(incomingMetric metric.Metric) *DataPoint {
+func (s *SignalFx) convertToProto(incomingMetric metric.Metric) *DataPoint {
 	// Create a new values for the Datapoint that requires pointers.
 	outname := s.Prefix() + signalFxValueSanitize(incomingMetric.Name)
 	value := incomingMetric.Value
 
-	now := time.Now().UnixNano() / int64(time.Millisecond)
+	timestamp := incomingMetric.Timestamp.UnixNano() / int64(time.Millisecond)
 	datapoint := new(DataPoint)
-	datapoint.Timestamp = &now
+	datapoint.Timestamp = &timestamp
 	datapoint.Metric = &outname
 	datapoint.Value = &Datum{
 		DoubleValue: &value,
@@ -121,7 +135,7 @@ func (s SignalFx) convertToProto(incomingMetric metric.Metric) *DataPoint {
 	return datapoint
 }
 
-func (s SignalFx) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
+func (s *SignalFx) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
 	dimSanitized := make(map[string]string)
 	dimensions := incomingMetric.GetDimensions(s.DefaultDimensions())
 	for key, value := range dimensions {
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for the on-disk spool
+const (
+	DefaultSpoolMaxSize = 100 * 1024 * 1024 // bytes
+	DefaultSpoolMaxAge  = 24 * 60 * 60      // seconds
+
+	spoolFileSuffix = ".spool"
+
+	// spoolReplayBatches is how many batches are replayed after each
+	// successful emission
+	spoolReplayBatches = 10
+)
+
+// spool keeps batches of metrics that could not be emitted on disk so they
+// can be replayed once the backend is reachable again. Every batch is one
+// file in the spool directory, named after the time it was written so that
+// a lexical sort of the directory gives the order the batches failed in.
+type spool struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	lock      sync.Mutex
+	seq       uint64
+	replaying int32
+	// corruptBytes counts the bytes of the entries that could not be read
+	// back and were removed, their metrics are lost
+	corruptBytes uint64
+}
+
+type spoolEntry struct {
+	path    string
+	size    int64
+	written time.Time
+}
+
+// spoolStats is a snapshot of the spool used for the internal metrics
+type spoolStats struct {
+	depth        int
+	bytes        int64
+	oldestAge    time.Duration
+	corruptBytes uint64
+}
+
+func newSpool(dir string, maxSize int64, maxAge time.Duration) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &spool{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+	}, nil
+}
+
+// write stores a batch of metrics on disk. It returns the number of metrics
+// that had to be evicted from the spool to stay within its limits. A batch
+// that is bigger than the whole spool is not written.
+func (s *spool) write(metrics []metric.Metric) (int, error) {
+	payload, err := json.Marshal(metrics)
+	if err != nil {
+		return 0, err
+	}
+	if s.maxSize > 0 && int64(len(payload)) > s.maxSize {
+		return 0, fmt.Errorf("the batch of %d bytes is bigger than the spool of %d bytes", len(payload), s.maxSize)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), s.seq, spoolFileSuffix)
+	tmp := filepath.Join(s.dir, "."+name)
+	if err := ioutil.WriteFile(tmp, payload, 0644); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return s.enforceLimits()
+}
+
+// next returns the oldest batch in the spool, or nil if the spool is empty.
+func (s *spool) next() (*spoolEntry, []metric.Metric, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries, err := s.entries()
+	if err != nil || len(entries) == 0 {
+		return nil, nil, err
+	}
+
+	entry := entries[0]
+	contents, err := ioutil.ReadFile(entry.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var metrics []metric.Metric
+	if err := json.Unmarshal(contents, &metrics); err != nil {
+		// a corrupt entry would block the spool forever, get rid of it
+		os.Remove(entry.path)
+		atomic.AddUint64(&s.corruptBytes, uint64(entry.size))
+		return nil, nil, fmt.Errorf("removed the corrupt entry %s of %d bytes: %v", entry.path, entry.size, err)
+	}
+	return &entry, metrics, nil
+}
+
+// remove deletes a batch once it has been replayed successfully.
+func (s *spool) remove(entry *spoolEntry) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	os.Remove(entry.path)
+}
+
+// expire drops batches that are older than the maximum age. It returns the
+// number of metrics dropped.
+func (s *spool) expire() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	dropped, _ := s.enforceLimits()
+	return dropped
+}
+
+func (s *spool) stats() spoolStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := spoolStats{corruptBytes: atomic.LoadUint64(&s.corruptBytes)}
+	entries, err := s.entries()
+	if err != nil {
+		return stats
+	}
+
+	stats.depth = len(entries)
+	for _, entry := range entries {
+		stats.bytes += entry.size
+	}
+	if len(entries) > 0 {
+		stats.oldestAge = time.Since(entries[0].written)
+	}
+	return stats
+}
+
+// startReplay makes sure only a single replay runs at any given time
+func (s *spool) startReplay() bool {
+	return atomic.CompareAndSwapInt32(&s.replaying, 0, 1)
+}
+
+func (s *spool) endReplay() {
+	atomic.StoreInt32(&s.replaying, 0)
+}
+
+// enforceLimits removes the oldest batches until the spool is within its
+// size and age limits. The caller must hold the lock.
+func (s *spool) enforceLimits() (int, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+
+	dropped := 0
+	minTime := time.Now().Add(-s.maxAge)
+	for _, entry := range entries {
+		tooOld := s.maxAge > 0 && entry.written.Before(minTime)
+		tooBig := s.maxSize > 0 && total > s.maxSize
+		if !tooOld && !tooBig {
+			break
+		}
+		dropped += countSpooledMetrics(entry.path)
+		os.Remove(entry.path)
+		total -= entry.size
+	}
+	return dropped, nil
+}
+
+// entries lists the batches in the spool, oldest first. The caller must hold
+// the lock.
+func (s *spool) entries() ([]spoolEntry, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []spoolEntry{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, spoolFileSuffix) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.Split(name, "-")[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, spoolEntry{
+			path:    filepath.Join(s.dir, name),
+			size:    f.Size(),
+			written: time.Unix(0, nanos),
+		})
+	}
+	sort.Sort(byWritten(entries))
+	return entries, nil
+}
+
+func countSpooledMetrics(path string) int {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	var metrics []metric.Metric
+	if err := json.Unmarshal(contents, &metrics); err != nil {
+		return 0
+	}
+	return len(metrics)
+}
+
+type byWritten []spoolEntry
+
+func (b byWritten) Len() int           { return len(b) }
+func (b byWritten) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byWritten) Less(i, j int) bool { return b[i].path < b[j].path }
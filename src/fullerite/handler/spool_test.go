@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestSpool(t *testing.T, maxSize int64, maxAge time.Duration) (*spool, func()) {
+	dir, err := ioutil.TempDir("", "fullerite_spool")
+	require.Nil(t, err)
+
+	s, err := newSpool(dir, maxSize, maxAge)
+	require.Nil(t, err)
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestSpoolReplaysInOrder(t *testing.T) {
+	s, cleanup := getTestSpool(t, 0, 0)
+	defer cleanup()
+
+	first := metric.New("first")
+	first.Timestamp = time.Unix(1450000000, 0)
+	_, err := s.write([]metric.Metric{first})
+	assert.Nil(t, err)
+	_, err = s.write([]metric.Metric{metric.New("second"), metric.New("third")})
+	assert.Nil(t, err)
+
+	entry, metrics, err := s.next()
+	require.Nil(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, "first", metrics[0].Name)
+	assert.Equal(t, int64(1450000000), metrics[0].Timestamp.Unix())
+	s.remove(entry)
+
+	entry, metrics, err = s.next()
+	require.Nil(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, 2, len(metrics))
+	assert.Equal(t, "second", metrics[0].Name)
+	s.remove(entry)
+
+	entry, _, err = s.next()
+	assert.Nil(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestSpoolEvictsOldestWhenFull(t *testing.T) {
+	payload, err := json.Marshal([]metric.Metric{metric.New("first")})
+	require.Nil(t, err)
+	s, cleanup := getTestSpool(t, int64(len(payload))+10, 0)
+	defer cleanup()
+
+	evicted, err := s.write([]metric.Metric{metric.New("first")})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, evicted)
+
+	evicted, err = s.write([]metric.Metric{metric.New("second")})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, evicted)
+	_, metrics, err := s.next()
+	require.Nil(t, err)
+	assert.Equal(t, "second", metrics[0].Name, "should evict the oldest batch")
+	assert.Equal(t, 1, s.stats().depth)
+}
+
+func TestSpoolRejectsBatchBiggerThanTheSpool(t *testing.T) {
+	s, cleanup := getTestSpool(t, 1, 0)
+	defer cleanup()
+
+	evicted, err := s.write([]metric.Metric{metric.New("first"), metric.New("second")})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, evicted)
+	assert.Equal(t, 0, s.stats().depth)
+}
+
+func TestSpoolExpiresOldEntries(t *testing.T) {
+	s, cleanup := getTestSpool(t, 0, time.Hour)
+	defer cleanup()
+
+	_, err := s.write([]metric.Metric{metric.New("first")})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, s.expire())
+
+	s.maxAge = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, 1, s.expire())
+	assert.Equal(t, 0, s.stats().depth)
+}
+
+func TestSpoolStats(t *testing.T) {
+	s, cleanup := getTestSpool(t, 0, 0)
+	defer cleanup()
+
+	assert.Equal(t, spoolStats{}, s.stats())
+
+	s.write([]metric.Metric{metric.New("first")})
+	s.write([]metric.Metric{metric.New("second")})
+
+	stats := s.stats()
+	assert.Equal(t, 2, stats.depth)
+	assert.True(t, stats.bytes > 0)
+	assert.True(t, stats.oldestAge >= 0)
+}
+
+func TestSpoolCountsCorruptEntries(t *testing.T) {
+	s, cleanup := getTestSpool(t, 0, 0)
+	defer cleanup()
+
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), 0, spoolFileSuffix)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(s.dir, name), []byte("{not json"), 0644))
+
+	entry, _, err := s.next()
+	assert.NotNil(t, err)
+	assert.Nil(t, entry)
+	assert.Equal(t, 0, s.stats().depth, "should remove the corrupt entry")
+	assert.Equal(t, uint64(9), s.stats().corruptBytes)
+}
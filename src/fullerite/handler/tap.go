@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// TappedMetric is a metric a handler emitted, as it was handed to the
+// emission: after the processors and the aggregation, before the prefix and
+// the default dimensions of the handler.
+type TappedMetric struct {
+	Handler string        `json:"handler"`
+	Metric  metric.Metric `json:"metric"`
+}
+
+// TapFilter selects the tapped metrics, empty fields match everything
+type TapFilter struct {
+	Handler    string
+	Collector  string
+	Name       *regexp.Regexp
+	Dimensions map[string]string
+}
+
+func (f TapFilter) matches(handler string, m metric.Metric) bool {
+	if f.Handler != "" && f.Handler != handler {
+		return false
+	}
+	if f.Collector != "" {
+		if collector, _ := m.GetDimensionValue("collector"); collector != f.Collector {
+			return false
+		}
+	}
+	if f.Name != nil && !f.Name.MatchString(m.Name) {
+		return false
+	}
+	for key, value := range f.Dimensions {
+		if actual, exists := m.GetDimensionValue(key); !exists || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// TapSubscription receives the tapped metrics that match its filter. A
+// subscriber that falls behind loses metrics rather than holding up the
+// handlers.
+type TapSubscription struct {
+	filter  TapFilter
+	metrics chan TappedMetric
+	dropped uint64
+}
+
+// Metrics is the channel the tapped metrics arrive on
+func (s *TapSubscription) Metrics() <-chan TappedMetric {
+	return s.metrics
+}
+
+// Dropped returns how many metrics the subscriber was too slow for
+func (s *TapSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// taps holds the subscriptions, the handlers only look at them while the
+// count is not zero so that tapping costs nothing when nobody listens
+var taps = struct {
+	sync.RWMutex
+	count         int32
+	subscriptions map[*TapSubscription]bool
+}{subscriptions: make(map[*TapSubscription]bool)}
+
+// Tap subscribes to the metrics all handlers emit, the subscription has to
+// be closed with Untap
+func Tap(filter TapFilter, buffer int) *TapSubscription {
+	s := &TapSubscription{filter: filter, metrics: make(chan TappedMetric, buffer)}
+	taps.Lock()
+	defer taps.Unlock()
+	taps.subscriptions[s] = true
+	atomic.StoreInt32(&taps.count, int32(len(taps.subscriptions)))
+	return s
+}
+
+// Untap ends a subscription
+func Untap(s *TapSubscription) {
+	taps.Lock()
+	defer taps.Unlock()
+	delete(taps.subscriptions, s)
+	atomic.StoreInt32(&taps.count, int32(len(taps.subscriptions)))
+}
+
+func tapping() bool {
+	return atomic.LoadInt32(&taps.count) > 0
+}
+
+// tapMetrics hands the metrics of an emission to the matching subscriptions
+func tapMetrics(handler string, metrics []metric.Metric) {
+	taps.RLock()
+	defer taps.RUnlock()
+	for s := range taps.subscriptions {
+		for _, m := range metrics {
+			if !s.filter.matches(handler, m) {
+				continue
+			}
+			// the subscriber gets a copy of the dimensions, the
+			// emission goes on with the metric
+			tapped := TappedMetric{Handler: handler, Metric: m}
+			tapped.Metric.Dimensions = m.GetDimensions(nil)
+			select {
+			case s.metrics <- tapped:
+			default:
+				atomic.AddUint64(&s.dropped, 1)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTapFilter(t *testing.T) {
+	m := metric.New("cpu.user")
+	m.AddDimensions(map[string]string{"collector": "CPU", "core": "0"})
+
+	filters := map[bool][]TapFilter{
+		true: {
+			{},
+			{Handler: "Graphite", Collector: "CPU"},
+			{Name: regexp.MustCompile(`^cpu\.`)},
+			{Dimensions: map[string]string{"core": "0"}},
+		},
+		false: {
+			{Handler: "Kairos"},
+			{Collector: "ProcStatus"},
+			{Name: regexp.MustCompile(`^mem`)},
+			{Dimensions: map[string]string{"core": "1"}},
+			{Dimensions: map[string]string{"host": "a"}},
+		},
+	}
+	for expected, list := range filters {
+		for _, filter := range list {
+			assert.Equal(t, expected, filter.matches("Graphite", m), "%+v", filter)
+		}
+	}
+}
+
+func TestTap(t *testing.T) {
+	assert.False(t, tapping(), "should not tap without subscriptions")
+
+	s := Tap(TapFilter{Name: regexp.MustCompile("^kept")}, 1)
+	assert.True(t, tapping())
+
+	base := BaseHandler{name: "Graphite"}
+	base.log = defaultLog
+	emitted := make(chan emissionTiming, 1)
+	kept := metric.New("kept")
+	kept.AddDimension("collector", "Test")
+	base.startEmission([]metric.Metric{metric.New("other"), kept, metric.New("kept.too")},
+		func([]metric.Metric) bool { return true }, emitted)
+
+	tapped := <-s.Metrics()
+	assert.Equal(t, "Graphite", tapped.Handler)
+	assert.Equal(t, "kept", tapped.Metric.Name)
+	assert.Equal(t, uint64(1), s.Dropped(), "should drop what does not fit the buffer")
+
+	tapped.Metric.Dimensions["collector"] = "changed"
+	assert.Equal(t, "Test", kept.Dimensions["collector"], "should tap copies of the dimensions")
+
+	Untap(s)
+	assert.False(t, tapping())
+	tapMetrics("Graphite", []metric.Metric{kept})
+	require.Len(t, s.Metrics(), 0)
+}
@@ -4,6 +4,9 @@ import (
 	"fullerite/config"
 	"fullerite/handler"
 	"fullerite/metric"
+	"fullerite/processor"
+
+	"sync"
 )
 
 func startHandlers(c config.Config) (handlers []handler.Handler) {
@@ -38,6 +41,95 @@ func startHandler(name string, globalConfig config.Config, instanceConfig map[st
 
 func writeToHandlers(handlers []handler.Handler, metric metric.Metric) {
 	for i := range handlers {
-		handlers[i].Channel() <- metric
+		handlers[i].Write(metric)
+	}
+}
+
+// handlerSet holds the running handlers along with the global processors
+// that run before metrics are handed to them. It is shared by the collector
+// readers, the error hook and the internal server, and a reload swaps the
+// handlers in it while they keep running.
+type handlerSet struct {
+	lock       sync.RWMutex
+	handlers   []handler.Handler
+	processors *processor.Chain
+	limiter    *cardinalityLimiter
+}
+
+func newHandlerSet(handlers []handler.Handler, processors *processor.Chain) *handlerSet {
+	return &handlerSet{handlers: handlers, processors: processors}
+}
+
+// Handlers returns the handlers that are currently running
+func (s *handlerSet) Handlers() []handler.Handler {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.handlers
+}
+
+// replace swaps in a new list of handlers and processors. Writes that are
+// still on their way to a replaced handler are counted as dropped once it
+// is stopped.
+func (s *handlerSet) replace(handlers []handler.Handler, processors *processor.Chain) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.handlers = handlers
+	s.processors = processors
+}
+
+// updateListeners makes the collector queues of the handlers match the
+// collectors of the config
+func (s *handlerSet) updateListeners(c config.Config) {
+	for _, h := range s.Handlers() {
+		h.UpdateListeners(c)
+	}
+}
+
+// enqueue runs a metric of the named collector through the processors and
+// the cardinality limiter and hands it to every handler. It returns how many
+// handlers had to drop a metric.
+func (s *handlerSet) enqueue(collectorName string, m metric.Metric) (dropped uint64) {
+	handlers, processors := s.snapshot()
+
+	m, keep := processors.ProcessMetric(m)
+	if !keep {
+		return 0
+	}
+
+	keep, warning := s.limiter.limit(collectorName, &m)
+	if warning != nil {
+		dropped += fanOut(handlers, collectorName, *warning)
+	}
+	if keep {
+		dropped += fanOut(handlers, collectorName, m)
+	}
+	return dropped
+}
+
+// snapshot returns the current handlers and processors. A handler that
+// blocks must not hold up a reload, so they are used without the lock.
+func (s *handlerSet) snapshot() ([]handler.Handler, *processor.Chain) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.handlers, s.processors
+}
+
+// fanOut hands the metric to every handler
+func fanOut(handlers []handler.Handler, collectorName string, m metric.Metric) (dropped uint64) {
+	for i := range handlers {
+		if !handlers[i].Enqueue(collectorName, m) {
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// write runs a metric through the processors and sends it straight to
+// every handler
+func (s *handlerSet) write(m metric.Metric) {
+	handlers, processors := s.snapshot()
+
+	if m, keep := processors.ProcessMetric(m); keep {
+		writeToHandlers(handlers, m)
 	}
 }
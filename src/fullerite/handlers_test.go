@@ -4,6 +4,7 @@ import (
 	"fullerite/config"
 	"fullerite/handler"
 	"fullerite/metric"
+	"fullerite/processor"
 
 	"fmt"
 	"testing"
@@ -62,6 +63,7 @@ func TestCanSendMetricsWhiteList(t *testing.T) {
 	checkEmission(t, "coll1", h, true)
 	checkEmission(t, "coll2", h, false)
 	checkEmission(t, "coll3", h, false)
+	checkEmission(t, handler.InternalCollector, h, false)
 }
 
 func TestCanSendMetricsOnlyBlackList(t *testing.T) {
@@ -97,4 +99,51 @@ func TestCanSendMetrics(t *testing.T) {
 	checkEmission(t, "coll1", h, true)
 	checkEmission(t, "coll2", h, true)
 	checkEmission(t, "coll3", h, true)
+	checkEmission(t, handler.InternalCollector, h, true)
+}
+
+func TestHandlerSetRunsProcessors(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	log := logrus.WithFields(logrus.Fields{"app": "fullerite", "pkg": "handler"})
+	h := handler.NewTest(make(chan metric.Metric, 5), 10, 10, time.Second, log)
+	h.Configure(map[string]interface{}{"queueCapacity": 5})
+	h.InitListeners(config.Config{Collectors: []string{"coll1"}})
+
+	processors, err := processor.NewChain([]map[string]interface{}{
+		{"type": "filter", "match": map[string]interface{}{"name": "^dropped$"}},
+		{"type": "addDimensions", "dimensions": map[string]interface{}{"region": "us-west"}},
+	})
+	assert.Nil(t, err)
+	handlers := newHandlerSet([]handler.Handler{h}, processors)
+
+	handlers.enqueue("coll1", metric.New("dropped"))
+	handlers.enqueue("coll1", metric.New("kept"))
+
+	ch := h.CollectorChannels()["coll1"]
+	assert.Equal(t, 1, len(ch))
+	m := <-ch
+	assert.Equal(t, "kept", m.Name)
+	assert.Equal(t, "us-west", m.Dimensions["region"])
+}
+
+func TestHandlerSetLimitsCardinality(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	log := logrus.WithFields(logrus.Fields{"app": "fullerite", "pkg": "handler"})
+	h := handler.NewTest(make(chan metric.Metric, 5), 10, 10, time.Second, log)
+	h.Configure(map[string]interface{}{"queueCapacity": 5})
+	h.InitListeners(config.Config{Collectors: []string{"coll1"}})
+
+	handlers := newHandlerSet([]handler.Handler{h}, nil)
+	handlers.limiter = newCardinalityLimiter()
+	handlers.limiter.configure(map[string]interface{}{"maxSeriesPerCollector": 1})
+
+	handlers.enqueue("coll1", metric.New("first"))
+	handlers.enqueue("coll1", metric.New("second"))
+
+	ch := h.CollectorChannels()["coll1"]
+	assert.Equal(t, 2, len(ch))
+	assert.Equal(t, "first", (<-ch).Name)
+	assert.Equal(t, "fullerite.cardinality_limit_exceeded", (<-ch).Name)
 }
@@ -0,0 +1,57 @@
+package internalserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// authenticate lets only the requests through that carry the auth token or
+// the control token as a bearer token, or a user and password for basic
+// auth. Everything is open without an auth token and passwords.
+func (srv *InternalServer) authenticate(next http.Handler) http.Handler {
+	if srv.authToken == "" && srv.authPasswords == nil {
+		return next
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if !srv.authenticated(req) {
+			srv.log.Debug("Unauthenticated request ", req.URL.Path, " from ", req.RemoteAddr)
+			if srv.authPasswords != nil {
+				writer.Header().Set("WWW-Authenticate", `Basic realm="fullerite"`)
+			} else {
+				writer.Header().Set("WWW-Authenticate", `Bearer realm="fullerite"`)
+			}
+			http.Error(writer, "Missing or invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(writer, req)
+	})
+}
+
+func (srv *InternalServer) authenticated(req *http.Request) bool {
+	if user, password, ok := req.BasicAuth(); ok {
+		expected, exists := srv.authPasswords[user]
+		return exists && equalSecrets(password, expected)
+	}
+
+	token, ok := bearerToken(req)
+	if !ok {
+		return false
+	}
+	return (srv.authToken != "" && equalSecrets(token, srv.authToken)) ||
+		(srv.controlToken != "" && equalSecrets(token, srv.controlToken))
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimPrefix(header, "Bearer "), true
+}
+
+// equalSecrets compares in constant time, so that a secret cannot be
+// guessed from the timing of the answers
+func equalSecrets(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
@@ -0,0 +1,124 @@
+package internalserver
+
+import (
+	"fullerite/config"
+	"test_utils"
+
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticateOff(t *testing.T) {
+	srv := New(config.Config{}, collectorStatFunc, collectorStatFunc)
+	server := httptest.NewServer(srv.authenticate(srv.mux()))
+	defer server.Close()
+
+	rsp, err := http.Get(server.URL + "/metrics")
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+}
+
+func TestAuthenticate(t *testing.T) {
+	srv := New(config.Config{InternalServerConfig: map[string]interface{}{
+		"authToken":     "t0ken",
+		"authPasswords": map[string]interface{}{"ops": "pa55"},
+		"controlToken":  "s3cr3t",
+	}}, collectorStatFunc, collectorStatFunc)
+	server := httptest.NewServer(srv.authenticate(srv.mux()))
+	defer server.Close()
+
+	get := func(auth func(*http.Request)) *http.Response {
+		req, _ := http.NewRequest("GET", server.URL+"/metrics", nil)
+		auth(req)
+		rsp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		rsp.Body.Close()
+		return rsp
+	}
+
+	rsp := get(func(*http.Request) {})
+	assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+	assert.Equal(t, `Basic realm="fullerite"`, rsp.Header.Get("WWW-Authenticate"))
+
+	for _, auth := range []func(*http.Request){
+		func(req *http.Request) { req.Header.Set("Authorization", "Bearer t0ken") },
+		func(req *http.Request) { req.Header.Set("Authorization", "Bearer s3cr3t") },
+		func(req *http.Request) { req.SetBasicAuth("ops", "pa55") },
+	} {
+		assert.Equal(t, http.StatusOK, get(auth).StatusCode)
+	}
+
+	for _, auth := range []func(*http.Request){
+		func(req *http.Request) { req.Header.Set("Authorization", "Bearer wrong") },
+		func(req *http.Request) { req.Header.Set("Authorization", "Bearer ") },
+		func(req *http.Request) { req.SetBasicAuth("ops", "wrong") },
+		func(req *http.Request) { req.SetBasicAuth("nobody", "") },
+	} {
+		assert.Equal(t, http.StatusUnauthorized, get(auth).StatusCode)
+	}
+}
+
+func TestAuthenticateTokenOnly(t *testing.T) {
+	srv := New(config.Config{InternalServerConfig: map[string]interface{}{
+		"authToken": "t0ken",
+	}}, collectorStatFunc, collectorStatFunc)
+	rsp := httptest.NewRecorder()
+	srv.authenticate(srv.mux()).ServeHTTP(rsp, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, rsp.Code)
+	assert.Equal(t, `Bearer realm="fullerite"`, rsp.Header().Get("WWW-Authenticate"))
+}
+
+func TestRunTLS(t *testing.T) {
+	certs, err := test_utils.WriteTestCertificates()
+	require.Nil(t, err)
+	defer certs.Remove()
+
+	// find a free port, the server does not tell which one it took
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	srv := New(config.Config{InternalServerConfig: map[string]interface{}{
+		"port":        port,
+		"bindAddress": "127.0.0.1",
+		"tlsCert":     certs.ServerCert,
+		"tlsKey":      certs.ServerKey,
+		"tlsClientCA": certs.CA,
+	}}, collectorStatFunc, collectorStatFunc)
+	go srv.Run()
+
+	url := "https://127.0.0.1:" + strconv.Itoa(port) + "/metrics"
+	get := func(tlsConfig *tls.Config) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		return client.Get(url)
+	}
+
+	var rsp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if rsp, err = get(certs.ClientTLSConfig(true)); err == nil {
+			break
+		}
+	}
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	_, err = get(certs.ClientTLSConfig(false))
+	assert.NotNil(t, err, "a client without a certificate should be turned away")
+
+	rsp, err = http.Get("http://127.0.0.1:" + strconv.Itoa(port) + "/metrics")
+	if err == nil {
+		rsp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode, "should not speak plaintext")
+	}
+}
@@ -0,0 +1,166 @@
+package internalserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+const defaultControlPath = "/control"
+
+// ErrNotRunning is returned by a Controller for a collector or handler
+// that is not running
+var ErrNotRunning = errors.New("not running")
+
+// ComponentInfo describes a running collector or handler and the
+// configuration it runs with, secrets redacted
+type ComponentInfo struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type,omitempty"`
+	Interval int         `json:"interval"`
+	Paused   bool        `json:"paused,omitempty"`
+	Config   interface{} `json:"config"`
+}
+
+// Controller changes what fullerite runs, it is what the control API
+// calls into. Its methods return ErrNotRunning for unknown names.
+type Controller interface {
+	Collectors() []ComponentInfo
+	Handlers() []ComponentInfo
+
+	// Collect runs a collector right away, outside its schedule
+	Collect(name string) error
+	// Pause skips the scheduled runs of a collector until it is resumed
+	Pause(name string) error
+	Resume(name string) error
+
+	// Flush emits what a handler has buffered right away
+	Flush(name string) error
+}
+
+// SetController enables the control API under the control path. Every
+// request has to carry the control token as a bearer token, the API stays
+// off without one.
+func (srv *InternalServer) SetController(c Controller) {
+	srv.controller = c
+}
+
+// handleControlRequest serves the control API:
+//
+//	GET  /control/collectors                  running collectors and configs
+//	GET  /control/handlers                    running handlers and configs
+//	POST /control/collectors/<name>/collect   run a collector right away
+//	POST /control/collectors/<name>/pause     skip its scheduled runs
+//	POST /control/collectors/<name>/resume    take them again
+//	POST /control/handlers/<name>/flush       emit what a handler buffered
+//	GET  /control/loglevel                    the log level
+//	POST /control/loglevel?level=debug        change the log level
+//
+// Actions answer like the reload path does:
+//
+//	{"status": "ok"}
+//	{"status": "failed", "error": "..."}
+func (srv *InternalServer) handleControlRequest(writer http.ResponseWriter, req *http.Request) {
+	if !srv.authorized(req) {
+		writer.Header().Set("WWW-Authenticate", `Bearer realm="fullerite"`)
+		http.Error(writer, "Missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, srv.controlPath), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "collectors":
+		srv.writeControlListing(writer, req, srv.controller.Collectors())
+	case len(parts) == 1 && parts[0] == "handlers":
+		srv.writeControlListing(writer, req, srv.controller.Handlers())
+	case len(parts) == 1 && parts[0] == "loglevel":
+		srv.handleLogLevel(writer, req)
+	case len(parts) == 3 && parts[0] == "collectors":
+		actions := map[string]func(string) error{
+			"collect": srv.controller.Collect,
+			"pause":   srv.controller.Pause,
+			"resume":  srv.controller.Resume,
+		}
+		srv.runControlAction(writer, req, actions[parts[2]], parts[1])
+	case len(parts) == 3 && parts[0] == "handlers" && parts[2] == "flush":
+		srv.runControlAction(writer, req, srv.controller.Flush, parts[1])
+	default:
+		http.NotFound(writer, req)
+	}
+}
+
+// authorized tells whether the request carries the control token
+func (srv *InternalServer) authorized(req *http.Request) bool {
+	token, ok := bearerToken(req)
+	return ok && equalSecrets(token, srv.controlToken)
+}
+
+func (srv *InternalServer) writeControlListing(writer http.ResponseWriter, req *http.Request, listing []ComponentInfo) {
+	if req.Method != "GET" {
+		writer.Header().Set("Allow", "GET")
+		http.Error(writer, "Only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	srv.writeControlResponse(writer, http.StatusOK, listing)
+}
+
+func (srv *InternalServer) runControlAction(writer http.ResponseWriter, req *http.Request, action func(string) error, name string) {
+	if action == nil {
+		http.NotFound(writer, req)
+		return
+	}
+	if req.Method != "POST" {
+		writer.Header().Set("Allow", "POST")
+		http.Error(writer, "Only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	srv.log.Info("Control request ", req.URL.Path, " from ", req.RemoteAddr)
+	err := action(name)
+	switch {
+	case err == nil:
+		srv.writeControlResponse(writer, http.StatusOK, map[string]string{"status": "ok"})
+	case err == ErrNotRunning:
+		srv.writeControlResponse(writer, http.StatusNotFound,
+			map[string]string{"status": "failed", "error": name + " is " + err.Error()})
+	default:
+		srv.writeControlResponse(writer, http.StatusConflict,
+			map[string]string{"status": "failed", "error": err.Error()})
+	}
+}
+
+// handleLogLevel reports the log level, or changes it until the next
+// restart when posted a level
+func (srv *InternalServer) handleLogLevel(writer http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+	case "POST":
+		level, err := l.ParseLevel(req.URL.Query().Get("level"))
+		if err != nil {
+			srv.writeControlResponse(writer, http.StatusBadRequest,
+				map[string]string{"status": "failed", "error": err.Error()})
+			return
+		}
+		srv.log.Info("Changing the log level to ", level, " on request from ", req.RemoteAddr)
+		l.SetLevel(level)
+	default:
+		writer.Header().Set("Allow", "GET, POST")
+		http.Error(writer, "Only GET and POST are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	srv.writeControlResponse(writer, http.StatusOK, map[string]string{"level": l.GetLevel().String()})
+}
+
+func (srv *InternalServer) writeControlResponse(writer http.ResponseWriter, status int, rsp interface{}) {
+	asString, err := json.Marshal(rsp)
+	if err != nil {
+		srv.log.Warn("Failed to marshal the control response because of error ", err)
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	writer.Write(asString)
+}
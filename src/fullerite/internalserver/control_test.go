@@ -0,0 +1,126 @@
+package internalserver
+
+import (
+	"fullerite/config"
+
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testController struct {
+	calls []string
+}
+
+func (c *testController) Collectors() []ComponentInfo {
+	return []ComponentInfo{{Name: "CPU", Type: "collector", Interval: 10}}
+}
+
+func (c *testController) Handlers() []ComponentInfo {
+	return []ComponentInfo{{Name: "Graphite", Interval: 10}}
+}
+
+func (c *testController) action(name, action string) error {
+	switch name {
+	case "CPU", "Graphite":
+		c.calls = append(c.calls, action+" "+name)
+		return nil
+	case "Diamond":
+		return errors.New("listener collectors do not run on a schedule")
+	}
+	return ErrNotRunning
+}
+
+func (c *testController) Collect(name string) error { return c.action(name, "collect") }
+func (c *testController) Pause(name string) error   { return c.action(name, "pause") }
+func (c *testController) Resume(name string) error  { return c.action(name, "resume") }
+func (c *testController) Flush(name string) error   { return c.action(name, "flush") }
+
+func controlRequest(srv *InternalServer, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rsp := httptest.NewRecorder()
+	srv.handleControlRequest(rsp, req)
+	return rsp
+}
+
+func buildControlServer() (*InternalServer, *testController) {
+	srv := New(config.Config{InternalServerConfig: map[string]interface{}{
+		"controlPath":  "/control/",
+		"controlToken": "s3cr3t",
+	}}, nil, nil)
+	controller := new(testController)
+	srv.SetController(controller)
+	return srv, controller
+}
+
+func TestControlRequiresToken(t *testing.T) {
+	srv, controller := buildControlServer()
+
+	for _, token := range []string{"", "wrong", "s3cr3"} {
+		rsp := controlRequest(srv, "POST", "/control/collectors/CPU/collect", token)
+		assert.Equal(t, http.StatusUnauthorized, rsp.Code, token)
+	}
+	assert.Empty(t, controller.calls)
+}
+
+func TestControlListing(t *testing.T) {
+	srv, _ := buildControlServer()
+
+	rsp := controlRequest(srv, "GET", "/control/collectors", "s3cr3t")
+	require.Equal(t, http.StatusOK, rsp.Code)
+	var listing []ComponentInfo
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &listing))
+	assert.Equal(t, "CPU", listing[0].Name)
+
+	rsp = controlRequest(srv, "GET", "/control/handlers/", "s3cr3t")
+	assert.Contains(t, rsp.Body.String(), `"name":"Graphite"`)
+
+	rsp = controlRequest(srv, "POST", "/control/handlers", "s3cr3t")
+	assert.Equal(t, http.StatusMethodNotAllowed, rsp.Code)
+}
+
+func TestControlActions(t *testing.T) {
+	srv, controller := buildControlServer()
+
+	for _, path := range []string{
+		"/control/collectors/CPU/collect",
+		"/control/collectors/CPU/pause",
+		"/control/collectors/CPU/resume",
+		"/control/handlers/Graphite/flush",
+	} {
+		rsp := controlRequest(srv, "POST", path, "s3cr3t")
+		assert.Equal(t, http.StatusOK, rsp.Code, path)
+		assert.Equal(t, `{"status":"ok"}`, rsp.Body.String())
+	}
+	assert.Equal(t, []string{"collect CPU", "pause CPU", "resume CPU", "flush Graphite"}, controller.calls)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, controlRequest(srv, "GET", "/control/collectors/CPU/collect", "s3cr3t").Code)
+	assert.Equal(t, http.StatusNotFound, controlRequest(srv, "POST", "/control/collectors/Missing/collect", "s3cr3t").Code)
+	assert.Equal(t, http.StatusNotFound, controlRequest(srv, "POST", "/control/collectors/CPU/flush", "s3cr3t").Code)
+	assert.Equal(t, http.StatusConflict, controlRequest(srv, "POST", "/control/collectors/Diamond/pause", "s3cr3t").Code)
+}
+
+func TestControlLogLevel(t *testing.T) {
+	srv, _ := buildControlServer()
+	defer l.SetLevel(l.GetLevel())
+
+	rsp := controlRequest(srv, "POST", "/control/loglevel?level=debug", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	assert.Equal(t, `{"level":"debug"}`, rsp.Body.String())
+	assert.Equal(t, l.DebugLevel, l.GetLevel())
+
+	rsp = controlRequest(srv, "POST", "/control/loglevel?level=loud", "s3cr3t")
+	assert.Equal(t, http.StatusBadRequest, rsp.Code)
+
+	rsp = controlRequest(srv, "GET", "/control/loglevel", "s3cr3t")
+	assert.Equal(t, `{"level":"debug"}`, rsp.Body.String())
+}
@@ -0,0 +1,125 @@
+package internalserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// the paths net/http/pprof serves its index on, the index links to the
+// profiles relative to it
+const (
+	pprofPath      = "/debug/pprof/"
+	goroutinesPath = "/debug/goroutines"
+)
+
+// mountDebug mounts the debugging endpoints on the mux:
+//
+//	/debug/pprof/                         the index of the runtime profiles
+//	/debug/pprof/profile?seconds=30       a CPU profile
+//	/debug/pprof/heap                     a heap profile, and the others
+//	/debug/pprof/trace?seconds=5          a runtime trace, see go tool trace
+//	/debug/goroutines                     the goroutines per subsystem
+//	/debug/goroutines?subsystem=handler   the stacks of a subsystem
+//
+// They require the control token, and are not mounted without one.
+func (srv *InternalServer) mountDebug(mux *http.ServeMux) {
+	mux.HandleFunc(pprofPath, srv.requireToken(pprof.Index))
+	mux.HandleFunc(pprofPath+"cmdline", srv.requireToken(pprof.Cmdline))
+	mux.HandleFunc(pprofPath+"profile", srv.requireToken(pprof.Profile))
+	mux.HandleFunc(pprofPath+"symbol", srv.requireToken(pprof.Symbol))
+	mux.HandleFunc(pprofPath+"trace", srv.requireToken(pprof.Trace))
+	mux.HandleFunc(goroutinesPath, srv.requireToken(srv.handleGoroutinesRequest))
+}
+
+// requireToken only lets requests with the control token through, if there
+// is one
+func (srv *InternalServer) requireToken(f http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		if srv.controlToken != "" && !srv.authorized(req) {
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="fullerite"`)
+			http.Error(writer, "Missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		f(writer, req)
+	}
+}
+
+// handleGoroutinesRequest counts the goroutines of every subsystem, or dumps
+// the stacks of the subsystem given:
+//
+//	{"collector": 12, "handler": 30, "internalserver": 2, "main": 14, "runtime": 5}
+func (srv *InternalServer) handleGoroutinesRequest(writer http.ResponseWriter, req *http.Request) {
+	goroutines := goroutinesBySubsystem(allStacks())
+
+	subsystem := req.URL.Query().Get("subsystem")
+	if subsystem == "" {
+		counts := make(map[string]int)
+		for name, stacks := range goroutines {
+			counts[name] = len(stacks)
+		}
+		asString, err := json.Marshal(counts)
+		if err != nil {
+			srv.log.Warn("Failed to marshal the goroutines because of error ", err)
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(asString)
+		return
+	}
+
+	stacks, exists := goroutines[subsystem]
+	if !exists {
+		http.Error(writer, "No goroutines of subsystem "+subsystem, http.StatusNotFound)
+		return
+	}
+	sort.Strings(stacks)
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writer.Write([]byte(strings.Join(stacks, "\n\n") + "\n"))
+}
+
+// allStacks returns the stacks of all goroutines the way a panic prints them
+func allStacks() string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutinesBySubsystem splits a dump of all goroutines by the fullerite
+// package the innermost of their fullerite functions is in. Goroutines that
+// never enter fullerite code belong to "runtime".
+func goroutinesBySubsystem(dump string) map[string][]string {
+	goroutines := make(map[string][]string)
+	for _, stack := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		name := stackSubsystem(stack)
+		goroutines[name] = append(goroutines[name], stack)
+	}
+	return goroutines
+}
+
+func stackSubsystem(stack string) string {
+	for _, line := range strings.Split(stack, "\n")[1:] {
+		if strings.HasPrefix(line, "\t") {
+			continue
+		}
+		// the main package shows up as main or, in tests, as fullerite
+		if strings.HasPrefix(line, "main.") || strings.HasPrefix(line, "fullerite.") {
+			return "main"
+		}
+		if !strings.HasPrefix(line, "fullerite/") {
+			continue
+		}
+		function := strings.TrimPrefix(line, "fullerite/")
+		if i := strings.IndexAny(function, "./"); i > 0 {
+			return function[:i]
+		}
+	}
+	return "runtime"
+}
@@ -0,0 +1,88 @@
+package internalserver
+
+import (
+	"fullerite/config"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutinesBySubsystem(t *testing.T) {
+	dump := strings.Join([]string{
+		"goroutine 1 [chan receive]:\n" +
+			"main.start(0xc42)\n\t/src/fullerite/main.go:203 +0x3a5\n" +
+			"main.main()\n\t/src/fullerite/main.go:150 +0x10",
+		"goroutine 7 [select]:\n" +
+			"fullerite/handler.(*BaseHandler).listenForMetrics(0xc42)\n\t/src/fullerite/handler/handler.go:668\n" +
+			"created by fullerite/handler.(*BaseHandler).run\n\t/src/fullerite/handler/handler.go:643",
+		"goroutine 9 [IO wait]:\n" +
+			"net.(*netFD).accept(0xc42)\n\t/go/src/net/fd_unix.go:238\n" +
+			"fullerite/collector.(*Diamond).collectDiamond(0xc42)\n\t/src/fullerite/collector/diamond.go:121",
+		"goroutine 2 [force gc (idle)]:\n" +
+			"runtime.gopark(0x0)\n\t/go/src/runtime/proc.go:292",
+	}, "\n\n")
+
+	goroutines := goroutinesBySubsystem(dump)
+	assert.Len(t, goroutines["main"], 1)
+	assert.Len(t, goroutines["handler"], 1)
+	assert.Len(t, goroutines["collector"], 1, "should go by the innermost fullerite function")
+	assert.Len(t, goroutines["runtime"], 1)
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	srv := New(config.Config{}, collectorStatFunc, collectorStatFunc)
+	server := httptest.NewServer(srv.mux())
+	rsp, err := http.Get(server.URL + "/debug/pprof/")
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode, "should be off by default")
+	server.Close()
+
+	srv = New(config.Config{InternalServerConfig: map[string]interface{}{"debug": true}}, collectorStatFunc, collectorStatFunc)
+	server = httptest.NewServer(srv.mux())
+	rsp, err = http.Get(server.URL + "/debug/pprof/")
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode, "should be off without a controlToken")
+	server.Close()
+
+	srv = New(config.Config{InternalServerConfig: map[string]interface{}{
+		"debug":        true,
+		"controlToken": "s3cr3t",
+	}}, collectorStatFunc, collectorStatFunc)
+	server = httptest.NewServer(srv.mux())
+	defer server.Close()
+
+	rsp, err = http.Get(server.URL + "/debug/pprof/")
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+		rsp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		return rsp
+	}
+
+	rsp = get("/debug/pprof/goroutine?debug=1")
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	rsp = get("/debug/goroutines")
+	defer rsp.Body.Close()
+	var counts map[string]int
+	require.Nil(t, json.NewDecoder(rsp.Body).Decode(&counts))
+	assert.True(t, counts["runtime"] > 0)
+
+	rsp = get("/debug/goroutines?subsystem=nothing")
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode)
+}
@@ -0,0 +1,287 @@
+package internalserver
+
+import (
+	"fullerite/handler"
+
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthPath = "/health"
+	defaultReadyPath  = "/ready"
+
+	defaultMaxEmissionAge       = 300 // seconds
+	defaultMaxMissedCollections = 3
+	defaultMaxDropRate          = 0.5
+	defaultDropRateWindow       = 300 // seconds
+)
+
+// Status is what the health and readiness checks are decided on, by name of
+// the handler or collector
+type Status struct {
+	Handlers   map[string]HandlerStatus
+	Collectors map[string]CollectorStatus
+}
+
+// HandlerStatus is the health of a running handler
+type HandlerStatus struct {
+	handler.Health
+	// Started is when the handler was started, the age of a handler
+	// that has not emitted yet is measured from it
+	Started time.Time
+}
+
+// CollectorStatus is the health of a running collector
+type CollectorStatus struct {
+	Started  time.Time
+	Interval int
+	// Listener collectors are not run on a schedule, so only whether
+	// they are listening is checked
+	Listener bool
+	// Listening is nil for collectors without a socket
+	Listening *bool
+	// LastSuccess is zero until a run succeeded
+	LastSuccess time.Time
+	// Schedule returns the first time after t the collector is due at,
+	// collectors without one are due every interval
+	Schedule func(t time.Time) time.Time
+	// Paused collectors skip their scheduled runs, so they are not
+	// expected to succeed
+	Paused bool
+}
+
+// dueAfter returns when the given number of runs after t are due
+func (s CollectorStatus) dueAfter(t time.Time, runs int) time.Time {
+	if s.Schedule == nil {
+		return t.Add(time.Duration(s.Interval*runs) * time.Second)
+	}
+	for i := 0; i < runs; i++ {
+		t = s.Schedule(t)
+	}
+	return t
+}
+
+// StatusFunc returns the status of the running handlers and collectors
+type StatusFunc func() Status
+
+// SetStatusFunc makes the server answer health and readiness checks on the
+// health and ready paths
+func (srv *InternalServer) SetStatusFunc(f StatusFunc) {
+	srv.statusFunc = f
+}
+
+// healthReport is the response of the health and ready paths
+type healthReport struct {
+	checkResult
+	Handlers   map[string]handlerReport   `json:"handlers"`
+	Collectors map[string]collectorReport `json:"collectors"`
+}
+
+type handlerReport struct {
+	checkResult
+	LastEmission string  `json:"lastEmission,omitempty"`
+	BreakerState string  `json:"breakerState,omitempty"`
+	DropRate     float64 `json:"dropRate"`
+}
+
+type collectorReport struct {
+	checkResult
+	LastSuccess string `json:"lastSuccess,omitempty"`
+	Listening   *bool  `json:"listening,omitempty"`
+}
+
+// checkResult is "ok" until a check fails
+type checkResult struct {
+	Status   string   `json:"status"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+func (c *checkResult) fail(format string, args ...interface{}) {
+	c.Problems = append(c.Problems, fmt.Sprintf(format, args...))
+}
+
+func (c *checkResult) decide() {
+	c.Status = "ok"
+	if len(c.Problems) > 0 {
+		c.Status = "failing"
+	}
+}
+
+// handleHealthRequest tells whether fullerite is working, a restart is in
+// order once it is not. It fails with 503 if a collector has not succeeded
+// for too many scheduled runs, a handler has not emitted for too long or dropped
+// too many metrics, or a listener is not bound:
+//
+//	{
+//		"status": "failing",
+//		"problems": ["handler Graphite is failing"],
+//		"handlers": {
+//			"Graphite": {
+//				"status": "failing",
+//				"problems": ["dropped 80% of the metrics"],
+//				"lastEmission": "2016-10-16T12:00:00Z",
+//				"breakerState": "closed",
+//				"dropRate": 0.8
+//			}
+//		},
+//		"collectors": {
+//			"Diamond": {"status": "ok", "listening": true}
+//		}
+//	}
+func (srv *InternalServer) handleHealthRequest(writer http.ResponseWriter, req *http.Request) {
+	srv.writeHealthReport(writer, srv.checkHealth(srv.statusFunc(), time.Now(), false))
+}
+
+// handleReadyRequest tells whether fullerite can take metrics and get them
+// out. It fails with 503 if a listener is not bound or the circuit breaker
+// of a handler is open, the response is the same as the one of the health
+// path.
+func (srv *InternalServer) handleReadyRequest(writer http.ResponseWriter, req *http.Request) {
+	srv.writeHealthReport(writer, srv.checkHealth(srv.statusFunc(), time.Now(), true))
+}
+
+func (srv *InternalServer) writeHealthReport(writer http.ResponseWriter, rsp healthReport) {
+	asString, err := json.Marshal(rsp)
+	if err != nil {
+		srv.log.Warn("Failed to marshal the health because of error ", err)
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	if rsp.Status != "ok" {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writer.Write(asString)
+}
+
+// checkHealth runs either the health or the readiness checks, the overall
+// status fails if any of the handlers or collectors does
+func (srv *InternalServer) checkHealth(status Status, now time.Time, readiness bool) healthReport {
+	rsp := healthReport{
+		Handlers:   make(map[string]handlerReport),
+		Collectors: make(map[string]collectorReport),
+	}
+
+	dropRates := srv.dropRates.rates(now, status.Handlers)
+	for name, s := range status.Handlers {
+		report := srv.checkHandler(s, dropRates[name], now, readiness)
+		if report.Status != "ok" {
+			rsp.fail("handler %s is failing", name)
+		}
+		rsp.Handlers[name] = report
+	}
+	for name, s := range status.Collectors {
+		report := srv.checkCollector(s, now, readiness)
+		if report.Status != "ok" {
+			rsp.fail("collector %s is failing", name)
+		}
+		rsp.Collectors[name] = report
+	}
+
+	rsp.decide()
+	return rsp
+}
+
+func (srv *InternalServer) checkHandler(s HandlerStatus, dropRate float64, now time.Time, readiness bool) handlerReport {
+	report := handlerReport{BreakerState: s.BreakerState, DropRate: dropRate}
+	if !s.LastEmission.IsZero() {
+		report.LastEmission = s.LastEmission.UTC().Format(time.RFC3339)
+	}
+
+	if readiness {
+		if s.BreakerState == "open" {
+			report.fail("the circuit breaker is open")
+		}
+	} else {
+		since := s.LastEmission
+		if since.IsZero() {
+			since = s.Started
+		}
+		if age := now.Sub(since); srv.maxEmissionAge > 0 && age > srv.maxEmissionAge {
+			report.fail("no successful emission for %s", age.Truncate(time.Second))
+		}
+		if dropRate > srv.maxDropRate {
+			report.fail("dropped %.0f%% of the metrics", dropRate*100)
+		}
+	}
+
+	report.decide()
+	return report
+}
+
+func (srv *InternalServer) checkCollector(s CollectorStatus, now time.Time, readiness bool) collectorReport {
+	report := collectorReport{Listening: s.Listening}
+	if !s.LastSuccess.IsZero() {
+		report.LastSuccess = s.LastSuccess.UTC().Format(time.RFC3339)
+	}
+
+	if s.Listening != nil && !*s.Listening {
+		report.fail("not listening")
+	}
+	if !readiness && !s.Listener && !s.Paused && srv.maxMissedCollections > 0 {
+		since := s.LastSuccess
+		if since.IsZero() {
+			since = s.Started
+		}
+		// a run may take until the next one is due and finish then
+		if now.After(s.dueAfter(since, srv.maxMissedCollections+1)) {
+			report.fail("no successful run for %s", now.Sub(since).Truncate(time.Second))
+		}
+	}
+
+	report.decide()
+	return report
+}
+
+// dropTracker keeps the counts of the handlers from a while ago, so that the
+// drop rate is the one of the last window rather than since the start
+type dropTracker struct {
+	lock     sync.Mutex
+	window   time.Duration
+	previous dropSnapshot
+	current  dropSnapshot
+}
+
+type dropSnapshot struct {
+	taken  time.Time
+	counts map[string]handler.Health
+}
+
+func newDropTracker(window time.Duration) *dropTracker {
+	return &dropTracker{window: window}
+}
+
+// rates returns the share of the metrics each handler dropped since the
+// previous snapshot, which is between one and two windows old. Until there
+// is one the rates are since the handlers started.
+func (t *dropTracker) rates(now time.Time, handlers map[string]HandlerStatus) map[string]float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.current.taken.IsZero() || now.Sub(t.current.taken) >= t.window {
+		t.previous = t.current
+		t.current = dropSnapshot{taken: now, counts: make(map[string]handler.Health)}
+		for name, s := range handlers {
+			t.current.counts[name] = s.Health
+		}
+	}
+
+	rates := make(map[string]float64)
+	for name, s := range handlers {
+		base := t.previous.counts[name]
+		if s.MetricsSent < base.MetricsSent || s.MetricsDropped < base.MetricsDropped {
+			// the handler was restarted since
+			base = handler.Health{}
+		}
+
+		sent := s.MetricsSent - base.MetricsSent
+		dropped := s.MetricsDropped - base.MetricsDropped
+		if sent+dropped > 0 {
+			rates[name] = float64(dropped) / float64(sent+dropped)
+		}
+	}
+	return rates
+}
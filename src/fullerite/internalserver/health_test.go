@@ -0,0 +1,126 @@
+package internalserver
+
+import (
+	"fullerite/config"
+	"fullerite/handler"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildHealthServer(cfg map[string]interface{}, status Status) *InternalServer {
+	srv := New(config.Config{InternalServerConfig: cfg}, nil, nil)
+	srv.SetStatusFunc(func() Status { return status })
+	return srv
+}
+
+func TestHealthyStatus(t *testing.T) {
+	now := time.Now()
+	listening := true
+	srv := buildHealthServer(nil, Status{
+		Handlers: map[string]HandlerStatus{
+			"Graphite": {Health: handler.Health{LastEmission: now, BreakerState: "closed", MetricsSent: 10}},
+		},
+		Collectors: map[string]CollectorStatus{
+			"CPU":     {Interval: 10, LastSuccess: now.Add(-5 * time.Second)},
+			"Diamond": {Listener: true, Listening: &listening, Started: now.Add(-time.Hour)},
+		},
+	})
+
+	for _, handle := range []func(http.ResponseWriter, *http.Request){srv.handleHealthRequest, srv.handleReadyRequest} {
+		rsp := httptest.NewRecorder()
+		handle(rsp, httptest.NewRequest("GET", "/health", nil))
+		assert.Equal(t, http.StatusOK, rsp.Code)
+
+		var report healthReport
+		require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &report))
+		assert.Equal(t, "ok", report.Status)
+		assert.Equal(t, "closed", report.Handlers["Graphite"].BreakerState)
+		assert.NotEmpty(t, report.Collectors["CPU"].LastSuccess)
+		assert.True(t, *report.Collectors["Diamond"].Listening)
+	}
+}
+
+func TestHealthThresholds(t *testing.T) {
+	now := time.Now()
+	srv := buildHealthServer(map[string]interface{}{
+		"maxEmissionAge":       60,
+		"maxMissedCollections": 2,
+		"maxDropRate":          0.2,
+	}, Status{})
+
+	stale := HandlerStatus{Started: now.Add(-2 * time.Minute)}
+	dropping := HandlerStatus{Health: handler.Health{LastEmission: now, MetricsSent: 70, MetricsDropped: 30}}
+	fresh := HandlerStatus{Started: now.Add(-30 * time.Second)}
+	report := srv.checkHealth(Status{Handlers: map[string]HandlerStatus{
+		"stale": stale, "dropping": dropping, "fresh": fresh,
+	}}, now, false)
+	assert.Equal(t, "failing", report.Status)
+	assert.Equal(t, []string{"no successful emission for 2m0s"}, report.Handlers["stale"].Problems)
+	assert.Equal(t, []string{"dropped 30% of the metrics"}, report.Handlers["dropping"].Problems)
+	assert.Equal(t, "ok", report.Handlers["fresh"].Status, "should be measured from the start")
+
+	everyFiveMinutes := func(t time.Time) time.Time {
+		return t.Truncate(5 * time.Minute).Add(5 * time.Minute)
+	}
+	collectors := map[string]CollectorStatus{
+		"late":      {Interval: 10, LastSuccess: now.Add(-31 * time.Second)},
+		"punctual":  {Interval: 10, LastSuccess: now.Add(-29 * time.Second)},
+		"listener":  {Interval: 10, Listener: true, Started: now.Add(-time.Hour)},
+		"scheduled": {Interval: 10, Schedule: everyFiveMinutes, LastSuccess: now.Add(-4 * time.Minute)},
+		"missed":    {Interval: 10, Schedule: everyFiveMinutes, LastSuccess: now.Add(-30 * time.Minute)},
+		"paused":    {Interval: 10, Paused: true, LastSuccess: now.Add(-time.Hour)},
+	}
+	report = srv.checkHealth(Status{Collectors: collectors}, now, false)
+	assert.Equal(t, "failing", report.Collectors["late"].Status)
+	assert.Equal(t, "ok", report.Collectors["punctual"].Status)
+	assert.Equal(t, "ok", report.Collectors["listener"].Status, "listeners do not run on a schedule")
+	assert.Equal(t, "ok", report.Collectors["scheduled"].Status, "should expect runs on the schedule")
+	assert.Equal(t, "failing", report.Collectors["missed"].Status)
+	assert.Equal(t, "ok", report.Collectors["paused"].Status, "paused collectors are not expected to run")
+}
+
+func TestReadiness(t *testing.T) {
+	notListening := false
+	srv := buildHealthServer(nil, Status{
+		Handlers: map[string]HandlerStatus{
+			"Graphite": {Health: handler.Health{BreakerState: "open"}},
+		},
+		Collectors: map[string]CollectorStatus{
+			"Diamond": {Listener: true, Listening: &notListening},
+		},
+	})
+
+	rsp := httptest.NewRecorder()
+	srv.handleReadyRequest(rsp, httptest.NewRequest("GET", "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rsp.Code)
+
+	var report healthReport
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &report))
+	assert.Equal(t, []string{"the circuit breaker is open"}, report.Handlers["Graphite"].Problems)
+	assert.Equal(t, []string{"not listening"}, report.Collectors["Diamond"].Problems)
+	assert.Len(t, report.Problems, 2)
+}
+
+func TestDropRateWindow(t *testing.T) {
+	tracker := newDropTracker(time.Minute)
+	start := time.Now()
+	status := func(sent, dropped uint64) map[string]HandlerStatus {
+		return map[string]HandlerStatus{
+			"Graphite": {Health: handler.Health{MetricsSent: sent, MetricsDropped: dropped}},
+		}
+	}
+
+	assert.Equal(t, 0.5, tracker.rates(start, status(50, 50))["Graphite"], "should be since the start")
+	assert.Equal(t, 0.5, tracker.rates(start.Add(30*time.Second), status(100, 100))["Graphite"])
+	assert.Equal(t, 0.25, tracker.rates(start.Add(time.Minute), status(200, 100))["Graphite"],
+		"should be since the first snapshot")
+	assert.Equal(t, 0.5, tracker.rates(start.Add(90*time.Second), status(5, 5))["Graphite"],
+		"should start over once the handler restarted")
+}
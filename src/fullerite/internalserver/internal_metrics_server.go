@@ -3,13 +3,18 @@ package internalserver
 import (
 	"fullerite/config"
 	"fullerite/metric"
+	"fullerite/util"
 
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	l "github.com/Sirupsen/logrus"
 )
@@ -17,20 +22,91 @@ import (
 const (
 	defaultPort        = 19090
 	defaultMetricsPath = "/metrics"
+	defaultReloadPath  = "/reload"
+
+	defaultPrometheusPath = "/prometheus"
+
+	defaultCardinalityPath = "/cardinality"
+	defaultCardinalityTopN = 10
 )
 
+// ConfigKeys are the keys of the "internalServer" part of the configuration
+var ConfigKeys = append([]config.Key{
+	{Name: "port", Type: config.TypeInt, Default: defaultPort, Description: "port to listen on"},
+	{Name: "path", Type: config.TypeString, Default: defaultMetricsPath, Description: "path of the internal metrics"},
+	{Name: "reloadPath", Type: config.TypeString, Default: defaultReloadPath, Description: "path that reloads the configuration, requires the control token if there is one"},
+	{Name: "cardinalityPath", Type: config.TypeString, Default: defaultCardinalityPath, Description: "path of the series counts"},
+	{Name: "prometheusPath", Type: config.TypeString, Default: defaultPrometheusPath,
+		Description: "path of the internal metrics in the Prometheus text format"},
+	{Name: "healthPath", Type: config.TypeString, Default: defaultHealthPath,
+		Description: "path that answers 503 once fullerite needs a restart"},
+	{Name: "readyPath", Type: config.TypeString, Default: defaultReadyPath,
+		Description: "path that answers 503 until fullerite can take metrics and get them out"},
+	{Name: "maxEmissionAge", Type: config.TypeInt, Default: defaultMaxEmissionAge,
+		Description: "seconds a handler may go without a successful emission before it fails the health check, 0 to never fail"},
+	{Name: "maxMissedCollections", Type: config.TypeInt, Default: defaultMaxMissedCollections,
+		Description: "scheduled runs a collector may miss before it fails the health check, 0 to never fail"},
+	{Name: "maxDropRate", Type: config.TypeFloat, Default: defaultMaxDropRate,
+		Description: "share of its metrics a handler may drop before it fails the health check"},
+	{Name: "dropRateWindow", Type: config.TypeInt, Default: defaultDropRateWindow,
+		Description: "seconds the drop rate is measured over"},
+	{Name: "controlPath", Type: config.TypeString, Default: defaultControlPath,
+		Description: "path of the API that pauses and runs collectors, flushes handlers and sets the log level"},
+	{Name: "controlToken", Type: config.TypeString,
+		Description: "bearer token the control API and the tap require, both are off without one"},
+	{Name: "tapPath", Type: config.TypeString, Default: defaultTapPath,
+		Description: "path that streams the metrics the handlers emit"},
+	{Name: "tapMaxRate", Type: config.TypeInt, Default: defaultTapMaxRate,
+		Description: "metrics per second a tap streams at most, the others are skipped"},
+	{Name: "debug", Type: config.TypeBool, Default: false,
+		Description: "serve runtime profiles and traces under /debug/pprof/ and goroutine dumps under /debug/goroutines, requires a controlToken"},
+	{Name: "authToken", Type: config.TypeString,
+		Description: "bearer token every request has to carry, unless it has a password or the control token"},
+	{Name: "authPasswords", Type: config.TypeMap,
+		Description: "users and their passwords for basic auth, every request has to carry one if set"},
+}, util.ListenerKeys...)
+
 // InternalServer will collect from each handler the status and return it over HTTP
 type InternalServer struct {
 	log               *l.Entry
 	handlerStatFunc   InternalStatFunc
 	collectorStatFunc InternalStatFunc
+	reloadFunc        ReloadFunc
+	cardinalityFunc   CardinalityFunc
+	statusFunc        StatusFunc
+	controller        Controller
+	listenerConfig    map[string]interface{}
 	port              int
 	path              string
+	reloadPath        string
+	cardinalityPath   string
+	prometheusPath    string
+	healthPath        string
+	readyPath         string
+	controlPath       string
+	controlToken      string
+	authToken         string
+	authPasswords     map[string]string
+	tapPath           string
+	tapMaxRate        int
+	debug             bool
+
+	// thresholds of the health checks
+	maxEmissionAge       time.Duration
+	maxMissedCollections int
+	maxDropRate          float64
+	dropRates            *dropTracker
 }
 
 // InternalStatFunc can be used to extract metrics
 type InternalStatFunc func() (stats map[string]metric.InternalMetrics)
 
+// ReloadFunc reloads the configuration of fullerite
+type ReloadFunc func() error
+
+// CardinalityFunc returns the n metric names with the most series
+type CardinalityFunc func(n int) []metric.SeriesCount
+
 // ResponseFormat is the structure of the response from an http request
 type ResponseFormat struct {
 	Memory     metric.InternalMetrics
@@ -48,24 +124,87 @@ func New(cfg config.Config, h InternalStatFunc, c InternalStatFunc) *InternalSer
 	return srv
 }
 
+// SetReloadFunc makes the server reload the configuration with the given
+// function when a POST request is made to the reload path. The request
+// requires the control token if there is one.
+func (srv *InternalServer) SetReloadFunc(f ReloadFunc) {
+	srv.reloadFunc = f
+}
+
+// SetCardinalityFunc makes the server report the metric names with the most
+// series on the cardinality path
+func (srv *InternalServer) SetCardinalityFunc(f CardinalityFunc) {
+	srv.cardinalityFunc = f
+}
+
 // Run starts a server on the specified port listening for the provided path
 func (srv *InternalServer) Run() {
 	srv.log.Info(fmt.Sprintf("Starting to run internal metrics server on port %d on path %s", srv.port, srv.path))
-	http.HandleFunc(srv.path, srv.handleInternalMetricsRequest)
 
-	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", srv.port))
+	// a server that was meant to be TLS does not fall back to plaintext
+	tlsConfig, err := util.ServerTLSConfig(srv.listenerConfig)
+	if err != nil {
+		srv.log.Error("Failed to start internal server, invalid TLS config: ", err)
+		return
+	}
+
+	ln, err := net.Listen("tcp", util.ListenAddress(srv.listenerConfig, strconv.Itoa(srv.port)))
 	if err != nil {
 		srv.log.Error("Failed to start internal server: ", err)
+		return
 	}
 
 	srv.port = ln.Addr().(*net.TCPAddr).Port // reset the port with the bind port number (would change if port 0 is used)
 
-	if http.Serve(ln, nil) != nil {
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	if err = http.Serve(ln, srv.authenticate(srv.mux())); err != nil {
 		srv.log.Error("Failed to start internal server: ", err)
 	}
 }
 
+// mux routes the paths of the server. It is a mux of its own rather than
+// the default one, net/http/pprof registers itself on the default one.
+func (srv *InternalServer) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(srv.path, srv.handleInternalMetricsRequest)
+	mux.HandleFunc(srv.prometheusPath, srv.handlePrometheusRequest)
+	if srv.controlToken == "" {
+		srv.log.Info("The tap is off, there is no controlToken")
+	} else {
+		mux.HandleFunc(srv.tapPath, srv.handleTapRequest)
+	}
+	if srv.reloadFunc != nil {
+		mux.HandleFunc(srv.reloadPath, srv.requireToken(srv.handleReloadRequest))
+	}
+	if srv.cardinalityFunc != nil {
+		mux.HandleFunc(srv.cardinalityPath, srv.handleCardinalityRequest)
+	}
+	if srv.statusFunc != nil {
+		mux.HandleFunc(srv.healthPath, srv.handleHealthRequest)
+		mux.HandleFunc(srv.readyPath, srv.handleReadyRequest)
+	}
+	if srv.controller != nil {
+		if srv.controlToken == "" {
+			srv.log.Info("The control API is off, there is no controlToken")
+		} else {
+			mux.HandleFunc(srv.controlPath+"/", srv.handleControlRequest)
+		}
+	}
+	if srv.debug {
+		if srv.controlToken == "" {
+			srv.log.Info("The debug endpoints are off, there is no controlToken")
+		} else {
+			srv.mountDebug(mux)
+		}
+	}
+	return mux
+}
+
 func (srv *InternalServer) configure(cfgMap map[string]interface{}) {
+	srv.listenerConfig = cfgMap
 
 	if val, exists := (cfgMap)["port"]; exists {
 		srv.port = config.GetAsInt(val, defaultPort)
@@ -78,6 +217,89 @@ func (srv *InternalServer) configure(cfgMap map[string]interface{}) {
 	} else {
 		srv.path = defaultMetricsPath
 	}
+
+	if val, exists := (cfgMap)["reloadPath"]; exists {
+		srv.reloadPath = val.(string)
+	} else {
+		srv.reloadPath = defaultReloadPath
+	}
+
+	if val, exists := (cfgMap)["cardinalityPath"]; exists {
+		srv.cardinalityPath = val.(string)
+	} else {
+		srv.cardinalityPath = defaultCardinalityPath
+	}
+
+	if val, exists := (cfgMap)["prometheusPath"]; exists {
+		srv.prometheusPath = val.(string)
+	} else {
+		srv.prometheusPath = defaultPrometheusPath
+	}
+
+	if val, exists := (cfgMap)["healthPath"]; exists {
+		srv.healthPath = val.(string)
+	} else {
+		srv.healthPath = defaultHealthPath
+	}
+
+	if val, exists := (cfgMap)["readyPath"]; exists {
+		srv.readyPath = val.(string)
+	} else {
+		srv.readyPath = defaultReadyPath
+	}
+
+	if val, exists := (cfgMap)["controlPath"]; exists {
+		srv.controlPath = strings.TrimSuffix(val.(string), "/")
+	} else {
+		srv.controlPath = defaultControlPath
+	}
+
+	if val, exists := (cfgMap)["controlToken"]; exists {
+		srv.controlToken = val.(string)
+	}
+
+	if val, exists := (cfgMap)["authToken"]; exists {
+		srv.authToken = val.(string)
+	}
+
+	if val, exists := (cfgMap)["authPasswords"]; exists {
+		srv.authPasswords = config.GetAsMap(val)
+	}
+
+	if val, exists := (cfgMap)["tapPath"]; exists {
+		srv.tapPath = val.(string)
+	} else {
+		srv.tapPath = defaultTapPath
+	}
+
+	srv.tapMaxRate = defaultTapMaxRate
+	if val, exists := (cfgMap)["tapMaxRate"]; exists {
+		srv.tapMaxRate = config.GetAsInt(val, defaultTapMaxRate)
+	}
+
+	srv.debug, _ = (cfgMap)["debug"].(bool)
+
+	maxEmissionAge := defaultMaxEmissionAge
+	if val, exists := (cfgMap)["maxEmissionAge"]; exists {
+		maxEmissionAge = config.GetAsInt(val, defaultMaxEmissionAge)
+	}
+	srv.maxEmissionAge = time.Duration(maxEmissionAge) * time.Second
+
+	srv.maxMissedCollections = defaultMaxMissedCollections
+	if val, exists := (cfgMap)["maxMissedCollections"]; exists {
+		srv.maxMissedCollections = config.GetAsInt(val, defaultMaxMissedCollections)
+	}
+
+	srv.maxDropRate = defaultMaxDropRate
+	if val, exists := (cfgMap)["maxDropRate"]; exists {
+		srv.maxDropRate = config.GetAsFloat(val, defaultMaxDropRate)
+	}
+
+	dropRateWindow := defaultDropRateWindow
+	if val, exists := (cfgMap)["dropRateWindow"]; exists {
+		dropRateWindow = config.GetAsInt(val, defaultDropRateWindow)
+	}
+	srv.dropRates = newDropTracker(time.Duration(dropRateWindow) * time.Second)
 }
 
 // this is what services the request. The response will be JSON formatted like this:
@@ -111,13 +333,59 @@ func (srv InternalServer) handleInternalMetricsRequest(writer http.ResponseWrite
 	io.WriteString(writer, rspString)
 }
 
+// handleReloadRequest reloads the configuration. Only POST is accepted since
+// a reload changes what fullerite runs, the response tells whether it worked:
+//	{"status": "ok"}
+//	{"status": "failed", "error": "..."}
+func (srv InternalServer) handleReloadRequest(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		writer.Header().Set("Allow", "POST")
+		http.Error(writer, "Only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rsp := map[string]string{"status": "ok"}
+	if err := srv.reloadFunc(); err != nil {
+		rsp["status"] = "failed"
+		rsp["error"] = err.Error()
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+
+	asString, _ := json.Marshal(rsp)
+	writer.Write(asString)
+}
+
+// handleCardinalityRequest lists the metric names with the most series, the
+// number of names is taken from the "n" parameter:
+//	[
+//		{
+//			"collector": "ProcStatus",
+//			"name": "VmRSS",
+//			"series": 1000,
+//			"dimensions": {"pid": 1000, "processName": 12}
+//		}
+//	]
+func (srv InternalServer) handleCardinalityRequest(writer http.ResponseWriter, req *http.Request) {
+	n := defaultCardinalityTopN
+	if val := req.URL.Query().Get("n"); val != "" {
+		parsed, err := strconv.Atoi(val)
+		if err != nil || parsed < 0 {
+			http.Error(writer, "n must be a positive number", http.StatusBadRequest)
+			return
+		}
+		n = parsed
+	}
+
+	asString, err := json.Marshal(srv.cardinalityFunc(n))
+	if err != nil {
+		srv.log.Warn("Failed to marshal the cardinality because of error ", err)
+	}
+	writer.Write(asString)
+}
+
 // responsible for querying each handler and serializing the total response
 func (srv InternalServer) buildResponse() *[]byte {
-	memoryStats := getMemoryStats()
-	rsp := ResponseFormat{}
-	rsp.Memory = *memoryStats
-	rsp.Handlers = srv.handlerStatFunc()
-	rsp.Collectors = srv.collectorStatFunc()
+	rsp := srv.collectStats()
 	asString, err := json.Marshal(rsp)
 	if err != nil {
 		srv.log.Warn("Failed to marshal response ", rsp, " because of error ", err)
@@ -126,6 +394,16 @@ func (srv InternalServer) buildResponse() *[]byte {
 	return &asString
 }
 
+// collectStats queries the memory, handler and collector stats
+func (srv InternalServer) collectStats() ResponseFormat {
+	memoryStats := getMemoryStats()
+	rsp := ResponseFormat{}
+	rsp.Memory = *memoryStats
+	rsp.Handlers = srv.handlerStatFunc()
+	rsp.Collectors = srv.collectorStatFunc()
+	return rsp
+}
+
 // gets the actual memory stats
 func memoryStats() *runtime.MemStats {
 	stats := new(runtime.MemStats)
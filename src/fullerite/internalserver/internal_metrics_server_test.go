@@ -6,14 +6,17 @@ import (
 	"fullerite/metric"
 
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type testHandler struct {
@@ -22,12 +25,12 @@ type testHandler struct {
 	name    string
 }
 
-func (h testHandler) Run()                             {} // noop
-func (h testHandler) Configure(map[string]interface{}) {} // noop
-func (h testHandler) InternalMetrics() metric.InternalMetrics {
+func (h *testHandler) Run()                             {} // noop
+func (h *testHandler) Configure(map[string]interface{}) {} // noop
+func (h *testHandler) InternalMetrics() metric.InternalMetrics {
 	return h.metrics
 }
-func (h testHandler) Name() string {
+func (h *testHandler) Name() string {
 	return h.name
 }
 
@@ -175,3 +178,91 @@ func TestRespondToHttp(t *testing.T) {
 	assert.Equal(t, 456.2, handlerMetrics.Counters["secondcounter"])
 	assert.Equal(t, 890.2, handlerMetrics.Gauges["secondgauge"])
 }
+
+func TestReloadRequest(t *testing.T) {
+	reloads := 0
+	srv := InternalServer{
+		log: l.WithField("testing", "internal_server"),
+		reloadFunc: func() error {
+			reloads++
+			return nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleReloadRequest(rec, httptest.NewRequest("GET", "/reload", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, 0, reloads, "should not reload on GET")
+
+	rec = httptest.NewRecorder()
+	srv.handleReloadRequest(rec, httptest.NewRequest("POST", "/reload", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, reloads)
+	assert.JSONEq(t, `{"status": "ok"}`, rec.Body.String())
+}
+
+func TestReloadRequestRequiresToken(t *testing.T) {
+	reloads := 0
+	srv := New(config.Config{InternalServerConfig: map[string]interface{}{"controlToken": "s3cr3t"}}, nil, nil)
+	srv.SetReloadFunc(func() error {
+		reloads++
+		return nil
+	})
+	server := httptest.NewServer(srv.mux())
+	defer server.Close()
+
+	rsp, err := http.Post(server.URL+"/reload", "", nil)
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+	assert.Equal(t, 0, reloads, "should not reload without the token")
+
+	req, _ := http.NewRequest("POST", server.URL+"/reload", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	rsp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, 1, reloads)
+}
+
+func TestReloadRequestFailure(t *testing.T) {
+	srv := InternalServer{
+		log: l.WithField("testing", "internal_server"),
+		reloadFunc: func() error {
+			return errors.New("broken config")
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleReloadRequest(rec, httptest.NewRequest("POST", "/reload", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"status": "failed", "error": "broken config"}`, rec.Body.String())
+}
+
+func TestCardinalityRequest(t *testing.T) {
+	requested := -1
+	srv := InternalServer{
+		log: l.WithField("testing", "internal_server"),
+		cardinalityFunc: func(n int) []metric.SeriesCount {
+			requested = n
+			return []metric.SeriesCount{
+				{Collector: "Test", Name: "rss", Series: 3, Dimensions: map[string]int{"pid": 3}},
+			}
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleCardinalityRequest(rec, httptest.NewRequest("GET", "/cardinality", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, defaultCardinalityTopN, requested)
+	assert.JSONEq(t, `[{"collector": "Test", "name": "rss", "series": 3, "dimensions": {"pid": 3}}]`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	srv.handleCardinalityRequest(rec, httptest.NewRequest("GET", "/cardinality?n=5", nil))
+	assert.Equal(t, 5, requested)
+
+	rec = httptest.NewRecorder()
+	srv.handleCardinalityRequest(rec, httptest.NewRequest("GET", "/cardinality?n=many", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,137 @@
+package internalserver
+
+import (
+	"fullerite/metric"
+
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// prometheusContentType is the version of the text format that is served
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var invalidPrometheusChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// prometheusFamily holds the samples of one metric name, the text format
+// wants them together under a single TYPE line
+type prometheusFamily struct {
+	metricType string
+	samples    []prometheusSample
+}
+
+type prometheusSample struct {
+	labels string
+	value  float64
+}
+
+// handlePrometheusRequest serves the same stats as the metrics path in the
+// Prometheus text format. The names are snake cased and prefixed with
+// fullerite and the kind of stat, the handler or collector is a label:
+//
+//	# TYPE fullerite_handler_metrics_sent counter
+//	fullerite_handler_metrics_sent{handler="Graphite"} 1234
+func (srv InternalServer) handlePrometheusRequest(writer http.ResponseWriter, req *http.Request) {
+	writer.Header().Set("Content-Type", prometheusContentType)
+	writePrometheus(writer, srv.collectStats())
+}
+
+// writePrometheus writes the stats in the Prometheus text format, sorted by
+// name and label
+func writePrometheus(w io.Writer, rsp ResponseFormat) {
+	families := make(map[string]*prometheusFamily)
+	addPrometheusStats(families, "memory", "", "", rsp.Memory)
+	for name, stats := range rsp.Handlers {
+		addPrometheusStats(families, "handler", "handler", name, stats)
+	}
+	for name, stats := range rsp.Collectors {
+		addPrometheusStats(families, "collector", "collector", name, stats)
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		family := families[name]
+		sort.Sort(byLabels(family.samples))
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, family.metricType)
+		for _, sample := range family.samples {
+			fmt.Fprintf(&buf, "%s%s %s\n", name, sample.labels, formatPrometheusValue(sample.value))
+		}
+	}
+	w.Write(buf.Bytes())
+}
+
+func addPrometheusStats(families map[string]*prometheusFamily, kind, label, value string, stats metric.InternalMetrics) {
+	labels := ""
+	if label != "" {
+		labels = fmt.Sprintf("{%s=\"%s\"}", label, escapePrometheusLabel(value))
+	}
+	add := func(name string, metricType string, v float64) {
+		name = prometheusName(kind, name)
+		family, exists := families[name]
+		if !exists {
+			family = &prometheusFamily{metricType: metricType}
+			families[name] = family
+		}
+		family.samples = append(family.samples, prometheusSample{labels: labels, value: v})
+	}
+	for name, v := range stats.Counters {
+		add(name, "counter", v)
+	}
+	for name, v := range stats.Gauges {
+		add(name, "gauge", v)
+	}
+}
+
+// prometheusName turns the name of a stat into a valid Prometheus name:
+// "metricsSent" of a handler becomes "fullerite_handler_metrics_sent".
+// Names that already start with fullerite only have their invalid
+// characters replaced.
+func prometheusName(kind, name string) string {
+	var snake bytes.Buffer
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// "NumGC" becomes "num_gc" rather than "num_g_c"
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				snake.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		snake.WriteRune(r)
+	}
+
+	sanitized := invalidPrometheusChars.ReplaceAllString(snake.String(), "_")
+	if strings.HasPrefix(sanitized, "fullerite_") {
+		return sanitized
+	}
+	return "fullerite_" + kind + "_" + sanitized
+}
+
+func escapePrometheusLabel(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
+
+// formatPrometheusValue writes NaN and infinities the way Prometheus reads them
+func formatPrometheusValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type byLabels []prometheusSample
+
+func (b byLabels) Len() int           { return len(b) }
+func (b byLabels) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byLabels) Less(i, j int) bool { return b[i].labels < b[j].labels }
@@ -0,0 +1,84 @@
+package internalserver
+
+import (
+	"fullerite/handler"
+	"fullerite/metric"
+
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusName(t *testing.T) {
+	names := map[string]string{
+		"NumGC":                         "fullerite_memory_num_gc",
+		"MSpanInuse":                    "fullerite_memory_m_span_inuse",
+		"metricsSent":                   "fullerite_memory_metrics_sent",
+		"fullerite.collector_runs":      "fullerite_collector_runs",
+		"emissions-per-second.p99":      "fullerite_memory_emissions_per_second_p99",
+		"fullerite.collector_last_succ": "fullerite_collector_last_succ",
+	}
+	for name, expected := range names {
+		assert.Equal(t, expected, prometheusName("memory", name), name)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	memory := metric.NewInternalMetrics()
+	memory.Counters["NumGC"] = 3
+	memory.Gauges["HeapAlloc"] = 1024
+
+	graphite := metric.NewInternalMetrics()
+	graphite.Counters["metricsSent"] = 10
+	graphite.Gauges["averageEmissionTiming"] = 0.25
+	kairos := metric.NewInternalMetrics()
+	kairos.Counters["metricsSent"] = 20
+	kairos.Gauges["averageEmissionTiming"] = math.NaN()
+
+	collector := metric.NewInternalMetrics()
+	collector.Counters["fullerite.collector_runs"] = 7
+
+	var out bytes.Buffer
+	writePrometheus(&out, ResponseFormat{
+		Memory:     *memory,
+		Handlers:   map[string]metric.InternalMetrics{"Kairos": *kairos, "Graphite": *graphite},
+		Collectors: map[string]metric.InternalMetrics{`Proc"Status`: *collector},
+	})
+
+	assert.Equal(t, strings.Join([]string{
+		`# TYPE fullerite_collector_runs counter`,
+		`fullerite_collector_runs{collector="Proc\"Status"} 7`,
+		`# TYPE fullerite_handler_average_emission_timing gauge`,
+		`fullerite_handler_average_emission_timing{handler="Graphite"} 0.25`,
+		`fullerite_handler_average_emission_timing{handler="Kairos"} NaN`,
+		`# TYPE fullerite_handler_metrics_sent counter`,
+		`fullerite_handler_metrics_sent{handler="Graphite"} 10`,
+		`fullerite_handler_metrics_sent{handler="Kairos"} 20`,
+		`# TYPE fullerite_memory_heap_alloc gauge`,
+		`fullerite_memory_heap_alloc 1024`,
+		`# TYPE fullerite_memory_num_gc counter`,
+		`fullerite_memory_num_gc 3`,
+	}, "\n")+"\n", out.String())
+}
+
+func TestPrometheusRequest(t *testing.T) {
+	h := buildTestHandler("Graphite", map[string]float64{"metricsSent": 12}, nil)
+	srv := InternalServer{
+		log:               l.WithField("testing", "internal_server"),
+		handlerStatFunc:   handlerStatFunc([]handler.Handler{h}),
+		collectorStatFunc: collectorStatFunc,
+	}
+
+	rsp := httptest.NewRecorder()
+	srv.handlePrometheusRequest(rsp, httptest.NewRequest("GET", "/prometheus", nil))
+
+	assert.Equal(t, prometheusContentType, rsp.Header().Get("Content-Type"))
+	assert.Contains(t, rsp.Body.String(), "# TYPE fullerite_handler_metrics_sent counter\n")
+	assert.Contains(t, rsp.Body.String(), `fullerite_handler_metrics_sent{handler="Graphite"} 12`)
+	assert.Contains(t, rsp.Body.String(), "# TYPE fullerite_memory_num_goroutine counter\n")
+}
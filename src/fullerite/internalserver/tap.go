@@ -0,0 +1,159 @@
+package internalserver
+
+import (
+	"fullerite/handler"
+
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTapPath    = "/tap"
+	defaultTapMaxRate = 100 // metrics per second and connection
+
+	// metrics a tap buffers for a slow client before it skips them
+	tapBufferSize = 1000
+)
+
+// handleTapRequest streams the metrics the handlers emit for as long as the
+// client stays connected. The query selects them:
+//
+//	handler=Graphite        emitted by one handler
+//	collector=ProcStatus    collected by one collector
+//	name=^cpu\.             names matching a regular expression
+//	dimension=host:web1     with a dimension, may be given more than once
+//	rate=10                 at most this many per second, up to tapMaxRate
+//
+// Clients that accept text/event-stream get Server-Sent Events, the others
+// a JSON object per line. Metrics over the rate or that the client is too
+// slow for are skipped, and the number skipped is sent once a second:
+//
+//	{"handler": "Graphite", "metric": {"name": "cpu.user", ...}}
+//	{"skipped": 12}
+//
+// The tap requires the control token, it is off without one.
+func (srv *InternalServer) handleTapRequest(writer http.ResponseWriter, req *http.Request) {
+	if srv.controlToken == "" || !srv.authorized(req) {
+		writer.Header().Set("WWW-Authenticate", `Bearer realm="fullerite"`)
+		http.Error(writer, "Missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	filter, rate, err := srv.parseTapQuery(req.URL.Query())
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+	if events {
+		writer.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		writer.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+
+	tap := handler.Tap(filter, tapBufferSize)
+	defer handler.Untap(tap)
+	srv.log.Info("Tap opened by ", req.RemoteAddr, " for ", req.URL.RawQuery)
+	defer srv.log.Info("Tap closed by ", req.RemoteAddr)
+	flusher.Flush()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	sent := 0
+	limited := uint64(0)
+	dropped := uint64(0)
+	for {
+		var err error
+		select {
+		case <-req.Context().Done():
+			return
+		case m := <-tap.Metrics():
+			if sent >= rate {
+				limited++
+				continue
+			}
+			sent++
+			err = writeTapEvent(writer, events, "metric", m)
+		case <-ticker.C:
+			skipped := limited + tap.Dropped() - dropped
+			sent, limited, dropped = 0, 0, tap.Dropped()
+			if skipped == 0 {
+				continue
+			}
+			err = writeTapEvent(writer, events, "skipped", map[string]uint64{"skipped": skipped})
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// parseTapQuery returns the filter and the rate a tap was asked for
+func (srv *InternalServer) parseTapQuery(query url.Values) (handler.TapFilter, int, error) {
+	filter := handler.TapFilter{
+		Handler:   query.Get("handler"),
+		Collector: query.Get("collector"),
+	}
+
+	if name := query.Get("name"); name != "" {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			return filter, 0, fmt.Errorf("invalid name: %s", err)
+		}
+		filter.Name = re
+	}
+
+	for _, dimension := range query["dimension"] {
+		parts := strings.SplitN(dimension, ":", 2)
+		if len(parts) != 2 {
+			return filter, 0, fmt.Errorf("dimension must be key:value, got %q", dimension)
+		}
+		if filter.Dimensions == nil {
+			filter.Dimensions = make(map[string]string)
+		}
+		filter.Dimensions[parts[0]] = parts[1]
+	}
+
+	rate := srv.tapMaxRate
+	if val := query.Get("rate"); val != "" {
+		parsed, err := strconv.Atoi(val)
+		if err != nil || parsed <= 0 {
+			return filter, 0, fmt.Errorf("rate must be a positive number")
+		}
+		if parsed < rate {
+			rate = parsed
+		}
+	}
+	return filter, rate, nil
+}
+
+func writeTapEvent(w io.Writer, events bool, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if events {
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	} else {
+		_, err = fmt.Fprintf(w, "%s\n", data)
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package internalserver
+
+import (
+	"fullerite/config"
+	"fullerite/handler"
+	"fullerite/metric"
+
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTapQuery(t *testing.T) {
+	srv := New(config.Config{InternalServerConfig: map[string]interface{}{"tapMaxRate": 50}}, nil, nil)
+
+	query, _ := url.ParseQuery("handler=Graphite&name=^cpu&dimension=host:web1&dimension=core:0&rate=500")
+	filter, rate, err := srv.parseTapQuery(query)
+	require.Nil(t, err)
+	assert.Equal(t, "Graphite", filter.Handler)
+	assert.Equal(t, "^cpu", filter.Name.String())
+	assert.Equal(t, map[string]string{"host": "web1", "core": "0"}, filter.Dimensions)
+	assert.Equal(t, 50, rate, "should not go over the maximum rate")
+
+	for _, invalid := range []string{"name=(", "dimension=host", "rate=0", "rate=x"} {
+		query, _ = url.ParseQuery(invalid)
+		_, _, err = srv.parseTapQuery(query)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestTapRequiresControlToken(t *testing.T) {
+	srv := New(config.Config{}, nil, nil)
+	server := httptest.NewServer(srv.mux())
+	defer server.Close()
+
+	rsp, err := http.Get(server.URL + "/tap")
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode, "should be off without a controlToken")
+}
+
+func TestTapStream(t *testing.T) {
+	srv := New(config.Config{InternalServerConfig: map[string]interface{}{"controlToken": "s3cr3t"}}, nil, nil)
+	server := httptest.NewServer(http.HandlerFunc(srv.handleTapRequest))
+	defer server.Close()
+
+	rsp, err := http.Get(server.URL + "/tap")
+	require.Nil(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+
+	req, _ := http.NewRequest("GET", server.URL+"/tap?collector=Tapped&rate=1", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	rsp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer rsp.Body.Close()
+	assert.Equal(t, "application/x-ndjson", rsp.Header.Get("Content-Type"))
+
+	h := handler.NewTest(make(chan metric.Metric), 60, 10, time.Second, l.WithField("testing", "tap"))
+	h.Run()
+	defer h.Stop()
+	for _, name := range []string{"first", "second"} {
+		m := metric.New(name)
+		m.AddDimension("collector", "Tapped")
+		h.Channel() <- m
+	}
+	h.Channel() <- metric.New("untapped")
+	h.Flush()
+
+	lines := bufio.NewScanner(rsp.Body)
+	require.True(t, lines.Scan())
+	var tapped handler.TappedMetric
+	require.Nil(t, json.Unmarshal(lines.Bytes(), &tapped))
+	assert.Equal(t, "Test", tapped.Handler)
+	assert.Equal(t, "first", tapped.Metric.Name)
+
+	require.True(t, lines.Scan())
+	assert.Equal(t, `{"skipped":1}`, strings.TrimSpace(lines.Text()), "should skip what is over the rate")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fullerite/collector"
+	"fullerite/config"
+	"fullerite/handler"
+	"fullerite/internalserver"
+	"fullerite/metric"
+
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+)
+
+// component is what a collector or handler says about itself
+type component struct {
+	kind        string
+	name        string
+	description string
+	keys        []config.Key
+	metrics     []metric.Info
+}
+
+func collectorComponent(name string) component {
+	inst := collector.New(name)
+	return component{
+		kind:        "collector",
+		name:        name,
+		description: inst.Description(),
+		keys:        append(inst.ConfigKeys(), scheduleKeys...),
+		metrics:     inst.Metrics(),
+	}
+}
+
+func handlerComponent(name string) component {
+	inst := handler.New(name)
+	return component{
+		kind:        "handler",
+		name:        name,
+		description: inst.Description(),
+		keys:        inst.ConfigKeys(),
+	}
+}
+
+// components returns the registered collectors and handlers, sorted by name
+func components() []component {
+	var all []component
+	for _, name := range collector.Names() {
+		all = append(all, collectorComponent(name))
+	}
+	for _, name := range handler.Names() {
+		all = append(all, handlerComponent(name))
+	}
+	return all
+}
+
+// findComponents returns the collectors and handlers with the given names
+func findComponents(names []string) ([]component, error) {
+	var found []component
+	for _, name := range names {
+		matched := false
+		if isRegistered(name, collector.Names()) {
+			found = append(found, collectorComponent(name))
+			matched = true
+		}
+		if isRegistered(name, handler.Names()) {
+			found = append(found, handlerComponent(name))
+			matched = true
+		}
+		if !matched {
+			return nil, fmt.Errorf("%s is neither a collector nor a handler, see 'fullerite list'", name)
+		}
+	}
+	return found, nil
+}
+
+func list(ctx *cli.Context) {
+	initLogrus(ctx)
+	// keep stdout for the documentation
+	logrus.SetOutput(os.Stderr)
+
+	all := components()
+	if len(ctx.Args()) > 0 {
+		var err error
+		if all, err = findComponents(ctx.Args()); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+	}
+
+	switch {
+	case ctx.Bool("markdown"):
+		writeMarkdown(os.Stdout, all, len(ctx.Args()) == 0)
+	case len(ctx.Args()) > 0:
+		for i, c := range all {
+			if i > 0 {
+				fmt.Println()
+			}
+			writeComponent(os.Stdout, c)
+		}
+	default:
+		writeComponentList(os.Stdout, all)
+	}
+}
+
+// writeComponentList prints a line for each collector and handler
+func writeComponentList(w io.Writer, all []component) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tKIND\tDESCRIPTION")
+	for _, c := range all {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", c.name, c.kind, c.description)
+	}
+	table.Flush()
+}
+
+// writeComponent prints everything a collector or handler says about itself
+func writeComponent(w io.Writer, c component) {
+	fmt.Fprintf(w, "%s (%s): %s\n\n", c.name, c.kind, c.description)
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "KEY\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, key := range c.keys {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", key.Name, key.Type, formatDefault(key), key.Description)
+	}
+	table.Flush()
+
+	if len(c.metrics) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	table = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "METRIC\tTYPE\tDESCRIPTION")
+	for _, m := range c.metrics {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", m.Name, m.MetricType, m.Description)
+	}
+	table.Flush()
+}
+
+// writeMarkdown prints the documentation of the collectors and handlers,
+// and of the global configuration if all of them are documented
+func writeMarkdown(w io.Writer, all []component, withGlobal bool) {
+	if withGlobal {
+		fmt.Fprintln(w, "# Configuration reference")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Generated by `fullerite list --markdown`, do not edit.")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "## fullerite.conf")
+		fmt.Fprintln(w)
+		writeMarkdownKeys(w, config.GlobalKeys)
+		fmt.Fprintln(w, "### internalServer")
+		fmt.Fprintln(w)
+		writeMarkdownKeys(w, internalserver.ConfigKeys)
+	}
+
+	kind := ""
+	for _, c := range all {
+		if c.kind != kind {
+			kind = c.kind
+			fmt.Fprintf(w, "## %ss\n\n", strings.Title(kind))
+		}
+		fmt.Fprintf(w, "### %s\n\n%s\n\n", c.name, c.description)
+		writeMarkdownKeys(w, c.keys)
+		if len(c.metrics) > 0 {
+			fmt.Fprintln(w, "| Metric | Type | Description |")
+			fmt.Fprintln(w, "| --- | --- | --- |")
+			for _, m := range c.metrics {
+				fmt.Fprintf(w, "| `%s` | %s | %s |\n", m.Name, m.MetricType, m.Description)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func writeMarkdownKeys(w io.Writer, keys []config.Key) {
+	fmt.Fprintln(w, "| Key | Type | Default | Description |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- |")
+	for _, key := range keys {
+		defaultValue := formatDefault(key)
+		if key.Default != nil && !key.Required {
+			defaultValue = "`" + defaultValue + "`"
+		}
+		fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", key.Name, key.Type, defaultValue, key.Description)
+	}
+	fmt.Fprintln(w)
+}
+
+// formatDefault shows the default of a key as JSON, keys without a default
+// are either required or unset
+func formatDefault(key config.Key) string {
+	if key.Required {
+		return "required"
+	}
+	if key.Default == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(key.Default)
+	if err != nil {
+		return fmt.Sprint(key.Default)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindComponents(t *testing.T) {
+	found, err := findComponents([]string{"CPUInfo", "Graphite"})
+	require.Nil(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, "collector", found[0].kind)
+	assert.Equal(t, "handler", found[1].kind)
+
+	_, err = findComponents([]string{"Graphit"})
+	assert.NotNil(t, err)
+
+	_, err = findComponents([]string{"Diamondz"})
+	assert.EqualError(t, err, "Diamondz is neither a collector nor a handler, see 'fullerite list'")
+}
+
+func TestWriteComponent(t *testing.T) {
+	found, err := findComponents([]string{"CPUInfo"})
+	require.Nil(t, err)
+
+	var out bytes.Buffer
+	writeComponent(&out, found[0])
+	assert.Contains(t, out.String(), "CPUInfo (collector): Emits the number of physical CPUs")
+	assert.Regexp(t, `procPath +string +"/proc/cpuinfo" +file to read`, out.String())
+	assert.Regexp(t, `splay +int +0`, out.String(), "should list the schedule keys")
+	assert.Regexp(t, `cpu_info +gauge`, out.String())
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var out bytes.Buffer
+	writeMarkdown(&out, components(), true)
+	doc := out.String()
+
+	assert.Contains(t, doc, "## fullerite.conf")
+	assert.Contains(t, doc, "### internalServer")
+	assert.Contains(t, doc, "## Collectors")
+	assert.Contains(t, doc, "## Handlers")
+	assert.Contains(t, doc, "| `apiKey` | string | required | Datadog API key |")
+	assert.Contains(t, doc, "| `overflowPolicy` | string | `\"block\"` |")
+	assert.Contains(t, doc, "| `mysql.binlog_growth_rate` | cumcounter |")
+}
+
+// docs/configuration.md is what make docs generates, regenerate it when
+// this fails
+func TestConfigurationDocsUpToDate(t *testing.T) {
+	var out bytes.Buffer
+	writeMarkdown(&out, components(), true)
+
+	doc, err := ioutil.ReadFile("../../docs/configuration.md")
+	require.Nil(t, err)
+	assert.Equal(t, out.String(), string(doc), "docs/configuration.md is out of date, run make docs")
+}
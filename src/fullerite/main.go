@@ -2,12 +2,15 @@ package main
 
 import (
 	"fullerite/config"
-	"fullerite/handler"
 	"fullerite/internalserver"
 	"fullerite/metric"
+	"fullerite/processor"
+	"fullerite/stats"
 
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -24,10 +27,12 @@ const (
 var log = logrus.WithFields(logrus.Fields{"app": "fullerite"})
 
 func initLogrus(ctx *cli.Context) {
-	logrus.SetFormatter(&logrus.TextFormatter{
-		DisableColors:   true,
-		TimestampFormat: time.RFC822,
-		FullTimestamp:   true,
+	logrus.SetFormatter(config.RedactingFormatter{
+		Formatter: &logrus.TextFormatter{
+			DisableColors:   true,
+			TimestampFormat: time.RFC822,
+			FullTimestamp:   true,
+		},
 	})
 
 	if level, err := logrus.ParseLevel(ctx.String("log_level")); err == nil {
@@ -49,7 +54,7 @@ func main() {
 		cli.StringFlag{
 			Name:  "config, c",
 			Value: "/etc/fullerite.conf",
-			Usage: "JSON formatted configuration file",
+			Usage: "configuration file, YAML (.yaml, .yml), TOML (.toml) or else JSON",
 		},
 		cli.StringFlag{
 			Name:  "log_level, l",
@@ -76,6 +81,37 @@ func main() {
 		},
 	}
 	commandFlags = append(commandFlags, app.Flags...)
+	checkConfigFlags := []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dump",
+			Usage: "Print the configuration with secrets redacted",
+		},
+	}
+	checkConfigFlags = append(checkConfigFlags, app.Flags...)
+	collectOnceFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "collector-config, f",
+			Usage: "Configuration file of the collector, instead of the one in collectorsConfigPath",
+		},
+		cli.IntFlag{
+			Name:  "times, n",
+			Value: 1,
+			Usage: "How many times to run the collector, one interval apart",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: formatTable,
+			Usage: "Output format (json, graphite, table)",
+		},
+	}
+	collectOnceFlags = append(collectOnceFlags, app.Flags...)
+	listFlags := []cli.Flag{
+		cli.BoolFlag{
+			Name:  "markdown",
+			Usage: "Print the documentation as Markdown",
+		},
+	}
+	listFlags = append(listFlags, app.Flags...)
 	app.Commands = []cli.Command{
 		{
 			Name:    "visualize",
@@ -94,6 +130,40 @@ func main() {
 				"NOTE: Make sure you flush out all your metrics either as a list OR individually separated\n" +
 				"with a newline '\\n'otherwise your metrics will not be parsed and will be IGNORED\n",
 		},
+		{
+			Name:   "check-config",
+			Action: checkConfig,
+			Flags:  checkConfigFlags,
+			Usage:  "validate the configuration without starting fullerite",
+			UsageText: "Reads the configuration and the configs of the collectors it lists,\n" +
+				"checks the names of the collectors and handlers and the keys and values\n" +
+				"of their configs. Prints every problem found and exits with status 1\n" +
+				"if there are any, so that configuration changes can be checked before\n" +
+				"they are rolled out. With --dump it prints the configuration first,\n" +
+				"with the included files merged and secrets redacted.\n",
+		},
+		{
+			Name:   "collect-once",
+			Action: collectOnceCommand,
+			Flags:  collectOnceFlags,
+			Usage:  "run a single collector and print its metrics",
+			UsageText: "fullerite collect-once [command options] <collector>\n\n" +
+				"Runs the collector once, or --times times for collectors that report\n" +
+				"counters, and prints the metrics it collected instead of sending them\n" +
+				"to the handlers. The names and dimensions are the ones the handlers\n" +
+				"would see, with the prefix applied and blacklisted metrics removed.\n",
+		},
+		{
+			Name:   "list",
+			Action: list,
+			Flags:  listFlags,
+			Usage:  "list and describe the collectors and handlers",
+			UsageText: "fullerite list [command options] [collector or handler...]\n\n" +
+				"Lists the collectors and handlers fullerite knows. Given names, it\n" +
+				"describes them: the keys of their configs with types and defaults,\n" +
+				"and the metrics the collectors emit. With --markdown it prints this\n" +
+				"as documentation, see docs/configuration.md.\n",
+		},
 	}
 	app.Run(os.Args)
 }
@@ -109,64 +179,52 @@ func start(ctx *cli.Context) {
 		p := profile.Start(&pcfg)
 		defer p.Stop()
 	}
-	quit := make(chan bool)
 	initLogrus(ctx)
 	log.Info("Starting fullerite...")
 
-	c, err := config.ReadConfig(ctx.String("config"))
-	if err != nil {
+	a := newAgent(ctx.String("config"))
+	if err := a.start(); err != nil {
 		return
 	}
-	collectors := startCollectors(c)
-	handlers := startHandlers(c)
-	collectorStatChan := make(chan metric.CollectorEmission)
 
-	internalServer := internalserver.New(c,
-		handlerStatFunc(handlers),
-		readCollectorStat(collectorStatChan))
+	internalServer := internalserver.New(a.config,
+		handlerStatFunc(a.handlerSet),
+		stats.Default.InternalMetrics)
+	internalServer.SetReloadFunc(a.reload)
+	internalServer.SetCardinalityFunc(a.handlerSet.limiter.topN)
+	internalServer.SetStatusFunc(a.status)
+	internalServer.SetController(a)
 	go internalServer.Run()
 
-	readFromCollectors(collectors, handlers, collectorStatChan)
-
-	hook := NewLogErrorHook(handlers)
+	hook := NewLogErrorHook(a.handlerSet)
 	log.Logger.Hooks.Add(hook)
 
-	<-quit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			a.reload()
+			continue
+		}
+		log.Info("Received ", sig, ", shutting down fullerite...")
+		break
+	}
+
+	if a.shutdown() {
+		log.Info("Shut down fullerite")
+	}
 }
 
-func handlerStatFunc(handlers []handler.Handler) internalserver.InternalStatFunc {
+func handlerStatFunc(handlers *handlerSet) internalserver.InternalStatFunc {
 	return func() map[string]metric.InternalMetrics {
 		stats := map[string]metric.InternalMetrics{}
-		for _, inst := range handlers {
+		for _, inst := range handlers.Handlers() {
 			stats[inst.Name()] = inst.InternalMetrics()
 		}
 		return stats
 	}
 }
 
-func readCollectorStat(collectorStatChan <-chan metric.CollectorEmission) internalserver.InternalStatFunc {
-	collectorMetrics := map[string]uint64{}
-	go func() {
-		for collectorMetric := range collectorStatChan {
-			collectorMetrics[collectorMetric.Name] = collectorMetric.EmissionCount
-		}
-	}()
-	return func() map[string]metric.InternalMetrics {
-		metricStats := map[string]metric.InternalMetrics{}
-		for k, v := range collectorMetrics {
-			counters := map[string]float64{"fullerite.collector_datapoints": float64(v)}
-			gauges := map[string]float64{}
-
-			m := metric.InternalMetrics{
-				Counters: counters,
-				Gauges:   gauges,
-			}
-			metricStats[k] = m
-		}
-		return metricStats
-	}
-}
-
 func visualize(ctx *cli.Context) {
 	initLogrus(ctx)
 	log.Info("Visualizing fullerite...")
@@ -194,7 +252,12 @@ func visualize(ctx *cli.Context) {
 	handlers := startHandlers(c)
 
 	// Read the metrics from the AdHoc collector
-	go readFromCollector(collector, handlers)
+	processors, err := processor.NewChain(c.Processors)
+	if err != nil {
+		log.Error("Ignoring the global processors: ", err)
+		processors = nil
+	}
+	go readFromCollector(collector, newHandlerSet(handlers, processors), nil)
 
 	// Stop collecting after `die-after` duration expires
 	quitChannel := make(chan bool, 1)
@@ -14,8 +14,11 @@ func NewInternalMetrics() *InternalMetrics {
 	return inst
 }
 
-// CollectorEmission counts collector emissions
-type CollectorEmission struct {
-	Name          string
-	EmissionCount uint64
+// SeriesCount is the number of distinct series of a metric name, along with
+// the number of distinct values of each of its dimensions
+type SeriesCount struct {
+	Collector  string         `json:"collector"`
+	Name       string         `json:"name"`
+	Series     int            `json:"series"`
+	Dimensions map[string]int `json:"dimensions"`
 }
@@ -1,5 +1,12 @@
 package metric
 
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
 // The different types of metrics that are supported
 const (
 	Gauge             = "gauge"
@@ -9,15 +16,39 @@ const (
 
 // Metric type holds all the information for a single metric data
 // point. Metrics are generated in collectors and passed to handlers.
+//
+// Timestamp is the time the point was collected. Collectors that know
+// better (e.g. Diamond or AdHoc) can set it, otherwise it is filled in
+// when the point is read off the collector channel.
 type Metric struct {
 	Name       string            `json:"name"`
 	MetricType string            `json:"type"`
 	Value      float64           `json:"value"`
 	Dimensions map[string]string `json:"dimensions"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// Info describes a metric a collector emits, for operators. The parts of
+// the name that depend on what was collected are in angle brackets.
+type Info struct {
+	Name        string
+	MetricType  string
+	Description string
+}
+
+// jsonMetric is the wire format of a Metric. The timestamp is sent
+// as (fractional) seconds since the epoch, which is what Diamond and
+// AdHoc scripts produce.
+type jsonMetric struct {
+	Name       string            `json:"name"`
+	MetricType string            `json:"type"`
+	Value      float64           `json:"value"`
+	Dimensions map[string]string `json:"dimensions"`
+	Timestamp  float64           `json:"timestamp,omitempty"`
 }
 
 // New returns a new metric with name. Default metric type is "gauge"
-// and timestamp is set to now. Value is initialized to 0.0.
+// and timestamp is left unset. Value is initialized to 0.0.
 func New(name string) Metric {
 	return Metric{
 		Name:       name,
@@ -69,12 +100,71 @@ func (m *Metric) GetDimensionValue(dimension string) (value string, ok bool) {
 	return
 }
 
+// SeriesKey identifies the time series of the metric: its name along with
+// the sorted dimensions.
+func (m *Metric) SeriesKey() string {
+	names := make([]string, 0, len(m.Dimensions))
+	for name := range m.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names)+1)
+	parts = append(parts, m.Name)
+	for _, name := range names {
+		parts = append(parts, name+"="+m.Dimensions[name])
+	}
+	return strings.Join(parts, ",")
+}
+
 // ZeroValue is metric zero value
 func (m *Metric) ZeroValue() bool {
 	return (len(m.Name) == 0) &&
 		(len(m.MetricType) == 0) &&
 		(m.Value == 0.0) &&
-		(len(m.Dimensions) == 0)
+		(len(m.Dimensions) == 0) &&
+		m.Timestamp.IsZero()
+}
+
+// SetTimestampIfUnset sets the timestamp of the metric to t if
+// nobody has set it yet.
+func (m *Metric) SetTimestampIfUnset(t time.Time) {
+	if m.Timestamp.IsZero() {
+		m.Timestamp = t
+	}
+}
+
+// MarshalJSON encodes the timestamp as seconds since the epoch and
+// omits it altogether when it is not set.
+func (m Metric) MarshalJSON() ([]byte, error) {
+	jm := jsonMetric{
+		Name:       m.Name,
+		MetricType: m.MetricType,
+		Value:      m.Value,
+		Dimensions: m.Dimensions,
+	}
+	if !m.Timestamp.IsZero() {
+		jm.Timestamp = float64(m.Timestamp.UnixNano()) / float64(time.Second)
+	}
+	return json.Marshal(jm)
+}
+
+// UnmarshalJSON accepts the timestamp as seconds since the epoch. A
+// missing timestamp leaves the metric's Timestamp unset.
+func (m *Metric) UnmarshalJSON(data []byte) error {
+	var jm jsonMetric
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+	m.Name = jm.Name
+	m.MetricType = jm.MetricType
+	m.Value = jm.Value
+	m.Dimensions = jm.Dimensions
+	m.Timestamp = time.Time{}
+	if jm.Timestamp > 0 {
+		m.Timestamp = time.Unix(0, int64(jm.Timestamp*float64(time.Second)))
+	}
+	return nil
 }
 
 // AddToAll adds a map of dimensions to a list of metrics
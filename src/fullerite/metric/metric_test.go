@@ -3,7 +3,9 @@ package metric_test
 import (
 	"fullerite/metric"
 
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -89,3 +91,62 @@ func TestAddDimensions(t *testing.T) {
 
 	assert.Equal(t, m1, m2)
 }
+
+func TestMetricJSONWithoutTimestamp(t *testing.T) {
+	m := metric.New("TestMetric")
+
+	b, err := json.Marshal(m)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"TestMetric","type":"gauge","value":0,"dimensions":{}}`, string(b))
+}
+
+func TestMetricJSONRoundTrip(t *testing.T) {
+	m := metric.New("TestMetric")
+	m.Value = 1.5
+	m.AddDimension("TestDimension", "test value")
+	m.Timestamp = time.Unix(1450000000, 500000000)
+
+	b, err := json.Marshal(m)
+	assert.Nil(t, err)
+
+	var parsed metric.Metric
+	assert.Nil(t, json.Unmarshal(b, &parsed))
+	assert.Equal(t, m.Name, parsed.Name)
+	assert.Equal(t, m.Value, parsed.Value)
+	assert.Equal(t, m.Dimensions, parsed.Dimensions)
+	assert.Equal(t, m.Timestamp.UnixNano()/int64(time.Millisecond),
+		parsed.Timestamp.UnixNano()/int64(time.Millisecond))
+}
+
+func TestMetricUnmarshalEpochSeconds(t *testing.T) {
+	var m metric.Metric
+	err := json.Unmarshal([]byte(`{"name":"foo","type":"gauge","value":1,"timestamp":1450000000}`), &m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1450000000), m.Timestamp.Unix())
+}
+
+func TestSetTimestampIfUnset(t *testing.T) {
+	m := metric.New("TestMetric")
+	first := time.Unix(1450000000, 0)
+	m.SetTimestampIfUnset(first)
+	m.SetTimestampIfUnset(first.Add(time.Minute))
+
+	assert.Equal(t, first, m.Timestamp)
+}
+
+func TestSeriesKey(t *testing.T) {
+	m1 := metric.New("TestMetric")
+	m1.AddDimension("b", "2")
+	m1.AddDimension("a", "1")
+
+	m2 := metric.New("TestMetric")
+	m2.AddDimension("a", "1")
+	m2.AddDimension("b", "2")
+
+	assert.Equal(t, "TestMetric,a=1,b=2", m1.SeriesKey())
+	assert.Equal(t, m1.SeriesKey(), m2.SeriesKey(), "order of the dimensions should not matter")
+
+	m2.AddDimension("b", "3")
+	assert.NotEqual(t, m1.SeriesKey(), m2.SeriesKey())
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The modes of the derivative processor
+const (
+	DerivativeRate  = "rate"
+	DerivativeDelta = "delta"
+
+	// DefaultDerivativeMaxAge is how long (in seconds) the last value of a
+	// series is kept around without seeing a new one
+	DefaultDerivativeMaxAge = 600
+)
+
+func init() {
+	RegisterProcessor("derivative", newDerivative)
+}
+
+// derivative turns cumulative counters into per-second rates or into the
+// delta since the last value, for backends that cannot do it themselves. It
+// remembers the last value of every series, the first value of a series
+// only sets the baseline and is dropped.
+//
+// A value that is lower than the one before means the counter was reset,
+// which starts a new baseline, or that it wrapped around. A wrap is only
+// assumed if counterMax is set and the last value was in the upper half
+// of the range.
+type derivative struct {
+	match      *Matcher
+	rate       bool
+	counterMax float64
+	maxAge     time.Duration
+
+	lock      sync.Mutex
+	last      map[string]counterSample
+	lastSweep time.Time
+}
+
+type counterSample struct {
+	value     float64
+	timestamp time.Time
+}
+
+func newDerivative(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	mode := DerivativeRate
+	if value, exists := rule["mode"]; exists {
+		mode, _ = value.(string)
+	}
+	if mode != DerivativeRate && mode != DerivativeDelta {
+		return nil, fmt.Errorf("mode must be %s or %s, got %v", DerivativeRate, DerivativeDelta, rule["mode"])
+	}
+
+	counterMax := config.GetAsFloat(rule["counterMax"], 0)
+	if counterMax < 0 {
+		return nil, fmt.Errorf("counterMax must be positive, got %v", counterMax)
+	}
+
+	maxAge := config.GetAsFloat(rule["maxAge"], DefaultDerivativeMaxAge)
+	if maxAge <= 0 {
+		return nil, fmt.Errorf("maxAge must be positive, got %v", maxAge)
+	}
+
+	return &derivative{
+		match:      match,
+		rate:       mode == DerivativeRate,
+		counterMax: counterMax,
+		maxAge:     time.Duration(maxAge * float64(time.Second)),
+		last:       make(map[string]counterSample),
+		lastSweep:  time.Now(),
+	}, nil
+}
+
+func (d *derivative) Process(m *metric.Metric) bool {
+	if m.MetricType != metric.CumulativeCounter || !d.match.Matches(m) {
+		return true
+	}
+
+	current := counterSample{value: m.Value, timestamp: m.Timestamp}
+	if current.timestamp.IsZero() {
+		current.timestamp = time.Now()
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.sweep(current.timestamp)
+
+	key := m.SeriesKey()
+	previous, exists := d.last[key]
+	if exists && !current.timestamp.After(previous.timestamp) {
+		// a duplicate or a value that arrived out of order
+		return false
+	}
+	d.last[key] = current
+	if !exists || current.timestamp.Sub(previous.timestamp) > d.maxAge {
+		return false
+	}
+
+	delta := current.value - previous.value
+	if delta < 0 {
+		if d.counterMax == 0 || previous.value < d.counterMax/2 {
+			// the counter was reset, this is the new baseline
+			return false
+		}
+		delta += d.counterMax + 1
+	}
+
+	if d.rate {
+		m.Value = delta / current.timestamp.Sub(previous.timestamp).Seconds()
+		m.MetricType = metric.Gauge
+	} else {
+		m.Value = delta
+		m.MetricType = metric.Counter
+	}
+	return true
+}
+
+// sweep forgets the series that were not seen for longer than the max age,
+// it only looks at them once per max age. The caller must hold the lock.
+func (d *derivative) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.maxAge {
+		return
+	}
+	for key, sample := range d.last {
+		if now.Sub(sample.timestamp) > d.maxAge {
+			delete(d.last, key)
+		}
+	}
+	d.lastSweep = now
+}
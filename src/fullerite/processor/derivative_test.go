@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterAt(value float64, at time.Time) metric.Metric {
+	m := metric.WithValue("requests", value)
+	m.MetricType = metric.CumulativeCounter
+	m.AddDimension("host", "web01")
+	m.Timestamp = at
+	return m
+}
+
+func TestDerivativeRate(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "derivative"}]`))
+	require.Nil(t, err)
+
+	start := time.Now()
+	processed := chain.Process([]metric.Metric{
+		counterAt(100, start),
+		counterAt(150, start.Add(10*time.Second)),
+		counterAt(250, start.Add(20*time.Second)),
+	})
+
+	require.Equal(t, 2, len(processed), "the first value is only the baseline")
+	assert.Equal(t, 5.0, processed[0].Value)
+	assert.Equal(t, 10.0, processed[1].Value)
+	assert.Equal(t, metric.Gauge, processed[0].MetricType)
+}
+
+func TestDerivativeDelta(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "derivative", "mode": "delta"}]`))
+	require.Nil(t, err)
+
+	start := time.Now()
+	processed := chain.Process([]metric.Metric{
+		counterAt(100, start),
+		counterAt(150, start.Add(10*time.Second)),
+	})
+
+	require.Equal(t, 1, len(processed))
+	assert.Equal(t, 50.0, processed[0].Value)
+	assert.Equal(t, metric.Counter, processed[0].MetricType)
+}
+
+func TestDerivativeKeepsSeriesApart(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "derivative", "mode": "delta"}]`))
+	require.Nil(t, err)
+
+	start := time.Now()
+	other := counterAt(1000, start.Add(10*time.Second))
+	other.AddDimension("host", "web02")
+
+	processed := chain.Process([]metric.Metric{
+		counterAt(100, start),
+		other,
+		counterAt(150, start.Add(10*time.Second)),
+	})
+
+	require.Equal(t, 1, len(processed))
+	assert.Equal(t, 50.0, processed[0].Value)
+}
+
+func TestDerivativeReset(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "derivative", "mode": "delta"}]`))
+	require.Nil(t, err)
+
+	start := time.Now()
+	processed := chain.Process([]metric.Metric{
+		counterAt(100, start),
+		counterAt(5, start.Add(10*time.Second)),
+		counterAt(25, start.Add(20*time.Second)),
+	})
+
+	require.Equal(t, 1, len(processed), "a reset starts a new baseline")
+	assert.Equal(t, 20.0, processed[0].Value)
+}
+
+func TestDerivativeWrap(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "derivative", "mode": "delta", "counterMax": 255}]`))
+	require.Nil(t, err)
+
+	start := time.Now()
+	processed := chain.Process([]metric.Metric{
+		counterAt(250, start),
+		counterAt(4, start.Add(10*time.Second)),
+	})
+
+	require.Equal(t, 1, len(processed))
+	assert.Equal(t, 10.0, processed[0].Value)
+}
+
+func TestDerivativeIgnoresOtherTypes(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "derivative"}]`))
+	require.Nil(t, err)
+
+	processed := chain.Process([]metric.Metric{metric.WithValue("gauge", 3)})
+	require.Equal(t, 1, len(processed))
+	assert.Equal(t, 3.0, processed[0].Value)
+}
+
+func TestDerivativeOutOfOrder(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "derivative", "mode": "delta"}]`))
+	require.Nil(t, err)
+
+	start := time.Now()
+	processed := chain.Process([]metric.Metric{
+		counterAt(100, start),
+		counterAt(150, start.Add(10*time.Second)),
+		counterAt(120, start.Add(5*time.Second)),
+		counterAt(160, start.Add(20*time.Second)),
+	})
+
+	require.Equal(t, 2, len(processed))
+	assert.Equal(t, 10.0, processed[1].Value)
+}
+
+func TestDerivativeInvalid(t *testing.T) {
+	for _, conf := range []string{
+		`[{"type": "derivative", "mode": "integral"}]`,
+		`[{"type": "derivative", "counterMax": -1}]`,
+		`[{"type": "derivative", "maxAge": 0}]`,
+	} {
+		_, err := NewChain(rules(t, conf))
+		assert.NotNil(t, err, conf)
+	}
+}
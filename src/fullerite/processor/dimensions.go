@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"fmt"
+)
+
+func init() {
+	RegisterProcessor("addDimensions", newAddDimensions)
+	RegisterProcessor("dropDimensions", newDropDimensions)
+	RegisterProcessor("renameDimensions", newRenameDimensions)
+}
+
+// addDimensions sets dimensions, overwriting the ones that already exist
+type addDimensions struct {
+	match      *Matcher
+	dimensions map[string]string
+}
+
+func newAddDimensions(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	dimensions, err := dimensionMap(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &addDimensions{match: match, dimensions: dimensions}, nil
+}
+
+func (a *addDimensions) Process(m *metric.Metric) bool {
+	if a.match.Matches(m) {
+		m.AddDimensions(a.dimensions)
+	}
+	return true
+}
+
+// dropDimensions removes dimensions
+type dropDimensions struct {
+	match      *Matcher
+	dimensions []string
+}
+
+func newDropDimensions(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	names, ok := rule["dimensions"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("dimensions must be a list of names, got %v", rule["dimensions"])
+	}
+	for _, name := range names {
+		if _, ok := name.(string); !ok {
+			return nil, fmt.Errorf("dimensions must be a list of names, got %v", names)
+		}
+	}
+	return &dropDimensions{match: match, dimensions: config.GetAsSlice(names)}, nil
+}
+
+func (d *dropDimensions) Process(m *metric.Metric) bool {
+	if d.match.Matches(m) {
+		for _, name := range d.dimensions {
+			m.RemoveDimension(name)
+		}
+	}
+	return true
+}
+
+// renameDimensions moves the values of dimensions to new names, given as
+// {"old": "new"}. The renames happen in no particular order, so a name can
+// neither be renamed and be the new name of another dimension, nor be the new
+// name of two dimensions; renames that build on each other go in rules of
+// their own.
+type renameDimensions struct {
+	match   *Matcher
+	renames map[string]string
+}
+
+func newRenameDimensions(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	renames, err := dimensionMap(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	renamedFrom := make(map[string]string)
+	for from, to := range renames {
+		if _, chained := renames[to]; chained && to != from {
+			return nil, fmt.Errorf("dimension %s is renamed to %s, which is renamed itself", from, to)
+		}
+		if other, exists := renamedFrom[to]; exists {
+			return nil, fmt.Errorf("dimensions %s and %s are both renamed to %s", other, from, to)
+		}
+		renamedFrom[to] = from
+	}
+	return &renameDimensions{match: match, renames: renames}, nil
+}
+
+func (r *renameDimensions) Process(m *metric.Metric) bool {
+	if !r.match.Matches(m) {
+		return true
+	}
+	for from, to := range r.renames {
+		if value, exists := m.Dimensions[from]; exists {
+			m.RemoveDimension(from)
+			m.AddDimension(to, value)
+		}
+	}
+	return true
+}
+
+// dimensionMap reads the "dimensions" object of a rule
+func dimensionMap(rule map[string]interface{}) (map[string]string, error) {
+	value, ok := rule["dimensions"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("dimensions must be an object, got %v", rule["dimensions"])
+	}
+	for k, v := range value {
+		if _, ok := v.(string); !ok {
+			return nil, fmt.Errorf("dimension %s must be a string, got %v", k, v)
+		}
+	}
+	return config.GetAsMap(value), nil
+}
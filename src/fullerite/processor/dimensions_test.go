@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddDimensions(t *testing.T) {
+	chain, err := NewChain(rules(t, `[
+		{"type": "addDimensions", "dimensions": {"region": "us-west"}, "match": {"name": "^web"}}
+	]`))
+	require.Nil(t, err)
+
+	processed := chain.Process([]metric.Metric{metric.New("web.requests"), metric.New("db.queries")})
+	assert.Equal(t, "us-west", processed[0].Dimensions["region"])
+	assert.NotContains(t, processed[1].Dimensions, "region")
+}
+
+func TestDropDimensions(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "dropDimensions", "dimensions": ["pid", "missing"]}]`))
+	require.Nil(t, err)
+
+	m := metric.New("proc.cpu")
+	m.AddDimensions(map[string]string{"pid": "123", "host": "web01"})
+	processed, _ := chain.ProcessMetric(m)
+	assert.Equal(t, map[string]string{"host": "web01"}, processed.Dimensions)
+}
+
+func TestRenameDimensions(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "renameDimensions", "dimensions": {"hostname": "host"}}]`))
+	require.Nil(t, err)
+
+	m := metric.New("test")
+	m.AddDimension("hostname", "web01")
+	processed, _ := chain.ProcessMetric(m)
+	assert.Equal(t, map[string]string{"host": "web01"}, processed.Dimensions)
+}
+
+func TestRenameDimensionsInRules(t *testing.T) {
+	chain, err := NewChain(rules(t, `[
+		{"type": "renameDimensions", "dimensions": {"b": "c"}},
+		{"type": "renameDimensions", "dimensions": {"a": "b"}}
+	]`))
+	require.Nil(t, err)
+
+	m := metric.New("test")
+	m.AddDimension("a", "1")
+	m.AddDimension("b", "2")
+	processed, _ := chain.ProcessMetric(m)
+	assert.Equal(t, map[string]string{"b": "1", "c": "2"}, processed.Dimensions)
+}
+
+func TestDimensionsInvalid(t *testing.T) {
+	for _, conf := range []string{
+		`[{"type": "addDimensions", "dimensions": ["a"]}]`,
+		`[{"type": "addDimensions", "dimensions": {"a": 1}}]`,
+		`[{"type": "dropDimensions", "dimensions": {"a": "b"}}]`,
+		`[{"type": "dropDimensions", "dimensions": [1]}]`,
+		`[{"type": "renameDimensions"}]`,
+		`[{"type": "renameDimensions", "dimensions": {"a": "b", "b": "c"}}]`,
+		`[{"type": "renameDimensions", "dimensions": {"a": "c", "b": "c"}}]`,
+	} {
+		_, err := NewChain(rules(t, conf))
+		assert.NotNil(t, err, conf)
+	}
+}
@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"fmt"
+)
+
+// The actions of the filter processor
+const (
+	FilterDrop = "drop"
+	FilterKeep = "keep"
+)
+
+func init() {
+	RegisterProcessor("filter", newFilter)
+}
+
+// filter drops the metrics that match, or with the keep action the metrics
+// that do not match
+type filter struct {
+	match *Matcher
+	keep  bool
+}
+
+func newFilter(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	if match == nil {
+		return nil, fmt.Errorf("match is missing")
+	}
+
+	action := FilterDrop
+	if value, exists := rule["action"]; exists {
+		action, _ = value.(string)
+	}
+	if action != FilterDrop && action != FilterKeep {
+		return nil, fmt.Errorf("action must be %s or %s, got %v", FilterDrop, FilterKeep, rule["action"])
+	}
+	return &filter{match: match, keep: action == FilterKeep}, nil
+}
+
+func (f *filter) Process(m *metric.Metric) bool {
+	return f.match.Matches(m) == f.keep
+}
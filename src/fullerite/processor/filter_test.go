@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func devMetrics() []metric.Metric {
+	dev := metric.New("dev.metric")
+	dev.AddDimension("env", "dev")
+	prod := metric.New("prod.metric")
+	prod.AddDimension("env", "prod")
+	return []metric.Metric{dev, prod}
+}
+
+func TestFilterDrop(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "filter", "match": {"dimensions": {"env": "^dev$"}}}]`))
+	require.Nil(t, err)
+
+	processed := chain.Process(devMetrics())
+	require.Equal(t, 1, len(processed))
+	assert.Equal(t, "prod.metric", processed[0].Name)
+}
+
+func TestFilterKeep(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "filter", "action": "keep", "match": {"name": "^dev\\."}}]`))
+	require.Nil(t, err)
+
+	processed := chain.Process(devMetrics())
+	require.Equal(t, 1, len(processed))
+	assert.Equal(t, "dev.metric", processed[0].Name)
+}
+
+func TestFilterInvalid(t *testing.T) {
+	_, err := NewChain(rules(t, `[{"type": "filter"}]`))
+	assert.NotNil(t, err, "should require a match")
+
+	_, err = NewChain(rules(t, `[{"type": "filter", "action": "maybe", "match": {"name": "a"}}]`))
+	assert.NotNil(t, err)
+}
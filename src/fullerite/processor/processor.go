@@ -0,0 +1,227 @@
+// Package processor transforms metrics on their way from the collectors to
+// the handlers. A chain of processors is declared as a list of rules in the
+// configuration, either globally or per handler, e.g.
+//
+//	"processors": [
+//		{"type": "rename", "pattern": "^cpu\\.(.*)$", "replacement": "system.cpu.$1"},
+//		{"type": "dropDimensions", "dimensions": ["pid"], "match": {"name": "^proc\\."}},
+//		{"type": "filter", "action": "drop", "match": {"dimensions": {"env": "^dev$"}}}
+//	]
+//
+// Every rule can be limited to some metrics with "match", which takes a
+// regex for the name and/or regexes for the values of dimensions.
+package processor
+
+import (
+	"fullerite/metric"
+
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// Processor changes a single metric in place. It returns false if the metric
+// should be dropped.
+type Processor interface {
+	Process(*metric.Metric) bool
+}
+
+var processorConstructs map[string]func(map[string]interface{}, *Matcher) (Processor, error)
+
+// RegisterProcessor composes a map of processor types -> factory functions
+func RegisterProcessor(name string, f func(map[string]interface{}, *Matcher) (Processor, error)) {
+	if processorConstructs == nil {
+		processorConstructs = make(map[string]func(map[string]interface{}, *Matcher) (Processor, error))
+	}
+	processorConstructs[name] = f
+}
+
+// New creates a processor from a single rule of the configuration
+func New(rule map[string]interface{}) (Processor, error) {
+	name, ok := rule["type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("processor rule without a type: %v", rule)
+	}
+
+	f, exists := processorConstructs[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown processor type %q", name)
+	}
+
+	match, err := NewMatcher(rule["match"])
+	if err != nil {
+		return nil, fmt.Errorf("%s processor: %s", name, err)
+	}
+
+	p, err := f(rule, match)
+	if err != nil {
+		return nil, fmt.Errorf("%s processor: %s", name, err)
+	}
+	return p, nil
+}
+
+// Matcher selects the metrics a processor applies to. A nil Matcher matches
+// every metric.
+type Matcher struct {
+	name       *regexp.Regexp
+	dimensions map[string]*regexp.Regexp
+}
+
+// NewMatcher parses the "match" part of a rule
+func NewMatcher(value interface{}) (*Matcher, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	conf, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("match must be an object, got %v", value)
+	}
+
+	m := new(Matcher)
+	if pattern, exists := conf["name"]; exists {
+		re, err := compileRegex(pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.name = re
+	}
+
+	if dimensions, exists := conf["dimensions"]; exists {
+		patterns, ok := dimensions.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("match dimensions must be an object, got %v", dimensions)
+		}
+		m.dimensions = make(map[string]*regexp.Regexp)
+		for dim, pattern := range patterns {
+			re, err := compileRegex(pattern)
+			if err != nil {
+				return nil, err
+			}
+			m.dimensions[dim] = re
+		}
+	}
+	return m, nil
+}
+
+// Matches returns true if the metric has a matching name and all the
+// dimensions with matching values
+func (mt *Matcher) Matches(m *metric.Metric) bool {
+	if mt == nil {
+		return true
+	}
+	if mt.name != nil && !mt.name.MatchString(m.Name) {
+		return false
+	}
+	for dim, re := range mt.dimensions {
+		value, exists := m.Dimensions[dim]
+		if !exists || !re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Chain runs metrics through a list of processors in order
+type Chain struct {
+	processors []Processor
+	filtered   uint64
+}
+
+// NewChain creates a chain from the rules in the configuration. Rules that
+// are invalid are left out and reported in the error.
+func NewChain(rules []map[string]interface{}) (*Chain, error) {
+	chain := new(Chain)
+	var errs []string
+	for i, rule := range rules {
+		p, err := New(rule)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("rule %d: %s", i, err))
+			continue
+		}
+		chain.processors = append(chain.processors, p)
+	}
+
+	if len(errs) > 0 {
+		return chain, fmt.Errorf("invalid processors: %v", errs)
+	}
+	return chain, nil
+}
+
+// ChainFromConfig creates a chain from the "processors" value of a handler
+// configuration, which is a list of rules
+func ChainFromConfig(value interface{}) (*Chain, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return new(Chain), fmt.Errorf("processors must be a list, got %v", value)
+	}
+
+	rules := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			return new(Chain), fmt.Errorf("processor rule must be an object, got %v", item)
+		}
+		rules = append(rules, rule)
+	}
+	return NewChain(rules)
+}
+
+// Len is the number of processors in the chain
+func (c *Chain) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.processors)
+}
+
+// Filtered is the number of metrics the chain dropped so far
+func (c *Chain) Filtered() uint64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.filtered)
+}
+
+// ProcessMetric runs a single metric through the chain. It returns false if
+// the metric was dropped. The dimensions are copied before the first
+// processor runs since the same metric is handed to every handler.
+func (c *Chain) ProcessMetric(m metric.Metric) (metric.Metric, bool) {
+	if c.Len() == 0 {
+		return m, true
+	}
+
+	dimensions := make(map[string]string, len(m.Dimensions))
+	for k, v := range m.Dimensions {
+		dimensions[k] = v
+	}
+	m.Dimensions = dimensions
+
+	for _, p := range c.processors {
+		if !p.Process(&m) {
+			atomic.AddUint64(&c.filtered, 1)
+			return m, false
+		}
+	}
+	return m, true
+}
+
+// Process runs all metrics through the chain and returns those that were
+// not dropped
+func (c *Chain) Process(metrics []metric.Metric) []metric.Metric {
+	result := make([]metric.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if processed, keep := c.ProcessMetric(m); keep {
+			result = append(result, processed)
+		}
+	}
+	return result
+}
+
+func compileRegex(value interface{}) (*regexp.Regexp, error) {
+	pattern, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a regex but got %v", value)
+	}
+	return regexp.Compile(pattern)
+}
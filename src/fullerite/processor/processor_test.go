@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rules parses the rules the same way they are read from fullerite.conf
+func rules(t *testing.T, conf string) []map[string]interface{} {
+	var parsed []map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(conf), &parsed))
+	return parsed
+}
+
+func TestNewUnknownProcessor(t *testing.T) {
+	_, err := New(map[string]interface{}{"type": "unknown"})
+	assert.NotNil(t, err)
+
+	_, err = New(map[string]interface{}{})
+	assert.NotNil(t, err, "should require a type")
+}
+
+func TestMatcher(t *testing.T) {
+	var nilMatcher *Matcher
+	m := metric.New("cpu.user")
+	assert.True(t, nilMatcher.Matches(&m), "nil matcher should match everything")
+
+	mt, err := NewMatcher(map[string]interface{}{
+		"name":       "^cpu\\.",
+		"dimensions": map[string]interface{}{"host": "^web"},
+	})
+	require.Nil(t, err)
+	assert.False(t, mt.Matches(&m), "should require the dimension")
+
+	m.AddDimension("host", "web01")
+	assert.True(t, mt.Matches(&m))
+
+	m.Name = "mem.free"
+	assert.False(t, mt.Matches(&m))
+
+	_, err = NewMatcher(map[string]interface{}{"name": "("})
+	assert.NotNil(t, err, "should reject invalid regexes")
+}
+
+func TestChainRunsInOrder(t *testing.T) {
+	chain, err := NewChain(rules(t, `[
+		{"type": "rename", "pattern": "^cpu\\.(.*)$", "replacement": "system.cpu.$1"},
+		{"type": "scale", "factor": 100, "match": {"name": "^system\\."}},
+		{"type": "filter", "match": {"name": "idle$"}}
+	]`))
+	require.Nil(t, err)
+	assert.Equal(t, 3, chain.Len())
+
+	user := metric.WithValue("cpu.user", 0.5)
+	idle := metric.WithValue("cpu.idle", 0.5)
+	processed := chain.Process([]metric.Metric{user, idle})
+
+	require.Equal(t, 1, len(processed))
+	assert.Equal(t, "system.cpu.user", processed[0].Name)
+	assert.Equal(t, 50.0, processed[0].Value)
+	assert.Equal(t, uint64(1), chain.Filtered())
+}
+
+func TestChainDoesNotChangeTheOriginal(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "addDimensions", "dimensions": {"region": "us-west"}}]`))
+	require.Nil(t, err)
+
+	m := metric.New("test")
+	processed, keep := chain.ProcessMetric(m)
+	assert.True(t, keep)
+	assert.Equal(t, "us-west", processed.Dimensions["region"])
+	assert.Empty(t, m.Dimensions, "other handlers share the dimensions of the metric")
+}
+
+func TestChainSkipsInvalidRules(t *testing.T) {
+	chain, err := NewChain(rules(t, `[
+		{"type": "scale"},
+		{"type": "setType", "metricType": "counter"}
+	]`))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, chain.Len(), "should keep the valid rules")
+}
+
+func TestChainFromConfig(t *testing.T) {
+	var conf map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(`{"processors": [{"type": "scale", "factor": 2}]}`), &conf))
+
+	chain, err := ChainFromConfig(conf["processors"])
+	assert.Nil(t, err)
+	assert.Equal(t, 1, chain.Len())
+
+	_, err = ChainFromConfig("not a list")
+	assert.NotNil(t, err)
+}
+
+func TestNilChain(t *testing.T) {
+	var chain *Chain
+	m := metric.New("test")
+	processed, keep := chain.ProcessMetric(m)
+	assert.True(t, keep)
+	assert.Equal(t, m, processed)
+	assert.Equal(t, uint64(0), chain.Filtered())
+}
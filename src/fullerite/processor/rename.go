@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"fmt"
+	"regexp"
+)
+
+func init() {
+	RegisterProcessor("rename", newRename)
+}
+
+// rename rewrites metric names with a regex, the replacement can refer to
+// groups of the pattern as $1, $2, ...
+type rename struct {
+	match       *Matcher
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func newRename(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	pattern, err := compileRegex(rule["pattern"])
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, ok := rule["replacement"].(string)
+	if !ok {
+		return nil, fmt.Errorf("replacement must be a string, got %v", rule["replacement"])
+	}
+
+	return &rename{
+		match:       match,
+		pattern:     pattern,
+		replacement: replacement,
+	}, nil
+}
+
+func (r *rename) Process(m *metric.Metric) bool {
+	if r.match.Matches(m) {
+		m.Name = r.pattern.ReplaceAllString(m.Name, r.replacement)
+	}
+	return true
+}
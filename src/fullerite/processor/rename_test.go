@@ -0,0 +1,35 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRename(t *testing.T) {
+	p, err := New(map[string]interface{}{
+		"type":        "rename",
+		"pattern":     "^(.*)\\.count$",
+		"replacement": "$1.total",
+	})
+	require.Nil(t, err)
+
+	m := metric.New("requests.count")
+	assert.True(t, p.Process(&m))
+	assert.Equal(t, "requests.total", m.Name)
+
+	m = metric.New("requests.rate")
+	p.Process(&m)
+	assert.Equal(t, "requests.rate", m.Name, "should leave names that do not match alone")
+}
+
+func TestRenameInvalid(t *testing.T) {
+	_, err := New(map[string]interface{}{"type": "rename", "pattern": "(", "replacement": ""})
+	assert.NotNil(t, err)
+
+	_, err = New(map[string]interface{}{"type": "rename", "pattern": "a"})
+	assert.NotNil(t, err, "should require a replacement")
+}
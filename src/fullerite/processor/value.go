@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"fmt"
+)
+
+func init() {
+	RegisterProcessor("scale", newScale)
+	RegisterProcessor("setType", newSetType)
+}
+
+// scale multiplies the value, e.g. to turn bytes into megabytes
+type scale struct {
+	match  *Matcher
+	factor float64
+}
+
+func newScale(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	value, exists := rule["factor"]
+	if !exists {
+		return nil, fmt.Errorf("factor is missing")
+	}
+	switch value.(type) {
+	case float64, string:
+	default:
+		return nil, fmt.Errorf("factor must be a number, got %v", value)
+	}
+	return &scale{match: match, factor: config.GetAsFloat(value, 1.0)}, nil
+}
+
+func (s *scale) Process(m *metric.Metric) bool {
+	if s.match.Matches(m) {
+		m.Value *= s.factor
+	}
+	return true
+}
+
+// setType overrides the type of the metric
+type setType struct {
+	match      *Matcher
+	metricType string
+}
+
+func newSetType(rule map[string]interface{}, match *Matcher) (Processor, error) {
+	metricType, _ := rule["metricType"].(string)
+	switch metricType {
+	case metric.Gauge, metric.Counter, metric.CumulativeCounter:
+	default:
+		return nil, fmt.Errorf("metricType must be one of %s, %s or %s, got %v",
+			metric.Gauge, metric.Counter, metric.CumulativeCounter, rule["metricType"])
+	}
+	return &setType{match: match, metricType: metricType}, nil
+}
+
+func (s *setType) Process(m *metric.Metric) bool {
+	if s.match.Matches(m) {
+		m.MetricType = s.metricType
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"fullerite/metric"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScale(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "scale", "factor": 0.001}]`))
+	require.Nil(t, err)
+
+	processed, _ := chain.ProcessMetric(metric.WithValue("bytes", 2000))
+	assert.Equal(t, 2.0, processed.Value)
+}
+
+func TestSetType(t *testing.T) {
+	chain, err := NewChain(rules(t, `[{"type": "setType", "metricType": "cumcounter", "match": {"name": "total$"}}]`))
+	require.Nil(t, err)
+
+	processed := chain.Process([]metric.Metric{metric.New("requests.total"), metric.New("requests.rate")})
+	assert.Equal(t, metric.CumulativeCounter, processed[0].MetricType)
+	assert.Equal(t, metric.Gauge, processed[1].MetricType)
+}
+
+func TestValueInvalid(t *testing.T) {
+	for _, conf := range []string{
+		`[{"type": "scale"}]`,
+		`[{"type": "scale", "factor": [1]}]`,
+		`[{"type": "setType", "metricType": "histogram"}]`,
+	} {
+		_, err := NewChain(rules(t, conf))
+		assert.NotNil(t, err, conf)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fullerite/config"
+
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// When a collector runs is configured in its own config file. By default it
+// runs every interval, counted from the moment it starts.
+//
+//	"align": true
+//		runs on the interval boundaries of the wall clock, e.g. at :00,
+//		:10, :20 with an interval of 10 seconds
+//	"splay": 5
+//		shifts the runs by up to 5 seconds, so that collectors and hosts
+//		do not all run at the same time
+//	"splayMode": "hostname"
+//		derives the shift from the host and collector name, so that it
+//		stays the same across restarts, rather than picking it at random
+//	"schedule": "2-59/5 * * * *"
+//		runs at the minutes of a cron expression instead of every
+//		interval, here every 5 minutes at minute 2
+const (
+	splayRandom   = "random"
+	splayHostname = "hostname"
+)
+
+// scheduleKeys are the keys of a collector config that set its schedule
+var scheduleKeys = []config.Key{
+	{Name: "align", Type: config.TypeBool, Default: false, Description: "run at multiples of the interval on the clock"},
+	{Name: "splay", Type: config.TypeInt, Default: 0, Description: "most seconds to shift the runs by"},
+	{Name: "splayMode", Type: config.TypeString, Default: splayRandom,
+		Description: "random, or hostname to derive the shift from the host and collector name"},
+	{Name: "schedule", Type: config.TypeString, Description: "cron expression to run at instead of every interval"},
+}
+
+// schedule decides when a collector runs
+type schedule interface {
+	// next returns the first time after t the collector runs at
+	next(t time.Time) time.Time
+}
+
+// newSchedule creates the schedule of a collector from its config
+func newSchedule(name string, interval int, conf map[string]interface{}) (schedule, error) {
+	splay := time.Duration(config.GetAsInt(conf["splay"], 0)) * time.Second
+	if splay < 0 {
+		return nil, fmt.Errorf("splay must not be negative, got %v", conf["splay"])
+	}
+
+	mode := splayRandom
+	if value, exists := conf["splayMode"]; exists {
+		mode, _ = value.(string)
+	}
+	var offset time.Duration
+	switch mode {
+	case splayRandom:
+		if splay > 0 {
+			offset = time.Duration(rand.Int63n(int64(splay)))
+		}
+	case splayHostname:
+		offset = hostnameSplay(name, splay)
+	default:
+		return nil, fmt.Errorf("splayMode must be %s or %s, got %v", splayRandom, splayHostname, conf["splayMode"])
+	}
+
+	if value, exists := conf["schedule"]; exists {
+		spec, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("schedule must be a cron expression, got %v", value)
+		}
+		cron, err := parseCron(spec)
+		if err != nil {
+			return nil, err
+		}
+		cron.offset = offset
+		return cron, nil
+	}
+
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %d", interval)
+	}
+	s := intervalSchedule{interval: time.Duration(interval) * time.Second}
+	if align, _ := conf["align"].(bool); align {
+		s.phase = time.Unix(0, 0).Add(offset)
+	} else {
+		// the first run is an interval away unless there is a splay
+		s.phase = time.Now().Add(offset)
+	}
+	return s, nil
+}
+
+// hostnameSplay spreads the collectors of all hosts over the splay, a host
+// always picks the same offset for a collector
+func hostnameSplay(name string, splay time.Duration) time.Duration {
+	if splay <= 0 {
+		return 0
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warn("Failed to get the hostname for the splay of ", name, ": ", err)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(hostname + "/" + name))
+	return time.Duration(h.Sum64() % uint64(splay))
+}
+
+// intervalSchedule runs every interval, counted from its phase
+type intervalSchedule struct {
+	interval time.Duration
+	phase    time.Time
+}
+
+func (s intervalSchedule) next(t time.Time) time.Time {
+	elapsed := t.Sub(s.phase)
+	n := elapsed / s.interval
+	if elapsed < 0 && elapsed%s.interval != 0 {
+		n--
+	}
+	return s.phase.Add((n + 1) * s.interval)
+}
+
+// cronSchedule runs at the minutes matching a cron expression in local time,
+// shifted by the offset
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// a day matches either field if both are restricted, like cron does
+	domStar, dowStar bool
+	offset           time.Duration
+}
+
+// parseCron parses the five fields of a cron expression: minute, hour, day
+// of month, month and day of week. Each field is a list of values, ranges
+// and "*", each optionally with a step, e.g. "*/15", "1-5" or "2/10,45".
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", spec)
+	}
+
+	s := new(cronSchedule)
+	bounds := []struct {
+		field    *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		bits, err := parseCronField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %s", spec, err)
+		}
+		*b.field = bits
+	}
+	// both 0 and 7 are Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+
+	if s.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", spec)
+	}
+	return s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+			item = item[:i]
+		}
+
+		first, last := min, max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err1, err2 error
+			first, err1 = strconv.Atoi(bounds[0])
+			last, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", item)
+			}
+		default:
+			var err error
+			if first, err = strconv.Atoi(item); err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			// a single value with a step runs up to the maximum
+			if step == 1 {
+				last = first
+			}
+		}
+
+		if first < min || last > max || first > last {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", item, min, max)
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) next(t time.Time) time.Time {
+	// the offset shifts every run, so the minutes are matched without it
+	t = t.Add(-s.offset).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t.Add(s.offset)
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntervalSchedule(t *testing.T) {
+	s, err := newSchedule("Test", 10, nil)
+	require.Nil(t, err)
+
+	now := time.Now()
+	next := s.next(now)
+	assert.True(t, next.Sub(now) > 9*time.Second, "should wait an interval for the first run")
+	assert.Equal(t, 10*time.Second, s.next(next).Sub(next))
+	assert.Equal(t, next, s.next(next.Add(-time.Nanosecond)))
+}
+
+func TestAlignedSchedule(t *testing.T) {
+	s, err := newSchedule("Test", 10, map[string]interface{}{"align": true})
+	require.Nil(t, err)
+
+	now := time.Date(2016, 3, 1, 12, 0, 3, 0, time.UTC)
+	assert.Equal(t, time.Date(2016, 3, 1, 12, 0, 10, 0, time.UTC), s.next(now).UTC())
+	assert.Equal(t, time.Date(2016, 3, 1, 12, 0, 20, 0, time.UTC), s.next(s.next(now)).UTC())
+}
+
+func TestAlignedScheduleWithHostnameSplay(t *testing.T) {
+	conf := map[string]interface{}{"align": true, "splay": 5, "splayMode": "hostname"}
+	s, err := newSchedule("Test", 10, conf)
+	require.Nil(t, err)
+	again, _ := newSchedule("Test", 10, conf)
+
+	now := time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)
+	next := s.next(now)
+	assert.Equal(t, next, again.next(now), "should pick the same splay every time")
+	assert.True(t, next.Sub(now) < 5*time.Second, "should stay within the splay")
+	assert.Equal(t, 10*time.Second, s.next(next).Sub(next))
+}
+
+func TestRandomSplay(t *testing.T) {
+	before := time.Now()
+	s, err := newSchedule("Test", 10, map[string]interface{}{"splay": 3})
+	require.Nil(t, err)
+
+	assert.True(t, s.next(before).Sub(before) < 3*time.Second, "should run within the splay first")
+}
+
+func TestCronSchedule(t *testing.T) {
+	s, err := newSchedule("Test", 10, map[string]interface{}{"schedule": "2-59/5 * * * *"})
+	require.Nil(t, err)
+
+	now := time.Date(2016, 3, 1, 12, 3, 30, 0, time.Local)
+	assert.Equal(t, time.Date(2016, 3, 1, 12, 7, 0, 0, time.Local), s.next(now))
+	assert.Equal(t, time.Date(2016, 3, 1, 13, 2, 0, 0, time.Local),
+		s.next(time.Date(2016, 3, 1, 12, 57, 0, 0, time.Local)))
+}
+
+func TestCronScheduleDays(t *testing.T) {
+	weekdays, err := parseCron("30 9 * * 1-5")
+	require.Nil(t, err)
+	// March 5th 2016 is a Saturday
+	assert.Equal(t, time.Date(2016, 3, 7, 9, 30, 0, 0, time.Local),
+		weekdays.next(time.Date(2016, 3, 5, 0, 0, 0, 0, time.Local)))
+
+	either, err := parseCron("0 0 1 * 0")
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2016, 3, 6, 0, 0, 0, 0, time.Local),
+		either.next(time.Date(2016, 3, 2, 0, 0, 0, 0, time.Local)), "should match the day of week")
+	assert.Equal(t, time.Date(2016, 4, 1, 0, 0, 0, 0, time.Local),
+		either.next(time.Date(2016, 3, 27, 0, 0, 0, 0, time.Local)), "should match the day of month")
+
+	sunday, err := parseCron("0 12 * * 7")
+	require.Nil(t, err)
+	assert.Equal(t, time.Sunday, sunday.next(time.Now()).Weekday())
+}
+
+func TestInvalidSchedules(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"schedule": "* * * *"},
+		{"schedule": "60 * * * *"},
+		{"schedule": "*/0 * * * *"},
+		{"schedule": "5-1 * * * *"},
+		{"schedule": "0 0 31 2 *"},
+		{"schedule": 5},
+		{"splay": -1},
+		{"splayMode": "sometimes"},
+	}
+	for _, conf := range invalid {
+		_, err := newSchedule("Test", 10, conf)
+		assert.NotNil(t, err, "should reject %v", conf)
+	}
+}
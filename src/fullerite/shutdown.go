@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fullerite/collector"
+	"fullerite/handler"
+
+	"sync"
+	"time"
+)
+
+// defaultShutdownTimeout is how long (in seconds) a shutdown may take
+// before fullerite gives up on flushing the remaining metrics
+const defaultShutdownTimeout = 30
+
+// shutdown stops fullerite in order: the collectors are stopped first, then
+// whatever they collected is handed to the handlers, and the handlers flush
+// their buffers. It returns false if that did not finish within the timeout.
+func shutdown(collectors []collector.Collector,
+	handlers []handler.Handler,
+	readers *sync.WaitGroup,
+	timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		stopCollectors(collectors, timeout)
+		stopReaders(collectors)
+		readers.Wait()
+
+		for _, h := range handlers {
+			if h != nil {
+				h.Stop()
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		log.Warn("Shutdown did not finish within ", timeout, ", metrics may have been lost")
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fullerite/collector"
+	"fullerite/config"
+	"fullerite/handler"
+	"fullerite/metric"
+
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownFlushesCollectedMetrics(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+	c := config.Config{Collectors: []string{"Test"}}
+
+	col := startCollector("Test", c, map[string]interface{}{"interval": 1})
+
+	log := logrus.WithFields(logrus.Fields{"app": "fullerite", "pkg": "handler"})
+	h := handler.NewTest(make(chan metric.Metric), 60, 100, time.Second, log)
+	h.InitListeners(c)
+	go h.Run()
+	handlers := []handler.Handler{h}
+
+	readers := readFromCollectors([]collector.Collector{col}, newHandlerSet(handlers, nil))
+
+	// let the collector run at least once
+	time.Sleep(5 * time.Second)
+
+	assert.True(t, shutdown([]collector.Collector{col}, handlers, readers, 10*time.Second))
+	assert.NotZero(t, h.InternalMetrics().Counters["metricsSent"],
+		"should have emitted the buffered metrics")
+
+	// collectors may have goroutines around that still emit
+	assert.NotPanics(t, func() {
+		select {
+		case col.Channel() <- metric.New("late"):
+		default:
+		}
+	}, "should not have closed the collector channel")
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	// a reader that never finishes
+	readers := new(sync.WaitGroup)
+	readers.Add(1)
+
+	start := time.Now()
+	assert.False(t, shutdown([]collector.Collector{}, []handler.Handler{}, readers, 100*time.Millisecond))
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,193 @@
+// Package stats keeps track of how the collectors are doing: how long their
+// runs take, when they last succeeded, how often they fail or time out and
+// what becomes of the points they emit. The internal server and the
+// Fullerite collector both publish the Default registry.
+package stats
+
+import (
+	"fullerite/metric"
+
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default is the registry fullerite records the stats of its collectors in
+var Default = NewRegistry()
+
+// CollectorStats is a snapshot of the stats of a single collector. Skipped
+// runs are left out because the previous run was still in progress.
+type CollectorStats struct {
+	Runs              uint64
+	Errors            uint64
+	Timeouts          uint64
+	Skipped           uint64
+	PointsEmitted     uint64
+	PointsBlacklisted uint64
+	// PointsDropped is the number of points that handlers dropped
+	// because their queue for the collector was full
+	PointsDropped   uint64
+	LastRunDuration time.Duration
+	// LastSuccess is zero until a run finished in time without
+	// logging an error
+	LastSuccess time.Time
+}
+
+// collectorStats is updated concurrently by the collector, its reader and
+// the error hook, every field is only accessed atomically
+type collectorStats struct {
+	runs              uint64
+	errors            uint64
+	timeouts          uint64
+	skipped           uint64
+	pointsEmitted     uint64
+	pointsBlacklisted uint64
+	pointsDropped     uint64
+	lastRunDuration   int64 // nanoseconds
+	lastSuccess       int64 // unix nanoseconds
+}
+
+// Registry holds the stats of every collector by canonical name. It is safe
+// for concurrent use.
+type Registry struct {
+	lock       sync.RWMutex
+	collectors map[string]*collectorStats
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]*collectorStats)}
+}
+
+func (r *Registry) collector(name string) *collectorStats {
+	r.lock.RLock()
+	s, exists := r.collectors[name]
+	r.lock.RUnlock()
+	if exists {
+		return s
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if s, exists = r.collectors[name]; !exists {
+		s = new(collectorStats)
+		r.collectors[name] = s
+	}
+	return s
+}
+
+// Run is a single run of a collector, see StartRun
+type Run struct {
+	stats    *collectorStats
+	start    time.Time
+	errors   uint64
+	timeouts uint64
+}
+
+// StartRun records that the named collector starts to collect. The run has
+// to be finished once Collect() returns.
+func (r *Registry) StartRun(name string) Run {
+	s := r.collector(name)
+	return Run{
+		stats:    s,
+		start:    time.Now(),
+		errors:   atomic.LoadUint64(&s.errors),
+		timeouts: atomic.LoadUint64(&s.timeouts),
+	}
+}
+
+// Finish records the duration of the run. The run counts as a success if the
+// collector neither logged an error nor timed out in the meantime.
+func (run Run) Finish() {
+	now := time.Now()
+	atomic.AddUint64(&run.stats.runs, 1)
+	atomic.StoreInt64(&run.stats.lastRunDuration, int64(now.Sub(run.start)))
+	if atomic.LoadUint64(&run.stats.errors) == run.errors &&
+		atomic.LoadUint64(&run.stats.timeouts) == run.timeouts {
+		atomic.StoreInt64(&run.stats.lastSuccess, now.UnixNano())
+	}
+}
+
+// AddError counts an error the named collector logged
+func (r *Registry) AddError(name string) {
+	atomic.AddUint64(&r.collector(name).errors, 1)
+}
+
+// AddTimeout counts a run of the named collector that took too long
+func (r *Registry) AddTimeout(name string) {
+	atomic.AddUint64(&r.collector(name).timeouts, 1)
+}
+
+// AddSkipped counts a run of the named collector that was left out
+func (r *Registry) AddSkipped(name string) {
+	atomic.AddUint64(&r.collector(name).skipped, 1)
+}
+
+// AddEmitted counts points of the named collector handed to the handlers
+func (r *Registry) AddEmitted(name string, points uint64) {
+	atomic.AddUint64(&r.collector(name).pointsEmitted, points)
+}
+
+// AddBlacklisted counts points of the named collector that were left out
+// because of its blacklist
+func (r *Registry) AddBlacklisted(name string, points uint64) {
+	atomic.AddUint64(&r.collector(name).pointsBlacklisted, points)
+}
+
+// AddDropped counts points of the named collector that handlers dropped
+func (r *Registry) AddDropped(name string, points uint64) {
+	atomic.AddUint64(&r.collector(name).pointsDropped, points)
+}
+
+// Collectors returns a snapshot of the stats of every collector
+func (r *Registry) Collectors() map[string]CollectorStats {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	snapshot := make(map[string]CollectorStats, len(r.collectors))
+	for name, s := range r.collectors {
+		snapshot[name] = s.snapshot()
+	}
+	return snapshot
+}
+
+func (s *collectorStats) snapshot() CollectorStats {
+	stats := CollectorStats{
+		Runs:              atomic.LoadUint64(&s.runs),
+		Errors:            atomic.LoadUint64(&s.errors),
+		Timeouts:          atomic.LoadUint64(&s.timeouts),
+		Skipped:           atomic.LoadUint64(&s.skipped),
+		PointsEmitted:     atomic.LoadUint64(&s.pointsEmitted),
+		PointsBlacklisted: atomic.LoadUint64(&s.pointsBlacklisted),
+		PointsDropped:     atomic.LoadUint64(&s.pointsDropped),
+		LastRunDuration:   time.Duration(atomic.LoadInt64(&s.lastRunDuration)),
+	}
+	if lastSuccess := atomic.LoadInt64(&s.lastSuccess); lastSuccess != 0 {
+		stats.LastSuccess = time.Unix(0, lastSuccess)
+	}
+	return stats
+}
+
+// InternalMetrics returns the stats of every collector as counters and
+// gauges, keyed by the name of the collector. The run duration is in
+// seconds, the last success is a unix timestamp and left out until there
+// was one.
+func (r *Registry) InternalMetrics() map[string]metric.InternalMetrics {
+	metrics := make(map[string]metric.InternalMetrics)
+	for name, s := range r.Collectors() {
+		m := metric.NewInternalMetrics()
+		m.Counters["fullerite.collector_datapoints"] = float64(s.PointsEmitted)
+		m.Counters["fullerite.collector_datapoints_dropped"] = float64(s.PointsDropped)
+		m.Counters["fullerite.collector_datapoints_blacklisted"] = float64(s.PointsBlacklisted)
+		m.Counters["fullerite.collector_runs"] = float64(s.Runs)
+		m.Counters["fullerite.collector_run_errors"] = float64(s.Errors)
+		m.Counters["fullerite.collector_run_timeouts"] = float64(s.Timeouts)
+		m.Counters["fullerite.collector_runs_skipped"] = float64(s.Skipped)
+		m.Gauges["fullerite.collector_run_duration"] = s.LastRunDuration.Seconds()
+		if !s.LastSuccess.IsZero() {
+			m.Gauges["fullerite.collector_last_success"] = float64(s.LastSuccess.Unix())
+		}
+		metrics[name] = *m
+	}
+	return metrics
+}
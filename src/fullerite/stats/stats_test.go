@@ -0,0 +1,91 @@
+package stats
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryCountsPoints(t *testing.T) {
+	r := NewRegistry()
+	r.AddEmitted("Test", 3)
+	r.AddBlacklisted("Test", 2)
+	r.AddDropped("Test", 1)
+	r.AddEmitted("Other", 1)
+
+	collectors := r.Collectors()
+	assert.Equal(t, 2, len(collectors))
+	assert.Equal(t, uint64(3), collectors["Test"].PointsEmitted)
+	assert.Equal(t, uint64(2), collectors["Test"].PointsBlacklisted)
+	assert.Equal(t, uint64(1), collectors["Test"].PointsDropped)
+	assert.Equal(t, uint64(1), collectors["Other"].PointsEmitted)
+}
+
+func TestRegistryRunSuccess(t *testing.T) {
+	r := NewRegistry()
+	before := time.Now()
+
+	run := r.StartRun("Test")
+	time.Sleep(10 * time.Millisecond)
+	run.Finish()
+
+	s := r.Collectors()["Test"]
+	assert.Equal(t, uint64(1), s.Runs)
+	assert.True(t, s.LastRunDuration >= 10*time.Millisecond)
+	assert.False(t, s.LastSuccess.Before(before), "should record the success")
+}
+
+func TestRegistryRunFailures(t *testing.T) {
+	r := NewRegistry()
+
+	run := r.StartRun("Test")
+	r.AddError("Test")
+	run.Finish()
+
+	run = r.StartRun("Test")
+	r.AddTimeout("Test")
+	run.Finish()
+
+	s := r.Collectors()["Test"]
+	assert.Equal(t, uint64(2), s.Runs)
+	assert.Equal(t, uint64(1), s.Errors)
+	assert.Equal(t, uint64(1), s.Timeouts)
+	assert.True(t, s.LastSuccess.IsZero(), "should not count failed runs as success")
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	r := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.AddEmitted("Test", 1)
+				r.InternalMetrics()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(1000), r.Collectors()["Test"].PointsEmitted)
+}
+
+func TestRegistryInternalMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.AddEmitted("Test", 5)
+	r.AddError("Test")
+
+	m := r.InternalMetrics()["Test"]
+	assert.Equal(t, 5.0, m.Counters["fullerite.collector_datapoints"])
+	assert.Equal(t, 1.0, m.Counters["fullerite.collector_run_errors"])
+	assert.Equal(t, 0.0, m.Counters["fullerite.collector_runs"])
+	assert.NotContains(t, m.Gauges, "fullerite.collector_last_success", "should leave out a success that never happened")
+
+	r.StartRun("Test").Finish()
+	m = r.InternalMetrics()["Test"]
+	assert.Contains(t, m.Gauges, "fullerite.collector_last_success")
+}
@@ -7,5 +7,8 @@ It includes functionality to determine the ip address of the machine that's runn
 mesos_leader.go:
 Detects the leader from amongst a set of mesos masters. It also caches this value for a configurable ttl to save time.
 
+listener.go:
+The bind address and the TLS settings shared by the ports fullerite listens on.
+
 */
 package util
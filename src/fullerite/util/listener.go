@@ -0,0 +1,70 @@
+package util
+
+import (
+	"fullerite/config"
+
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// ListenerKeys are the keys shared by the configs of everything fullerite
+// listens on: the address to bind to and the TLS certificates
+var ListenerKeys = []config.Key{
+	{Name: "bindAddress", Type: config.TypeString, Default: "",
+		Description: "address to listen on, every address when empty"},
+	{Name: "tlsCert", Type: config.TypeString,
+		Description: "PEM file of the server certificate, the port is plaintext without one"},
+	{Name: "tlsKey", Type: config.TypeString,
+		Description: "PEM file of the key of the server certificate"},
+	{Name: "tlsClientCA", Type: config.TypeString,
+		Description: "PEM file of the CAs that sign the client certificates, clients need a certificate if it is set"},
+}
+
+// ListenAddress joins the bind address of a config and a port into an
+// address to listen on
+func ListenAddress(conf map[string]interface{}, port string) string {
+	bindAddress, _ := conf["bindAddress"].(string)
+	return net.JoinHostPort(bindAddress, port)
+}
+
+// ServerTLSConfig loads the certificates of a config. It returns nil if
+// there is no server certificate, the port stays plaintext then.
+func ServerTLSConfig(conf map[string]interface{}) (*tls.Config, error) {
+	certFile, _ := conf["tlsCert"].(string)
+	keyFile, _ := conf["tlsKey"].(string)
+	clientCAFile, _ := conf["tlsClientCA"].(string)
+
+	if certFile == "" {
+		if keyFile != "" || clientCAFile != "" {
+			return nil, errors.New("tlsKey and tlsClientCA need a tlsCert")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		contents, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificates in %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"test_utils"
+
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenAddress(t *testing.T) {
+	assert.Equal(t, ":19191", ListenAddress(map[string]interface{}{}, "19191"))
+	assert.Equal(t, "127.0.0.1:19191", ListenAddress(map[string]interface{}{"bindAddress": "127.0.0.1"}, "19191"))
+	assert.Equal(t, "[::1]:19191", ListenAddress(map[string]interface{}{"bindAddress": "::1"}, "19191"))
+}
+
+func TestServerTLSConfigPlaintext(t *testing.T) {
+	tlsConfig, err := ServerTLSConfig(map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.Nil(t, tlsConfig)
+
+	_, err = ServerTLSConfig(map[string]interface{}{"tlsKey": "key.pem"})
+	assert.NotNil(t, err, "a key without a certificate is a mistake")
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	certs, err := test_utils.WriteTestCertificates()
+	assert.Nil(t, err)
+	defer certs.Remove()
+
+	tlsConfig, err := ServerTLSConfig(map[string]interface{}{
+		"tlsCert": certs.ServerCert,
+		"tlsKey":  certs.ServerKey,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+
+	tlsConfig, err = ServerTLSConfig(map[string]interface{}{
+		"tlsCert":     certs.ServerCert,
+		"tlsKey":      certs.ServerKey,
+		"tlsClientCA": certs.CA,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	assert.NotNil(t, tlsConfig.ClientCAs)
+}
+
+func TestServerTLSConfigInvalid(t *testing.T) {
+	certs, err := test_utils.WriteTestCertificates()
+	assert.Nil(t, err)
+	defer certs.Remove()
+
+	_, err = ServerTLSConfig(map[string]interface{}{"tlsCert": certs.ServerCert})
+	assert.NotNil(t, err, "the key is missing")
+
+	_, err = ServerTLSConfig(map[string]interface{}{
+		"tlsCert":     certs.ServerCert,
+		"tlsKey":      certs.ServerKey,
+		"tlsClientCA": certs.ServerKey,
+	})
+	assert.NotNil(t, err, "a key is not a CA")
+}
@@ -0,0 +1,134 @@
+package test_utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"time"
+)
+
+// TestCertificates are the PEM files of a throwaway CA, a server
+// certificate for 127.0.0.1 and a client certificate, both signed by the CA
+type TestCertificates struct {
+	Dir        string
+	CA         string
+	ServerCert string
+	ServerKey  string
+	ClientCert string
+	ClientKey  string
+}
+
+// WriteTestCertificates writes a set of certificates to a temporary
+// directory, it is removed with Remove
+func WriteTestCertificates() (*TestCertificates, error) {
+	dir, err := ioutil.TempDir("", "fullerite-certs")
+	if err != nil {
+		return nil, err
+	}
+	certs := &TestCertificates{
+		Dir:        dir,
+		CA:         path.Join(dir, "ca.pem"),
+		ServerCert: path.Join(dir, "server.pem"),
+		ServerKey:  path.Join(dir, "server-key.pem"),
+		ClientCert: path.Join(dir, "client.pem"),
+		ClientKey:  path.Join(dir, "client-key.pem"),
+	}
+
+	caTemplate := certificateTemplate(1, "fullerite test CA")
+	caTemplate.IsCA = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	caTemplate.BasicConstraintsValid = true
+	caKey, caCert, err := writeCertificate(caTemplate, nil, nil, certs.CA, "")
+	if err != nil {
+		return nil, err
+	}
+
+	serverTemplate := certificateTemplate(2, "127.0.0.1")
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	if _, _, err = writeCertificate(serverTemplate, caCert, caKey, certs.ServerCert, certs.ServerKey); err != nil {
+		return nil, err
+	}
+
+	clientTemplate := certificateTemplate(3, "fullerite test client")
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	if _, _, err = writeCertificate(clientTemplate, caCert, caKey, certs.ClientCert, certs.ClientKey); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// ClientTLSConfig trusts the CA and, if withCert is set, presents the
+// client certificate
+func (certs *TestCertificates) ClientTLSConfig(withCert bool) *tls.Config {
+	contents, _ := ioutil.ReadFile(certs.CA)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(contents)
+	tlsConfig := &tls.Config{RootCAs: pool}
+	if withCert {
+		cert, _ := tls.LoadX509KeyPair(certs.ClientCert, certs.ClientKey)
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig
+}
+
+// Remove deletes the certificates
+func (certs *TestCertificates) Remove() {
+	os.RemoveAll(certs.Dir)
+}
+
+func certificateTemplate(serial int64, name string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+// writeCertificate signs the template with the parent, or itself without
+// one, and writes the certificate and the key out
+func writeCertificate(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey,
+	certFile, keyFile string) (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err = ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		return nil, nil, err
+	}
+	if keyFile != "" {
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return nil, nil, err
+		}
+	}
+	return key, cert, nil
+}